@@ -0,0 +1,243 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+var ErrInvalidAegis = errors.New("invalid Aegis vault")
+var ErrUnsupportedEntry = errors.New("unsupported entry")
+
+// aegisSlotPassword is the Aegis slot type protected by a password
+const aegisSlotPassword = 1
+
+// aegisVault is an Aegis authenticator export. The DB is either a
+// plaintext aegisDB object or a base64 encoded, encrypted aegisDB.
+type aegisVault struct {
+	Version int             `json:"version"`
+	Header  aegisHeader     `json:"header"`
+	DB      json.RawMessage `json:"db"`
+}
+
+type aegisHeader struct {
+	Slots  []aegisSlot  `json:"slots"`
+	Params *aegisParams `json:"params"`
+}
+
+type aegisParams struct {
+	Nonce string `json:"nonce"`
+	Tag   string `json:"tag"`
+}
+
+type aegisSlot struct {
+	Type      int         `json:"type"`
+	UUID      string      `json:"uuid"`
+	Key       string      `json:"key"`
+	KeyParams aegisParams `json:"key_params"`
+	N         int         `json:"n,omitempty"`
+	R         int         `json:"r,omitempty"`
+	P         int         `json:"p,omitempty"`
+	Salt      string      `json:"salt,omitempty"`
+}
+
+type aegisDB struct {
+	Version int          `json:"version"`
+	Entries []aegisEntry `json:"entries"`
+}
+
+type aegisEntry struct {
+	Type   string    `json:"type"`
+	UUID   string    `json:"uuid"`
+	Name   string    `json:"name"`
+	Issuer string    `json:"issuer"`
+	Note   string    `json:"note"`
+	Icon   *string   `json:"icon"`
+	Info   aegisInfo `json:"info"`
+}
+
+type aegisInfo struct {
+	Secret  string `json:"secret"`
+	Algo    string `json:"algo"`
+	Digits  int    `json:"digits"`
+	Period  uint   `json:"period,omitempty"`
+	Counter uint64 `json:"counter,omitempty"`
+}
+
+// openAESGCM decrypts hex encoded AES-GCM parameters with key
+func openAESGCM(key, ciphertext []byte, params aegisParams) ([]byte, error) {
+	nonce, err := hex.DecodeString(params.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := hex.DecodeString(params.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Open(nil, nonce, append(ciphertext, tag...), nil)
+}
+
+// aegisMasterKey decrypts the vault master key with the first password
+// slot that the passphrase opens
+func aegisMasterKey(slots []aegisSlot, passphrase string) ([]byte, error) {
+	for _, slot := range slots {
+		if slot.Type != aegisSlotPassword {
+			continue
+		}
+
+		salt, err := hex.DecodeString(slot.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAegis, err)
+		}
+
+		if err := checkScryptParams(slot.N, slot.R, slot.P); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAegis, err)
+		}
+
+		slotKey, err := scrypt.Key([]byte(passphrase), salt, slot.N, slot.R, slot.P, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAegis, err)
+		}
+
+		encryptedKey, err := hex.DecodeString(slot.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAegis, err)
+		}
+
+		if masterKey, err := openAESGCM(slotKey, encryptedKey, slot.KeyParams); err == nil {
+			return masterKey, nil
+		}
+	}
+
+	return nil, ErrDecryptionFailed
+}
+
+// decodeAegisDB returns the vault database, decrypting it if needed
+func decodeAegisDB(vault aegisVault, passphrase PassphraseFunc) (aegisDB, error) {
+	var db aegisDB
+
+	if len(vault.Header.Slots) == 0 {
+		if err := json.Unmarshal(vault.DB, &db); err != nil {
+			return db, fmt.Errorf("%w: %s", ErrInvalidAegis, err)
+		}
+		return db, nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(vault.DB, &encoded); err != nil || vault.Header.Params == nil {
+		return db, fmt.Errorf("%w: encrypted database expected", ErrInvalidAegis)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return db, fmt.Errorf("%w: %s", ErrInvalidAegis, err)
+	}
+
+	if passphrase == nil {
+		return db, ErrPassphraseRequired
+	}
+
+	p, err := passphrase()
+	if err != nil {
+		return db, fmt.Errorf("%w: %s", ErrPassphraseRequired, err)
+	}
+
+	masterKey, err := aegisMasterKey(vault.Header.Slots, p)
+	if err != nil {
+		return db, err
+	}
+
+	plaintext, err := openAESGCM(masterKey, ciphertext, *vault.Header.Params)
+	if err != nil {
+		return db, ErrDecryptionFailed
+	}
+
+	if err := json.Unmarshal(plaintext, &db); err != nil {
+		return db, fmt.Errorf("%w: %s", ErrInvalidAegis, err)
+	}
+
+	return db, nil
+}
+
+// ParseAegis parses an Aegis authenticator JSON export into secrets. The
+// passphrase function is only called if the vault is encrypted. Entries
+// that cannot be represented are returned as skipped errors naming the
+// entry.
+func ParseAegis(data []byte, passphrase PassphraseFunc) ([]Secret, []error, error) {
+	var vault aegisVault
+	if err := json.Unmarshal(data, &vault); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidAegis, err)
+	}
+
+	if len(vault.DB) == 0 {
+		return nil, nil, fmt.Errorf("%w: no database", ErrInvalidAegis)
+	}
+
+	db, err := decodeAegisDB(vault, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		secrets []Secret
+		skipped []error
+	)
+
+	for _, e := range db.Entries {
+		name := e.Name
+		if len(e.Issuer) != 0 {
+			name = e.Issuer + ":" + e.Name
+		}
+
+		entryType := strings.ToLower(e.Type)
+		if entryType != TypeTOTP && entryType != TypeHOTP {
+			skipped = append(skipped, fmt.Errorf("%s: %w: type %s", name, ErrUnsupportedEntry, e.Type))
+			continue
+		}
+
+		secret := Secret{
+			Name:      name,
+			Value:     strings.ToUpper(e.Info.Secret),
+			Type:      entryType,
+			Algorithm: strings.ToUpper(e.Info.Algo),
+			Digits:    e.Info.Digits,
+			Issuer:    e.Issuer,
+			Account:   e.Name,
+			Notes:     e.Note,
+		}
+
+		if entryType == TypeHOTP {
+			secret.Counter = e.Info.Counter
+		} else {
+			secret.Period = e.Info.Period
+		}
+
+		if _, err := secret.Options().normalize(); err != nil {
+			skipped = append(skipped, fmt.Errorf("%s: %w: %s", name, ErrUnsupportedEntry, err))
+			continue
+		}
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, skipped, nil
+}
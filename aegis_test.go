@@ -0,0 +1,202 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const testAegisDB = `{
+	"version": 2,
+	"entries": [
+		{"type": "totp", "name": "alice", "issuer": "Example", "note": "work", "info": {"secret": "jbswy3dpehpk3pxp", "algo": "SHA256", "digits": 8, "period": 60}},
+		{"type": "hotp", "name": "counter", "issuer": "", "info": {"secret": "JBSWY3DPEHPK3PXP", "algo": "SHA1", "digits": 6, "counter": 7}},
+		{"type": "steam", "name": "game", "issuer": "Steam", "info": {"secret": "JBSWY3DPEHPK3PXP", "algo": "SHA1", "digits": 5, "period": 30}},
+		{"type": "totp", "name": "short", "issuer": "", "info": {"secret": "JBSWY3DPEHPK3PXP", "algo": "SHA1", "digits": 4, "period": 30}}
+	]
+}`
+
+// sealAESGCM encrypts plaintext in the Aegis layout, returning the
+// ciphertext and hex encoded nonce and tag
+func sealAESGCM(t *testing.T, key, plaintext []byte) ([]byte, aegisParams) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	for i := range nonce {
+		nonce[i] = byte(i)
+	}
+
+	sealed := gcm.Seal(nil, nonce, plaintext, nil)
+	tagStart := len(sealed) - gcm.Overhead()
+
+	return sealed[:tagStart], aegisParams{
+		Nonce: hex.EncodeToString(nonce),
+		Tag:   hex.EncodeToString(sealed[tagStart:]),
+	}
+}
+
+func encryptedAegisVault(t *testing.T, password string) []byte {
+	masterKey := make([]byte, 32)
+	for i := range masterKey {
+		masterKey[i] = byte(i * 3)
+	}
+
+	salt := []byte("0123456789abcdef")
+	slotKey, err := scrypt.Key([]byte(password), salt, 1024, 8, 1, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptedKey, keyParams := sealAESGCM(t, slotKey, masterKey)
+	encryptedDB, params := sealAESGCM(t, masterKey, []byte(testAegisDB))
+
+	db, _ := json.Marshal(base64.StdEncoding.EncodeToString(encryptedDB))
+	vault := aegisVault{
+		Version: 1,
+		Header: aegisHeader{
+			Slots: []aegisSlot{
+				{Type: 2, Key: "00"},
+				{
+					Type:      aegisSlotPassword,
+					Key:       hex.EncodeToString(encryptedKey),
+					KeyParams: keyParams,
+					N:         1024,
+					R:         8,
+					P:         1,
+					Salt:      hex.EncodeToString(salt),
+				},
+			},
+			Params: &params,
+		},
+		DB: db,
+	}
+
+	data, err := json.Marshal(vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestParseAegis(t *testing.T) {
+	plain := []byte(`{"version": 1, "header": {"slots": null, "params": null}, "db": ` + testAegisDB + `}`)
+	encrypted := encryptedAegisVault(t, "password")
+
+	wantSecrets := []Secret{
+		{Name: "Example:alice", Value: "JBSWY3DPEHPK3PXP", Type: TypeTOTP, Algorithm: "SHA256", Digits: 8, Period: 60, Issuer: "Example", Account: "alice", Notes: "work"},
+		{Name: "counter", Value: "JBSWY3DPEHPK3PXP", Type: TypeHOTP, Algorithm: "SHA1", Digits: 6, Counter: 7, Account: "counter"},
+	}
+
+	password := func(p string) PassphraseFunc {
+		return func() (string, error) { return p, nil }
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase PassphraseFunc
+		want       []Secret
+		wantErr    error
+	}{
+		{
+			name: "plain",
+			data: plain,
+			want: wantSecrets,
+		},
+		{
+			name:       "encrypted",
+			data:       encrypted,
+			passphrase: password("password"),
+			want:       wantSecrets,
+		},
+		{
+			name:       "wrong password",
+			data:       encrypted,
+			passphrase: password("wrong"),
+			wantErr:    ErrDecryptionFailed,
+		},
+		{
+			name:    "no password",
+			data:    encrypted,
+			wantErr: ErrPassphraseRequired,
+		},
+		{
+			name:       "password error",
+			data:       encrypted,
+			passphrase: func() (string, error) { return "", errors.New("no terminal") },
+			wantErr:    ErrPassphraseRequired,
+		},
+		{
+			name:    "not json",
+			data:    []byte("otpauth://totp/name?secret=SEED"),
+			wantErr: ErrInvalidAegis,
+		},
+		{
+			name:    "no database",
+			data:    []byte(`{"version": 1}`),
+			wantErr: ErrInvalidAegis,
+		},
+		{
+			name:    "encrypted database missing params",
+			data:    []byte(`{"version": 1, "header": {"slots": [{"type": 1}]}, "db": "AAAA"}`),
+			wantErr: ErrInvalidAegis,
+		},
+		{
+			name:       "excessive scrypt parameters",
+			data:       []byte(`{"version": 1, "header": {"slots": [{"type": 1, "n": 1073741824, "r": 8, "p": 1, "salt": "00"}], "params": {}}, "db": "AAAA"}`),
+			passphrase: password("password"),
+			wantErr:    ErrInvalidAegis,
+		},
+		{
+			name:       "zero scrypt p",
+			data:       []byte(`{"version": 1, "header": {"slots": [{"type": 1, "n": 16, "r": 1, "p": 0, "salt": "00"}], "params": {}}, "db": "AAAA"}`),
+			passphrase: password("password"),
+			wantErr:    ErrInvalidAegis,
+		},
+		{
+			name:       "zero scrypt r",
+			data:       []byte(`{"version": 1, "header": {"slots": [{"type": 1, "n": 16, "r": 0, "p": 1, "salt": "00"}], "params": {}}, "db": "AAAA"}`),
+			passphrase: password("password"),
+			wantErr:    ErrInvalidAegis,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skipped, err := ParseAegis(tt.data, tt.passphrase)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseAegis() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAegis() = %v, want %v", got, tt.want)
+			}
+			if len(skipped) != 2 {
+				t.Errorf("ParseAegis() skipped = %v, want 2 entries", skipped)
+			}
+			for _, err := range skipped {
+				if !errors.Is(err, ErrUnsupportedEntry) {
+					t.Errorf("ParseAegis() skipped error = %v, want %v", err, ErrUnsupportedEntry)
+				}
+			}
+		})
+	}
+}
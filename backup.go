@@ -0,0 +1,172 @@
+package totp
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBackupNotFound is returned when a backup to restore does not exist
+var ErrBackupNotFound = errors.New("backup not found")
+
+// DefaultBackupCount is the number of backups kept by the totp command
+const DefaultBackupCount = 5
+
+const (
+	backupSuffix     = ".bak"
+	backupTimeFormat = "20060102T150405.000000000Z"
+)
+
+// Backup is a timestamped copy of a collection file kept next to it
+type Backup struct {
+	// Filename is the path of the backup file
+	Filename string
+
+	// Time is when the backup was made
+	Time time.Time
+}
+
+// backupPrefix returns the start of the backup file names of filename
+func backupPrefix(filename string) string {
+	return filepath.Base(filename) + "."
+}
+
+// Backups returns the backups of the collection file filename, newest first
+func Backups(filename string) ([]Backup, error) {
+	dir := filepath.Dir(filename)
+	prefix := backupPrefix(filename)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), backupSuffix))
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, Backup{Filename: filepath.Join(dir, name), Time: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+
+	return backups, nil
+}
+
+// BackupFile copies the collection file filename to a new timestamped
+// backup and removes all but the newest keep backups. Nothing is done if
+// the file does not exist or keep is not positive. The name of the new
+// backup is returned.
+func BackupFile(filename string, keep int) (string, error) {
+	if keep <= 0 {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	backupName := filepath.Join(filepath.Dir(filename),
+		backupPrefix(filename)+time.Now().UTC().Format(backupTimeFormat)+backupSuffix)
+	if err := writeFileAtomic(backupName, data, 0600); err != nil {
+		return "", err
+	}
+
+	backups, err := Backups(filename)
+	if err != nil {
+		return backupName, err
+	}
+
+	for _, b := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(b.Filename); err != nil {
+			return backupName, err
+		}
+	}
+
+	return backupName, nil
+}
+
+// RestoreBackup replaces the collection file filename with the contents of
+// the backup, first backing up the current file so the restore can be
+// undone
+func RestoreBackup(filename, backup string, keep int) error {
+	data, err := os.ReadFile(backup)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrBackupNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := BackupFile(filename, keep); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filename, data, 0600)
+}
+
+// configSnapshot is the configuration of a collection: the passphrase
+// encrypting it and its secrets serialized without their HOTP counters and
+// the modified dates that changing a counter stamps
+type configSnapshot struct {
+	passphrase string
+	secrets    string
+}
+
+func (c *Collection) configSnapshot() *configSnapshot {
+	secrets := make(map[string]Secret, len(c.Secrets))
+	for name, secret := range c.Secrets {
+		secret.Counter = 0
+		secret.DateModified = time.Time{}
+		secrets[name] = secret
+	}
+
+	data, _ := json.Marshal(secrets)
+	return &configSnapshot{passphrase: c.passphrase, secrets: string(data)}
+}
+
+// snapshotLoaded keeps the configuration of a loaded collection file. Files
+// from an older schema are not kept, so saving their upgrade backs them up.
+func (c *Collection) snapshotLoaded() {
+	c.saved = nil
+	if len(c.schemaUpgrades) == 0 {
+		c.saved = c.configSnapshot()
+	}
+}
+
+// onlyCountersChanged reports whether the collection only changed HOTP
+// counters since its file was loaded or saved
+func (c *Collection) onlyCountersChanged() bool {
+	return c.saved != nil && *c.saved == *c.configSnapshot()
+}
+
+// SetBackupCount sets the number of backups of the collection file kept
+// by the Save method. Zero, the default, disables backups. Saves that only
+// change HOTP counters are not backed up.
+func (c *Collection) SetBackupCount(count int) {
+	c.backups = count
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
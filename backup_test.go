@@ -0,0 +1,149 @@
+package totp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBackupFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "collection.json")
+
+	// nothing to back up
+	if name, err := BackupFile(filename, 3); err != nil || name != "" {
+		t.Errorf("BackupFile() of missing file = %q, %v", name, err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filename, []byte{byte('0' + i)}, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := BackupFile(filename, 3); err != nil {
+			t.Fatal("BackupFile() error:", err)
+		}
+	}
+
+	backups, err := Backups(filename)
+	if err != nil || len(backups) != 3 {
+		t.Fatalf("Backups() = %v, %v", backups, err)
+	}
+
+	// newest first
+	if data, _ := os.ReadFile(backups[0].Filename); string(data) != "4" {
+		t.Errorf("newest backup = %q", data)
+	}
+	if data, _ := os.ReadFile(backups[2].Filename); string(data) != "2" {
+		t.Errorf("oldest backup = %q", data)
+	}
+
+	// disabled
+	if name, err := BackupFile(filename, 0); err != nil || name != "" {
+		t.Errorf("BackupFile() disabled = %q, %v", name, err)
+	}
+
+	// unrelated files are ignored
+	_ = os.WriteFile(filepath.Join(dir, "collection.json.notatime.bak"), nil, 0600)
+	_ = os.WriteFile(filepath.Join(dir, "other.json.20200102T030405.000000000Z.bak"), nil, 0600)
+	if backups, _ := Backups(filename); len(backups) != 3 {
+		t.Errorf("Backups() with unrelated files = %v", backups)
+	}
+
+	if _, err := Backups(filepath.Join(dir, "nosuchdir", "collection.json")); err == nil {
+		t.Error("Backups() of missing directory did not fail")
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "collection.json")
+
+	_ = os.WriteFile(filename, []byte("old"), 0600)
+	backup, _ := BackupFile(filename, 5)
+	_ = os.WriteFile(filename, []byte("new"), 0600)
+
+	if err := RestoreBackup(filename, backup, 5); err != nil {
+		t.Fatal("RestoreBackup() error:", err)
+	}
+
+	if data, _ := os.ReadFile(filename); string(data) != "old" {
+		t.Errorf("RestoreBackup() restored %q", data)
+	}
+
+	// the replaced collection was backed up
+	backups, _ := Backups(filename)
+	if len(backups) != 2 {
+		t.Fatalf("Backups() after restore = %v", backups)
+	}
+	if data, _ := os.ReadFile(backups[0].Filename); string(data) != "new" {
+		t.Errorf("backup before restore = %q", data)
+	}
+
+	if err := RestoreBackup(filename, filename+".nosuchbackup", 5); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("RestoreBackup() error = %v, want %v", err, ErrBackupNotFound)
+	}
+}
+
+func TestCollection_SaveBackups(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "collection.json")
+
+	c := NewCollection()
+	c.SetFilename(filename)
+	c.SetBackupCount(2)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		_, _ = c.UpdateSecret(name, "SEED")
+		if err := c.Save(); err != nil {
+			t.Fatal("Save() error:", err)
+		}
+	}
+
+	backups, _ := Backups(filename)
+	if len(backups) != 2 {
+		t.Fatalf("Backups() = %v", backups)
+	}
+
+	b, err := NewCollectionWithFile(backups[0].Filename)
+	if err != nil || len(b.Secrets) != 3 {
+		t.Errorf("newest backup = %v, %v", b.Secrets, err)
+	}
+
+	// saves that only advance HOTP counters are not backed up
+	_, _ = c.UpdateSecretWithOptions("hotp", "SEED", SecretOptions{Type: TypeHOTP})
+	_ = c.Save()
+	backups, _ = Backups(filename)
+	for i := 0; i < 2; i++ {
+		_, _ = c.GenerateCode("hotp")
+		if err := c.Save(); err != nil {
+			t.Fatal("Save() error:", err)
+		}
+	}
+	_ = c.Save()
+
+	if after, _ := Backups(filename); !reflect.DeepEqual(after, backups) {
+		t.Errorf("Backups() after counter changes = %v, want %v", after, backups)
+	}
+
+	// collections loaded from the file are compared with the file as
+	// loaded, which is not read again when saving
+	loaded, err := NewCollectionWithFile(filename)
+	if err != nil {
+		t.Fatal("NewCollectionWithFile() error:", err)
+	}
+	loaded.SetBackupCount(2)
+	_ = os.WriteFile(filename, []byte("{"), 0600)
+	_, _ = loaded.GenerateCode("hotp")
+	if err := loaded.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+	if after, _ := Backups(filename); !reflect.DeepEqual(after, backups) {
+		t.Errorf("Backups() after loaded counter change = %v, want %v", after, backups)
+	}
+
+	// encrypting the collection is a change
+	c.SetPassphrase("passphrase")
+	_ = c.Save()
+	if after, _ := Backups(filename); reflect.DeepEqual(after, backups) {
+		t.Error("Collection encryption not backed up")
+	}
+}
@@ -0,0 +1,42 @@
+package totp
+
+import "time"
+
+// Clock is the source of the current time a Collection generates codes
+// with and stamps the dates of secrets with. Setting one lets tests and
+// applications control time.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock of the system time, used by collections with no
+// clock set
+type SystemClock struct{}
+
+// Now returns the current system time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock is a Clock stopped at a time
+type FixedClock time.Time
+
+// Now returns the time of the clock
+func (f FixedClock) Now() time.Time {
+	return time.Time(f)
+}
+
+// SetClock sets the clock of the collection. A nil clock selects
+// SystemClock.
+func (c *Collection) SetClock(clock Clock) {
+	c.clock = clock
+}
+
+// now returns the current time of the collection's clock
+func (c *Collection) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+
+	return c.clock.Now()
+}
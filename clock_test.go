@@ -0,0 +1,55 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollection_SetClock(t *testing.T) {
+	added := time.Date(2019, 6, 23, 20, 0, 0, 0, time.UTC)
+	modified := added.Add(time.Hour)
+
+	c := NewCollection()
+	if now := c.now(); time.Since(now) > time.Minute {
+		t.Errorf("now() without clock = %v", now)
+	}
+
+	// Dates are stamped with the clock's time
+	c.SetClock(FixedClock(added))
+	secret, err := c.UpdateSecret("name", "SEED")
+	if err != nil || !secret.DateAdded.Equal(added) || !secret.DateModified.Equal(added) {
+		t.Errorf("UpdateSecret() = %v, %v", secret, err)
+	}
+
+	c.SetClock(FixedClock(modified))
+	if secret, _ := c.UpdateSecret("name", "SEEDSEED"); !secret.DateAdded.Equal(added) || !secret.DateModified.Equal(modified) {
+		t.Errorf("UpdateSecret() update = %v", secret)
+	}
+
+	c.SetClock(FixedClock(modified.Add(time.Hour)))
+	if secret, _ := c.RenameSecret("name", "renamed"); !secret.DateModified.Equal(modified.Add(time.Hour)) {
+		t.Errorf("RenameSecret() = %v", secret)
+	}
+
+	c.SetClock(FixedClock(modified.Add(2 * time.Hour)))
+	if secret, _ := c.SetMetadata("renamed", SecretMetadata{Issuer: "Example"}); !secret.DateModified.Equal(modified.Add(2 * time.Hour)) {
+		t.Errorf("SetMetadata() = %v", secret)
+	}
+
+	_, _ = c.UpdateSecretWithOptions("counter", "SEED", SecretOptions{Type: TypeHOTP})
+	c.SetClock(FixedClock(modified.Add(3 * time.Hour)))
+	if secret, _ := c.SetCounter("counter", 5); !secret.DateModified.Equal(modified.Add(3 * time.Hour)) {
+		t.Errorf("SetCounter() = %v", secret)
+	}
+
+	// Codes are generated at the clock's time
+	c.SetClock(FixedClock(added))
+	want, _ := Secret{Value: "SEEDSEED"}.GenerateCodeWithTime(added)
+	if code, err := c.GenerateCode("renamed"); err != nil || code != want {
+		t.Errorf("GenerateCode() = %v, %v, want %v", code, err, want)
+	}
+
+	if now := (SystemClock{}).Now(); time.Since(now) > time.Minute {
+		t.Errorf("SystemClock.Now() = %v", now)
+	}
+}
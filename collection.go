@@ -30,15 +30,62 @@ type Secret struct {
 
 	// Value is the secret (seed) value
 	Value string
+
+	// Type is the secret type, TypeTOTP or TypeHOTP. Empty selects
+	// TypeTOTP.
+	Type string `json:",omitempty"`
+
+	// Counter is the counter of an HOTP secret used to generate the next
+	// code
+	Counter uint64 `json:",omitempty"`
+
+	// Algorithm is the HMAC algorithm used to generate codes. Empty
+	// selects DefaultAlgorithm.
+	Algorithm string `json:",omitempty"`
+
+	// Digits is the number of digits in a generated code. Zero selects
+	// DefaultDigits.
+	Digits int `json:",omitempty"`
+
+	// Period is the number of seconds a generated code is valid. Zero
+	// selects DefaultPeriod.
+	Period uint `json:",omitempty"`
+
+	// Issuer is the provider or service the secret belongs to. Empty
+	// selects the issuer part of an "issuer:account" name.
+	Issuer string `json:",omitempty"`
+
+	// Account is the user name or email address of the account. Empty
+	// selects the account part of an "issuer:account" name.
+	Account string `json:",omitempty"`
+
+	// Notes is free-form text about the secret
+	Notes string `json:",omitempty"`
+
+	// Tags are the names of the groups the secret belongs to
+	Tags []string `json:",omitempty"`
 }
 
 // Collection is a struct that holds TOTP data
 type Collection struct {
+	// Version is the schema version of the serialized collection
+	Version int
+
 	// Secrets is a map of secrets using the secret name as the key
 	Secrets map[string]Secret
 
-	filename string
-	writer   io.Writer
+	filename   string
+	writer     io.Writer
+	passphrase string
+	backups    int
+	store      Store
+	clock      Clock
+
+	// saved is the configuration of the collection file as last loaded or
+	// saved, so saves only changing HOTP counters are not backed up
+	saved *configSnapshot
+
+	schemaUpgrades []string
 }
 
 // CollectionInterface is used for DI when needed
@@ -52,22 +99,21 @@ type CollectionInterface interface {
 }
 
 // Save serializes (marshals) the Collections struct and writes it to
-// a file
+// the store, writer, or file set. If a passphrase is set, the data is
+// encrypted before writing. A file is replaced atomically so a failed save
+// leaves the previous contents intact, and the previous file is kept as a
+// backup when a backup count is set.
 func (c *Collection) Save() error {
-	serializedSettings, err := c.Serialize()
-	if err != nil {
-		return err
+	switch {
+	case c.store != nil:
+		return c.store.Save(c)
+	case c.writer != nil:
+		return (&StdioStore{Writer: c.writer}).Save(c)
+	case len(c.filename) != 0:
+		return (&FileStore{Filename: c.filename, Backups: c.backups}).Save(c)
 	}
 
-	if c.writer != nil {
-		_, err = c.writer.Write(serializedSettings)
-	} else if len(c.filename) != 0 {
-		err = os.WriteFile(c.filename, serializedSettings, 0600)
-	} else {
-		err = ErrNoFilename
-	}
-
-	return err
+	return ErrNoFilename
 }
 
 // DeleteSecret deletes an entry by name
@@ -83,8 +129,14 @@ func (c *Collection) DeleteSecret(name string) (Secret, error) {
 }
 
 // UpdateSecret updates (if it exists) or adds a new entry with the
-// name and value given
+// name and value given. The options of an existing entry are kept.
 func (c *Collection) UpdateSecret(name, value string) (Secret, error) {
+	return c.UpdateSecretWithOptions(name, value, c.Secrets[name].Options())
+}
+
+// UpdateSecretWithOptions updates (if it exists) or adds a new entry with
+// the name, value, and code generation options given
+func (c *Collection) UpdateSecretWithOptions(name, value string, opts SecretOptions) (Secret, error) {
 	if len(name) == 0 {
 		return Secret{}, ErrSecretNameEmpty
 	}
@@ -93,8 +145,13 @@ func (c *Collection) UpdateSecret(name, value string) (Secret, error) {
 		return Secret{}, ErrSecretValueEmpty
 	}
 
+	opts, err := opts.normalize()
+	if err != nil {
+		return Secret{}, err
+	}
+
 	value = strings.ToUpper(value)
-	_, err := totp.GenerateCode(value, time.Now())
+	_, err = totp.GenerateCodeCustom(value, c.now(), opts.validateOpts())
 	if err != nil {
 		return Secret{}, err
 	}
@@ -103,20 +160,24 @@ func (c *Collection) UpdateSecret(name, value string) (Secret, error) {
 	if ok {
 		// entry indicates an update
 		retSecret.Value = value
-		retSecret.DateModified = time.Now()
-		c.Secrets[name] = retSecret
+		retSecret.DateModified = c.now()
 	} else {
 		// no entry indicates an add
-		dateAdded := time.Now()
+		dateAdded := c.now()
 		retSecret = Secret{
 			Name:         name,
 			Value:        value,
 			DateAdded:    dateAdded,
 			DateModified: dateAdded,
 		}
-		c.Secrets[name] = retSecret
 	}
 
+	retSecret.Type = opts.Type
+	retSecret.Algorithm = opts.Algorithm
+	retSecret.Digits = opts.Digits
+	retSecret.Period = opts.Period
+	c.Secrets[name] = retSecret
+
 	return retSecret, err
 }
 
@@ -132,7 +193,7 @@ func (c *Collection) RenameSecret(oldName, newName string) (Secret, error) {
 	}
 
 	retSecret.Name = newName
-	retSecret.DateModified = time.Now()
+	retSecret.DateModified = c.now()
 	c.Secrets[newName] = retSecret
 	delete(c.Secrets, oldName)
 
@@ -159,20 +220,30 @@ func (c *Collection) GetSecrets() []Secret {
 	return secrets
 }
 
-// GenerateCodeWithTime creates a TOTP code with the named secret's value
+// GenerateCodeWithTime creates a code with the named secret's value and
+// options. The counter of an HOTP secret is incremented, so the collection
+// must be saved to persist it.
 func (c *Collection) GenerateCodeWithTime(name string, time time.Time) (string, error) {
 	secret, err := c.GetSecret(name)
 	if err != nil {
 		return "", err
 	}
 
-	return totp.GenerateCode(secret.Value, time)
+	code, err := secret.GenerateCodeWithTime(time)
+	if err != nil || !secret.IsHOTP() {
+		return code, err
+	}
 
+	secret.Counter++
+	c.Secrets[name] = secret
+
+	return code, nil
 }
 
-// GenerateCode creates a TOTP code with the named secret's value
+// GenerateCode creates a code with the named secret's value at the current
+// time of the collection's clock
 func (c *Collection) GenerateCode(name string) (string, error) {
-	return c.GenerateCodeWithTime(name, time.Now())
+	return c.GenerateCodeWithTime(name, c.now())
 }
 
 // Serialize marshals the Collection struct into a byte array
@@ -180,19 +251,78 @@ func (c *Collection) Serialize() ([]byte, error) {
 	return json.MarshalIndent(c, "", "  ")
 }
 
-// Deserialize unmarshals a byte array into a Collection struct
+// Deserialize unmarshals a byte array into a Collection struct. Encrypted
+// data is decrypted with the collection passphrase, which is remembered so
+// the collection is encrypted again when saved. Data from older versions is
+// upgraded to the current schema, and data from newer versions is refused
+// with ErrNewerSchema.
 func (c *Collection) Deserialize(data []byte) error {
+	data, err := c.decryptData(data)
+	if err != nil {
+		return err
+	}
+
+	data, c.schemaUpgrades, err = upgradeSchema(data)
+	if err != nil {
+		return err
+	}
+
 	return json.Unmarshal(data, &c)
 }
 
-// SetWriter sets the writer for the Save method
+// marshal serializes the collection, encrypting it if a passphrase is set
+func (c *Collection) marshal() ([]byte, error) {
+	data, err := c.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.encryptData(data)
+}
+
+// encryptData encrypts data with the collection passphrase, if one is set
+func (c *Collection) encryptData(data []byte) ([]byte, error) {
+	if !c.Encrypted() {
+		return data, nil
+	}
+
+	return encrypt(data, c.passphrase)
+}
+
+// decryptData decrypts encrypted data with the collection passphrase,
+// which is remembered so the collection is encrypted again when saved.
+// Unencrypted data is returned unchanged.
+func (c *Collection) decryptData(data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+
+	passphrase, err := c.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = decrypt(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	c.passphrase = passphrase
+
+	return data, nil
+}
+
+// SetWriter sets the writer for the Save method, replacing any store
 func (c *Collection) SetWriter(writer io.Writer) {
 	c.writer = writer
+	c.store = nil
 }
 
-// SetFilename sets the filename for the Save method
+// SetFilename sets the filename for the Save method, replacing any store
 func (c *Collection) SetFilename(filename string) string {
 	c.filename = filename
+	c.store = nil
+	c.saved = nil
 
 	return c.filename
 }
@@ -200,6 +330,7 @@ func (c *Collection) SetFilename(filename string) string {
 // NewCollection creates a new, blank Collection instance
 func NewCollection() *Collection {
 	c := new(Collection)
+	c.Version = SchemaVersion
 	c.Secrets = make(map[string]Secret)
 	return c
 }
@@ -243,6 +374,9 @@ func NewCollectionWithFile(filename string) (c *Collection, err error) {
 
 	c, err = NewCollectionWithReader(f)
 	c.filename = filename
+	if err == nil {
+		c.snapshotLoaded()
+	}
 
 	return c, err
 }
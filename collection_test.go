@@ -58,6 +58,7 @@ func TestNewCollection(t *testing.T) {
 		{
 			name: "new collection",
 			want: &Collection{
+				Version: SchemaVersion,
 				Secrets: make(map[string]Secret),
 			},
 		},
@@ -90,6 +91,7 @@ func TestNewCollectionWithData(t *testing.T) {
 				data: []byte(`{ "Secrets": { "testname": { "DateAdded": "2012-11-01T22:08:41+00:00", "DateModified": "2012-11-02T22:08:41+00:00","Name": "testname", "Value": "seedseed" } } }`),
 			},
 			want: &Collection{
+				Version: SchemaVersion,
 				Secrets: map[string]Secret{
 					"testname": {
 						DateAdded:    addedTime,
@@ -98,6 +100,7 @@ func TestNewCollectionWithData(t *testing.T) {
 						Value:        "seedseed",
 					},
 				},
+				schemaUpgrades: []string{"version 0 to 1: add the schema version"},
 			},
 			wantErr: false,
 		},
@@ -107,6 +110,7 @@ func TestNewCollectionWithData(t *testing.T) {
 				data: []byte(`{`),
 			},
 			want: &Collection{
+				Version: SchemaVersion,
 				Secrets: make(map[string]Secret),
 			},
 			wantErr: true,
@@ -145,6 +149,7 @@ func TestNewCollectionWithReader(t *testing.T) {
 				reader: strings.NewReader(`{ "Secrets": { "testname": { "DateAdded": "2012-11-01T22:08:41+00:00", "DateModified": "2012-11-02T22:08:41+00:00","Name": "testname", "Value": "seedseed" } } }`),
 			},
 			want: &Collection{
+				Version: SchemaVersion,
 				Secrets: map[string]Secret{
 					"testname": {
 						DateAdded:    addedTime,
@@ -153,6 +158,7 @@ func TestNewCollectionWithReader(t *testing.T) {
 						Value:        "seedseed",
 					},
 				},
+				schemaUpgrades: []string{"version 0 to 1: add the schema version"},
 			},
 			wantErr: false,
 		},
@@ -162,6 +168,7 @@ func TestNewCollectionWithReader(t *testing.T) {
 				reader: strings.NewReader(`{`),
 			},
 			want: &Collection{
+				Version: SchemaVersion,
 				Secrets: make(map[string]Secret),
 			},
 			wantErr: true,
@@ -172,6 +179,7 @@ func TestNewCollectionWithReader(t *testing.T) {
 				reader: errorReader{},
 			},
 			want: &Collection{
+				Version: SchemaVersion,
 				Secrets: make(map[string]Secret),
 			},
 			wantErr: true,
@@ -0,0 +1,303 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	envAgentSocket          = "TOTP_AGENT_SOCK"
+	defaultAgentIdleTimeout = 15 * time.Minute
+	agentRequestTimeout     = 5 * time.Second
+
+	// agentStoreHeader is the response header naming the store the agent
+	// serves, so clients only use agents holding their collection
+	agentStoreHeader = "Totp-Store"
+	agentStdinStore  = "stdin:"
+)
+
+// errAgentUnavailable is returned when the agent cannot be reached, serves
+// another collection, or will not generate a code, so the code is generated
+// from the collection instead
+var errAgentUnavailable = errors.New("agent unavailable")
+
+type agentVars struct {
+	socket      string
+	idleTimeout time.Duration
+}
+
+// agentStore returns the store of the collection loaded by the command,
+// which is the absolute path of the --file store or, for stdin, the hash of
+// the data read so only agents loaded with the same data are used
+func agentStore() (string, error) {
+	if collectionFile.useStdio {
+		data, err := readStdin()
+		if err != nil {
+			return "", err
+		}
+
+		hash := sha256.Sum256(data)
+		return agentStdinStore + hex.EncodeToString(hash[:]), nil
+	}
+
+	if filename, err := filepath.Abs(collectionFile.filename); err == nil {
+		return filename, nil
+	}
+
+	return collectionFile.filename, nil
+}
+
+// storeHandler names the store the agent serves in each response
+type storeHandler struct {
+	handler http.Handler
+	store   string
+}
+
+func (h storeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(agentStoreHeader, h.store)
+	h.handler.ServeHTTP(w, r)
+}
+
+// idleHandler cancels the agent when no request is received for the idle
+// timeout
+type idleHandler struct {
+	handler http.Handler
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (h *idleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.timer.Reset(h.timeout)
+	h.handler.ServeHTTP(w, r)
+}
+
+// newIdleHandler returns a handler that calls cancel after timeout without
+// a request. A zero timeout never cancels.
+func newIdleHandler(handler http.Handler, timeout time.Duration, cancel func()) http.Handler {
+	if timeout <= 0 {
+		return handler
+	}
+
+	return &idleHandler{handler: handler, timer: time.AfterFunc(timeout, cancel), timeout: timeout}
+}
+
+// agentSocketPath returns the socket given or one in a new directory only
+// the user can access, along with a function removing the directory
+func agentSocketPath(socket string) (string, func(), error) {
+	if len(socket) != 0 {
+		return socket, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "totp-agent-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	return filepath.Join(dir, "agent.sock"), func() { os.RemoveAll(dir) }, nil
+}
+
+// runAgent holds the collection in memory and serves codes on a Unix socket
+// until interrupted or idle
+func runAgent(writer io.Writer, cfg agentVars) error {
+	// the store is identified before loading because stdin is read by both
+	store, err := agentStore()
+	if err != nil {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	socket, cleanup, err := agentSocketPath(cfg.socket)
+	if err != nil {
+		printError("Error creating socket directory", err)
+		return err
+	}
+	defer cleanup()
+
+	listener, err := serveListen(serveVars{socket: socket})
+	if err != nil {
+		printError("Error listening", err)
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the agent is read-only because it cannot save advanced HOTP counters
+	// to the stores it is loaded from
+	handler := newIdleHandler(storeHandler{handler: &codeServer{c: c, now: commandClock.Now}, store: store}, cfg.idleTimeout, cancel)
+
+	fmt.Fprintf(writer, "%s=%s; export %s;\n", envAgentSocket, socket, envAgentSocket)
+	if err := serveUntilDone(ctx, listener, handler); err != nil {
+		printError("Error serving", err)
+		return err
+	}
+
+	return nil
+}
+
+// agentCode requests the code of a named secret at a time from the agent
+// listening on the socket. errAgentUnavailable is returned if the agent is
+// not running, serves a store other than the one given, or does not
+// generate the code, as for HOTP secrets and secrets it does not hold.
+func agentCode(socket, store, name string, t time.Time) (codeDocument, error) {
+	client := http.Client{
+		Timeout: agentRequestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	target := "http://agent" + serveSecretsPath + "/" + url.PathEscape(name) + "/code?time=" + url.QueryEscape(t.Format(time.RFC3339))
+	resp, err := client.Get(target)
+	if err != nil {
+		return codeDocument{}, fmt.Errorf("%w: %v", errAgentUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if served := resp.Header.Get(agentStoreHeader); served != store {
+		return codeDocument{}, fmt.Errorf("%w: the agent serves %s", errAgentUnavailable, served)
+	}
+
+	// the collection is used for codes the agent does not generate, so
+	// its errors are output instead
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || len(result.Error) == 0 {
+			return codeDocument{}, fmt.Errorf("%w: agent response %s", errAgentUnavailable, resp.Status)
+		}
+
+		return codeDocument{}, fmt.Errorf("%w: %s", errAgentUnavailable, result.Error)
+	}
+
+	var result codeDocument
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return codeDocument{}, err
+	}
+
+	if result.Period != 0 {
+		period := time.Duration(result.Period) * time.Second
+		result.step = func(steps int) (string, error) {
+			step, err := agentCode(socket, store, name, t.Add(time.Duration(steps)*period))
+			return step.Code, err
+		}
+	}
+
+	return result, nil
+}
+
+// generateAgentCode writes the code of a named secret from the agent and,
+// when following, the codes of the periods after it. errAgentUnavailable is
+// returned without output so the caller can generate the code itself.
+func generateAgentCode(writer io.Writer, socket, name string, codeTime time.Time, cfg runVars, copier *codeCopier) error {
+	timeOffset := codeTime.Sub(commandClock.Now()) - cfg.backward + cfg.forward
+	store, err := agentStore()
+	if err != nil {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	generate := cfg.details.wrap(func(t time.Time) (codeDocument, error) {
+		return agentCode(socket, store, name, t)
+	})
+
+	t := codeTime.Add(cfg.forward - cfg.backward)
+	result, err := generate(t)
+	if err != nil {
+		if !errors.Is(err, errAgentUnavailable) {
+			printError("Error generating code", err)
+		}
+		return err
+	}
+
+	// The period is only known once the agent has answered
+	fresh, err := cfg.validity.codeTime(t, time.Duration(result.Period)*time.Second, commandClock.Sleep)
+	if err != nil {
+		printError("Error generating code", err)
+		return err
+	}
+
+	if !fresh.Equal(t) {
+		t = fresh
+		if result, err = generate(t); err != nil {
+			if !errors.Is(err, errAgentUnavailable) {
+				printError("Error generating code", err)
+			}
+			return err
+		}
+	}
+
+	if err := outputCode(writer, result); err != nil {
+		return err
+	}
+	copier.copy([]string{result.Code}, result.remaining(t))
+
+	if cfg.follow {
+		generateCodesService(commandClock, timeOffset, 0, time.Duration(result.Period)*time.Second, copier.wrap(generate))
+	}
+
+	return nil
+}
+
+func getAgentCmd() *cobra.Command {
+	var (
+		cfg      agentVars
+		cobraCmd = &cobra.Command{
+			Use:   cmdAgent,
+			Short: "Hold the collection in memory and serve codes",
+			Long: `Hold the collection in memory and serve codes
+
+The collection is loaded, and decrypted if needed, once. Codes are then
+served on a Unix socket that only the user can access until the agent is
+interrupted or receives no requests for --idle-timeout. The socket is output
+as a shell command setting the ` + envAgentSocket + ` environment variable.
+When it is set, "totp <name>" and --follow ask the agent for TOTP codes
+instead of loading the collection, if the agent serves the same --file
+collection or was given the same --stdio data. HOTP codes are always generated from the collection so
+their counters are saved.
+
+Secrets changed after the agent starts are not seen until it is restarted.`,
+			Example: `  totp agent > ~/.totp-agent &
+  . ~/.totp-agent
+  totp agent --stdio --idle-timeout 1h < collection.json
+  totp --stdio <name> < collection.json`,
+			Args: cobra.NoArgs,
+			Run: func(_ *cobra.Command, _ []string) {
+				_ = runAgent(os.Stdout, cfg)
+			},
+		}
+	)
+
+	cobraCmd.Flags().StringVarP(&cfg.socket, "socket", "", "", "Unix socket to listen on (default in a new temporary directory)")
+	cobraCmd.Flags().DurationVarP(&cfg.idleTimeout, "idle-timeout", "", defaultAgentIdleTimeout, "exit after this long without a request, or 0 to never exit")
+	cobraCmd.Flags().BoolP(optionStdio, "", false, "load with stdin")
+
+	return cobraCmd
+}
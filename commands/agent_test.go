@@ -0,0 +1,258 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
+)
+
+// startTestAgent serves the test collection, as the store given, on a socket
+// until the test ends
+func startTestAgent(t *testing.T, store string) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := serveListen(serveVars{socket: socket})
+	if err != nil {
+		t.Skip("Unix sockets are not supported:", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	c, _ := collectionFile.loader()
+	go func() {
+		_ = http.Serve(listener, storeHandler{handler: &codeServer{c: c, now: time.Now}, store: store})
+	}()
+
+	return socket
+}
+
+// captureStdout returns what f writes to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := os.Stdout
+	os.Stdout = w
+	f()
+	os.Stdout = saved
+	w.Close()
+
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestAgentCode(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+	c, _ := collectionFile.loader()
+	_, _ = c.UpdateSecretWithOptions("counter", "SEED", totp.SecretOptions{Type: totp.TypeHOTP})
+	_ = c.Save()
+
+	store, _ := agentStore()
+	socket := startTestAgent(t, store)
+
+	// the agent keeps the secrets it was started with
+	_, _ = c.DeleteSecret("name0")
+	_ = c.Save()
+
+	codeTime := time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)
+	want, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime)
+
+	result, err := agentCode(socket, store, "name0", codeTime)
+	if err != nil || result.Code != want || result.Period != 30 {
+		t.Errorf("agentCode() = %v, %v", result, err)
+	}
+	next, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime.Add(30 * time.Second))
+	if got, err := result.Next(); err != nil || got != next {
+		t.Errorf("agentCode() next = %q, %v", got, err)
+	}
+	previous, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime.Add(-30 * time.Second))
+	if got, err := result.Previous(); err != nil || got != previous {
+		t.Errorf("agentCode() previous = %q, %v", got, err)
+	}
+
+	// Missing and HOTP secrets, other stores, and stopped agents fall back
+	// to the collection
+	if _, err := agentCode(socket, store, "invalidname", codeTime); !errors.Is(err, errAgentUnavailable) || !strings.Contains(err.Error(), totp.ErrSecretNotFound.Error()) {
+		t.Errorf("agentCode() with invalid name error = %v", err)
+	}
+	if _, err := agentCode(socket, store, "counter", codeTime); !errors.Is(err, errAgentUnavailable) {
+		t.Errorf("agentCode() with HOTP secret error = %v", err)
+	}
+	if _, err := agentCode(socket, agentStdinStore, "name0", codeTime); !errors.Is(err, errAgentUnavailable) {
+		t.Errorf("agentCode() with another store error = %v", err)
+	}
+	if _, err := agentCode(filepath.Join(t.TempDir(), "missing.sock"), store, "name0", codeTime); !errors.Is(err, errAgentUnavailable) {
+		t.Errorf("agentCode() without agent error = %v", err)
+	}
+
+	// Time options and follow
+	var followed codeDocument
+	savedGenerateCodesService := generateCodesService
+	generateCodesService = func(_ clock, _, _, interval time.Duration, generate codeFunc) {
+		followed, _ = generate(codeTime)
+		if interval != 30*time.Second {
+			t.Errorf("follow interval = %v", interval)
+		}
+	}
+	defer func() { generateCodesService = savedGenerateCodesService }()
+
+	writer := &bytes.Buffer{}
+	cfg := runVars{backward: 30 * time.Second, follow: true}
+	if err := generateAgentCode(writer, socket, "name0", codeTime, cfg, nil); err != nil || writer.String() != previous+"\n" || followed.Code != want {
+		t.Errorf("generateAgentCode() = %q, %v, followed %v", writer.String(), err, followed)
+	}
+
+	// The agent's codes are checked for the minimum validity
+	clock := &testClock{now: codeTime}
+	savedCommandClock := commandClock
+	commandClock = clock
+	defer func() { commandClock = savedCommandClock }()
+
+	writer.Reset()
+	cfg = runVars{validity: codeValidity{min: 25 * time.Second}}
+	if err := generateAgentCode(writer, socket, "name0", codeTime, cfg, nil); err != nil || writer.String() != next+"\n" || clock.slept != 20*time.Second {
+		t.Errorf("generateAgentCode() = %q, %v, waited %v", writer.String(), err, clock.slept)
+	}
+
+	cfg = runVars{validity: codeValidity{min: time.Minute}}
+	if err := generateAgentCode(writer, socket, "name0", codeTime, cfg, nil); err == nil {
+		t.Error("generateAgentCode() with minimum validity longer than the period succeeded")
+	}
+
+	// The root command uses the agent, which still has the deleted secret,
+	// and falls back for HOTP secrets
+	t.Setenv(envAgentSocket, socket)
+	rootCmd := getRootCmd()
+	_ = rootCmd.Flags().Set(optionTime, codeTime.Format(time.RFC3339))
+	stdout := captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) })
+	if stdout != want+"\n" {
+		t.Errorf("root command output = %q, want %q", stdout, want)
+	}
+
+	rootCmd = getRootCmd()
+	_ = rootCmd.Flags().Set(optionTime, codeTime.Format(time.RFC3339))
+	_ = rootCmd.Flags().Set(optionNext, "true")
+	stdout = captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) })
+	if stdout != want+" (next "+next+")\n" {
+		t.Errorf("root command next output = %q", stdout)
+	}
+
+	rootCmd = getRootCmd()
+	stdout = captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"counter"}) })
+	hotp, _ := totp.Secret{Value: "SEED", Type: totp.TypeHOTP}.GenerateCodeWithTime(codeTime)
+	if stdout != hotp+"\n" {
+		t.Errorf("root command HOTP output = %q, want %q", stdout, hotp)
+	}
+
+	// Other collections are loaded instead, without the deleted secret
+	rootCmd = getRootCmd()
+	_ = rootCmd.Flags().Set(optionTime, codeTime.Format(time.RFC3339))
+	collectionFile.filename = filepath.Join(t.TempDir(), "other.json")
+	if stdout := captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) }); len(stdout) != 0 {
+		t.Errorf("root command output with another collection = %q", stdout)
+	}
+	collectionFile.filename = "testcollection.json"
+
+	// Agents loaded with stdin only serve clients given the same data,
+	// which here is an empty collection while the agent holds the secrets
+	defer func() {
+		collectionFile.useStdio, collectionFile.stdin, collectionFile.loader = false, nil, loadCollectionFromDefaultFile
+	}()
+	collectionFile.useStdio, collectionFile.stdin = true, []byte("{}")
+	stdinStore, _ := agentStore()
+	if !strings.HasPrefix(stdinStore, agentStdinStore) {
+		t.Errorf("agentStore() with stdin = %q", stdinStore)
+	}
+	t.Setenv(envAgentSocket, startTestAgent(t, stdinStore))
+
+	for _, data := range []string{"{}", `{"Secrets":{}}`} {
+		rootCmd = getRootCmd()
+		_ = rootCmd.Flags().Set(optionTime, codeTime.Format(time.RFC3339))
+		_ = rootCmd.Flags().Set(optionStdio, "true")
+		rootCmd.PersistentPreRun(rootCmd, nil)
+		collectionFile.stdin = []byte(data)
+
+		wantStdout := ""
+		if data == "{}" {
+			wantStdout = want + "\n"
+		}
+		if stdout := captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name1"}) }); stdout != wantStdout {
+			t.Errorf("root command output with stdin %s = %q, want %q", data, stdout, wantStdout)
+		}
+	}
+}
+
+func TestRunAgent(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	// Load error
+	if err := runAgent(&bytes.Buffer{}, agentVars{}); err == nil {
+		t.Error("runAgent() without a collection succeeded")
+	}
+
+	createTestData(t)
+
+	// The agent exits when idle and removes its socket directory
+	writer := &bytes.Buffer{}
+	if err := runAgent(writer, agentVars{idleTimeout: 50 * time.Millisecond}); err != nil {
+		t.Skip("Unix sockets are not supported:", err)
+	}
+
+	line := strings.TrimSpace(writer.String())
+	socket := strings.TrimSuffix(strings.TrimPrefix(line, envAgentSocket+"="), "; export "+envAgentSocket+";")
+	if socket == line || !strings.HasSuffix(socket, "agent.sock") {
+		t.Fatalf("runAgent() output = %q", line)
+	}
+	if _, err := os.Stat(filepath.Dir(socket)); !os.IsNotExist(err) {
+		t.Errorf("socket directory not removed: %v", err)
+	}
+
+	// Given sockets are used
+	socket = filepath.Join(t.TempDir(), "given.sock")
+	writer.Reset()
+	if err := runAgent(writer, agentVars{socket: socket, idleTimeout: 50 * time.Millisecond}); err != nil || !strings.Contains(writer.String(), socket) {
+		t.Errorf("runAgent() = %q, %v", writer.String(), err)
+	}
+
+	if err := runAgent(writer, agentVars{socket: filepath.Join(t.TempDir(), "missing", "agent.sock")}); err == nil {
+		t.Error("runAgent() with an invalid socket succeeded")
+	}
+}
+
+func TestIdleHandler(t *testing.T) {
+	handler := http.NotFoundHandler()
+	if got := newIdleHandler(handler, 0, func() {}); got == nil {
+		t.Error("newIdleHandler() without timeout returned nil")
+	}
+
+	cancelled := make(chan bool, 1)
+	idle := newIdleHandler(handler, 50*time.Millisecond, func() { cancelled <- true })
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("idle handler was not cancelled")
+	}
+
+	if _, ok := idle.(*idleHandler); !ok {
+		t.Errorf("newIdleHandler() = %T", idle)
+	}
+}
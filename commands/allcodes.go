@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	api "github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+// allCodePatterns turns the arguments of --all into glob patterns. An
+// argument without glob characters matches names starting with it.
+func allCodePatterns(args []string) []string {
+	patterns := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, `*?[\`) {
+			arg += "*"
+		}
+		patterns = append(patterns, arg)
+	}
+
+	return patterns
+}
+
+// secondsRemaining returns the whole seconds, rounded up, until the code
+// for time t changes
+func secondsRemaining(secret api.Secret, t time.Time) int {
+	remaining := durationToNextInterval(t, secret.Options().PeriodDuration())
+	return int((remaining + time.Second - 1) / time.Second)
+}
+
+// listAllCodes writes a table of the current code and seconds remaining
+// for each secret matching the arguments and tags, or the codes formatted
+// with the --format template, and copies the codes.
+// HOTP codes are not generated because that would advance their counters.
+func listAllCodes(writer io.Writer, args, tags []string, t time.Time, copier *codeCopier) error {
+	const (
+		nameTitle      = "Name"
+		codeTitle      = "Code"
+		remainingTitle = "Remaining"
+	)
+
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	secrets, err := filterSecrets(c.GetSecrets(), allCodePatterns(args), tags)
+	if err != nil {
+		printError("Error matching secrets", err)
+		return err
+	}
+
+	type row struct {
+		name, code, remaining string
+	}
+
+	var (
+		codes     []string
+		remaining time.Duration
+	)
+
+	documents := make([]codeDocument, 0, len(secrets))
+	rows := make([]row, 0, len(secrets))
+	maxNameLen := len(nameTitle)
+	maxCodeLen := len(codeTitle)
+	for _, s := range secrets {
+		r := row{name: s.Name, code: "-", remaining: "-"}
+		if s.IsHOTP() {
+			r.code = "(hotp)"
+			documents = append(documents, newCodeDocument(s, "", t))
+		} else {
+			code, err := s.GenerateCodeWithTime(t)
+			if err != nil {
+				printError("Error generating code for "+s.Name, err)
+			} else {
+				documents = append(documents, newCodeDocument(s, code, t))
+				r.code = code
+				r.remaining = fmt.Sprintf("%ds", secondsRemaining(s, t))
+
+				codes = append(codes, code)
+				if d := durationToNextInterval(t, s.Options().PeriodDuration()); len(codes) == 1 || d < remaining {
+					remaining = d
+				}
+			}
+		}
+
+		if len(r.name) > maxNameLen {
+			maxNameLen = len(r.name)
+		}
+		if len(r.code) > maxCodeLen {
+			maxCodeLen = len(r.code)
+		}
+		rows = append(rows, r)
+	}
+
+	copier.copy(codes, remaining)
+
+	if codeTemplate != nil {
+		for _, document := range documents {
+			if err := formatCode(writer, document); err != nil {
+				printError("Error formatting code", err)
+				return err
+			}
+		}
+		return nil
+	}
+
+	if structuredOutput() {
+		return outputDocument(writer, documents)
+	}
+
+	fmt.Fprintf(writer, "%-*s %-*s %s\n", maxNameLen, nameTitle, maxCodeLen, codeTitle, remainingTitle)
+	fmt.Fprintf(writer, "%s %s %s\n", titleLine(maxNameLen), titleLine(maxCodeLen), titleLine(len(remainingTitle)))
+	for _, r := range rows {
+		fmt.Fprintf(writer, "%-*s %-*s %s\n", maxNameLen, r.name, maxCodeLen, r.code, r.remaining)
+	}
+
+	return nil
+}
+
+// runAll handles the root command with the all option
+func runAll(cmd *cobra.Command, args []string, cfg runVars) {
+	if len(cfg.secret) != 0 || cfg.qr || cfg.follow {
+		fmt.Fprintf(os.Stderr, "The all option cannot be used with the secret, qrcode, or follow options.\n\n")
+		if err := cmd.Help(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		return
+	}
+
+	codeTime, err := parseTimeOption(cfg.timeString)
+	if err != nil {
+		printError("Error parsing the time option", err)
+		return
+	}
+
+	copier := newCodeCopier(cfg)
+	defer copier.finish()
+
+	// listAllCodes will output error text
+	_ = listAllCodes(os.Stdout, args, cfg.tags, codeTime.Add(cfg.forward-cfg.backward), copier)
+}
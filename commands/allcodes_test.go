@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
+)
+
+func Test_allCodePatterns(t *testing.T) {
+	got := allCodePatterns([]string{"name", "test*", "Example:[ab]"})
+	if want := []string{"name*", "test*", "Example:[ab]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("allCodePatterns() = %v, want %v", got, want)
+	}
+}
+
+func Test_secondsRemaining(t *testing.T) {
+	codeTime := time.Date(2019, 6, 23, 20, 0, 10, 500, time.UTC)
+	if got := secondsRemaining(totp.Secret{}, codeTime); got != 20 {
+		t.Errorf("secondsRemaining() = %d, want 20", got)
+	}
+	if got := secondsRemaining(totp.Secret{Period: 60}, codeTime); got != 50 {
+		t.Errorf("secondsRemaining() = %d, want 50", got)
+	}
+}
+
+func TestListAllCodes(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+	c, _ := totp.NewCollectionWithFile(collectionFile.filename)
+	_, _ = c.UpdateSecretWithOptions("name5", "SEED", totp.SecretOptions{Type: totp.TypeHOTP})
+	_, _ = c.SetMetadata("name2", totp.SecretMetadata{Tags: []string{"prod"}})
+	_ = c.Save()
+
+	codeTime := time.Date(2019, 6, 23, 20, 0, 5, 0, time.UTC)
+	code, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime)
+
+	writer := &bytes.Buffer{}
+	if err := listAllCodes(writer, []string{"name"}, nil, codeTime, nil); err != nil {
+		t.Fatal("listAllCodes() error:", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("listAllCodes() = %v", lines)
+	}
+	if want := "name0 " + code + " 25s"; lines[2] != want {
+		t.Errorf("listAllCodes() line = %q, want %q", lines[2], want)
+	}
+	if !strings.HasPrefix(lines[7], "name5 (hotp)") {
+		t.Errorf("listAllCodes() HOTP line = %q", lines[7])
+	}
+
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("name5"); s.Counter != 0 {
+		t.Error("HOTP counter advanced", s)
+	}
+
+	// Tag filter
+	writer.Reset()
+	if err := listAllCodes(writer, nil, []string{"prod"}, codeTime, nil); err != nil {
+		t.Fatal("listAllCodes() error:", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(writer.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[2], "name2 ") {
+		t.Errorf("listAllCodes() with tag = %v", lines)
+	}
+
+	// Invalid pattern
+	if err := listAllCodes(writer, []string{"["}, nil, codeTime, nil); err == nil {
+		t.Error("listAllCodes() with invalid pattern did not fail")
+	}
+
+	// Root command options
+	rootCmd := getRootCmd()
+	_ = rootCmd.Flags().Set(optionAll, "true")
+	_ = rootCmd.RunE(rootCmd, []string{"test"})
+
+	_ = rootCmd.Flags().Set(optionTime, "noon")
+	_ = rootCmd.RunE(rootCmd, []string{})
+
+	_ = rootCmd.Flags().Set(optionFollow, "true")
+	_ = rootCmd.RunE(rootCmd, []string{})
+
+	// Tag without all or interactive
+	rootCmd = getRootCmd()
+	_ = rootCmd.Flags().Set(optionTag, "prod")
+	_ = rootCmd.RunE(rootCmd, []string{"name2"})
+
+	// Missing collection
+	os.Remove(collectionFile.filename)
+	if err := listAllCodes(writer, nil, nil, codeTime, nil); err == nil {
+		t.Error("listAllCodes() with missing collection did not fail")
+	}
+}
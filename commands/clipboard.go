@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const envClipboard = "TOTP_CLIPBOARD"
+
+// clipboardFunc places text on the clipboard. Empty text clears it.
+type clipboardFunc func(text string) error
+
+// copyOSC52 writes text as an OSC 52 sequence, which terminals that
+// support it place on the system clipboard
+func copyOSC52(writer io.Writer, text string) {
+	fmt.Fprintf(writer, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+}
+
+// osc52Clipboard returns a clipboard that writes OSC 52 sequences to the
+// terminal. It works over SSH and in terminal multiplexers that pass the
+// sequence on.
+func osc52Clipboard(terminal io.Writer) clipboardFunc {
+	return func(text string) error {
+		copyOSC52(terminal, text)
+		return nil
+	}
+}
+
+// commandClipboard returns a clipboard that runs a command, such as
+// "xclip -selection clipboard", with the text as its input
+func commandClipboard(command string) clipboardFunc {
+	return func(text string) error {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return errors.New("empty clipboard command")
+		}
+
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		cmd.Stderr = os.Stderr
+
+		return cmd.Run()
+	}
+}
+
+// newClipboard returns the clipboard command set with TOTP_CLIPBOARD or,
+// if not set, OSC 52 written to the terminal
+func newClipboard(terminal io.Writer) clipboardFunc {
+	if command := os.Getenv(envClipboard); len(command) != 0 {
+		return commandClipboard(command)
+	}
+
+	return osc52Clipboard(terminal)
+}
+
+// codeCopier copies the codes a command outputs to the clipboard and, when
+// clear is set, clears them once they expire. The methods of a nil
+// codeCopier do nothing, so commands call them whether or not --copy was
+// given.
+type codeCopier struct {
+	clipboard clipboardFunc
+	clear     bool
+	expires   time.Time
+	clock     clock
+	close     func()
+}
+
+// newCodeCopier returns a codeCopier for the copy options, or nil if codes
+// are not copied. OSC 52 sequences are written to the controlling terminal
+// so they are not mixed with the output.
+func newCodeCopier(cfg runVars) *codeCopier {
+	if !cfg.copy {
+		return nil
+	}
+
+	var terminal io.Writer = os.Stderr
+	closeTerminal := func() {}
+	if tty, err := openTerminal(); err == nil {
+		terminal = tty
+		closeTerminal = func() { tty.Close() }
+	}
+
+	return &codeCopier{
+		clipboard: newClipboard(terminal),
+		clear:     cfg.clearCopy,
+		clock:     commandClock,
+		close:     closeTerminal,
+	}
+}
+
+// copy places the codes, one per line, on the clipboard. They are cleared
+// by finish after remaining, the time until the first of them expires.
+func (c *codeCopier) copy(codes []string, remaining time.Duration) {
+	if c == nil || len(codes) == 0 {
+		return
+	}
+
+	if err := c.clipboard(strings.Join(codes, "\n")); err != nil {
+		printError("Error copying to the clipboard", err)
+		return
+	}
+
+	c.expires = c.clock.Now().Add(remaining)
+}
+
+// wrap returns generate with each code it generates copied
+func (c *codeCopier) wrap(generate codeFunc) codeFunc {
+	if c == nil {
+		return generate
+	}
+
+	return func(t time.Time) (codeDocument, error) {
+		document, err := generate(t)
+		if err == nil {
+			c.copy([]string{document.Code}, document.remaining(t))
+		}
+
+		return document, err
+	}
+}
+
+// finish waits for the copied codes to expire and clears the clipboard if
+// clearing was requested
+func (c *codeCopier) finish() {
+	if c == nil {
+		return
+	}
+	defer c.close()
+
+	if !c.clear || c.expires.IsZero() {
+		return
+	}
+
+	wait := c.expires.Sub(c.clock.Now())
+	fmt.Fprintf(os.Stderr, "Clearing the clipboard in %ds\n", int((wait+time.Second-1)/time.Second))
+	c.clock.Sleep(wait)
+
+	if err := c.clipboard(""); err != nil {
+		printError("Error clearing the clipboard", err)
+	}
+}
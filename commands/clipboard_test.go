@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
+)
+
+// testCopier returns a codeCopier recording what is placed on the clipboard
+// and its clock, which records how long it waits to clear it
+func testCopier(clear bool) (*codeCopier, *[]string, *testClock) {
+	var copied []string
+	clock := &testClock{now: time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)}
+
+	return &codeCopier{
+		clipboard: func(text string) error {
+			copied = append(copied, text)
+			return nil
+		},
+		clear: clear,
+		clock: clock,
+		close: func() {},
+	}, &copied, clock
+}
+
+func Test_copyOSC52(t *testing.T) {
+	writer := &bytes.Buffer{}
+	copyOSC52(writer, "123456")
+	if got := writer.String(); got != "\x1b]52;c;MTIzNDU2\a" {
+		t.Errorf("copyOSC52() = %q", got)
+	}
+}
+
+func TestClipboards(t *testing.T) {
+	// OSC 52 is used without a clipboard command
+	t.Setenv(envClipboard, "")
+	writer := &bytes.Buffer{}
+	if err := newClipboard(writer)("123456"); err != nil || writer.String() != "\x1b]52;c;MTIzNDU2\a" {
+		t.Errorf("newClipboard() wrote %q, %v", writer.String(), err)
+	}
+
+	// Clipboard commands get the text as input
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available:", err)
+	}
+
+	output := filepath.Join(t.TempDir(), "clipboard")
+	script := filepath.Join(t.TempDir(), "copy.sh")
+	_ = os.WriteFile(script, []byte("#!/bin/sh\ncat > \"$1\"\n"), 0700)
+	t.Setenv(envClipboard, script+" "+output)
+
+	writer.Reset()
+	if err := newClipboard(writer)("123456"); err != nil || writer.Len() != 0 {
+		t.Errorf("newClipboard() command error = %v, wrote %q", err, writer.String())
+	}
+	if data, err := os.ReadFile(output); err != nil || string(data) != "123456" {
+		t.Errorf("clipboard command input = %q, %v", data, err)
+	}
+
+	if err := commandClipboard(" ")("123456"); err == nil {
+		t.Error("commandClipboard() with empty command succeeded")
+	}
+	if err := commandClipboard(filepath.Join(t.TempDir(), "missing"))("123456"); err == nil {
+		t.Error("commandClipboard() with missing command succeeded")
+	}
+}
+
+func TestCodeCopier(t *testing.T) {
+	// nil copiers do nothing
+	var copier *codeCopier
+	copier.copy([]string{"123456"}, time.Second)
+	copier.finish()
+	if document, err := copier.wrap(secretCodeFunc(totp.Secret{Value: "SEED"}))(time.Now()); err != nil || len(document.Code) != 6 {
+		t.Errorf("nil wrap() = %v, %v", document, err)
+	}
+	if newCodeCopier(runVars{}) != nil {
+		t.Error("newCodeCopier() without copy option is not nil")
+	}
+
+	// Codes are copied and cleared when they expire
+	copier, copied, clock := testCopier(true)
+	copier.copy([]string{"123456", "654321"}, 20*time.Second)
+	copier.finish()
+	if strings.Join(*copied, "|") != "123456\n654321|" || clock.slept != 20*time.Second {
+		t.Errorf("copied %q, waited %v", *copied, clock.slept)
+	}
+
+	// Nothing is cleared unless asked, or if nothing was copied
+	copier, copied, _ = testCopier(false)
+	copier.copy([]string{"123456"}, 20*time.Second)
+	copier.finish()
+	copier.copy(nil, time.Second)
+	if len(*copied) != 1 {
+		t.Errorf("copied %q", *copied)
+	}
+
+	copier, copied, _ = testCopier(true)
+	copier.finish()
+	if len(*copied) != 0 {
+		t.Errorf("copied %q", *copied)
+	}
+
+	// Wrapped code functions copy each code
+	copier, copied, clock = testCopier(true)
+	codeTime := time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)
+	want, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime)
+	generate := copier.wrap(secretCodeFunc(totp.Secret{Value: "SEED"}))
+	if document, err := generate(codeTime); err != nil || document.Code != want || len(*copied) != 1 || (*copied)[0] != want {
+		t.Errorf("wrap() = %v, %v, copied %q", document, err, *copied)
+	}
+	if until := copier.expires.Sub(clock.Now()); until != 20*time.Second {
+		t.Errorf("expires in %v", until)
+	}
+	if _, err := copier.wrap(secretCodeFunc(totp.Secret{Value: "invalidseed"}))(codeTime); err == nil || len(*copied) != 1 {
+		t.Errorf("wrap() with invalid seed error = %v, copied %q", err, *copied)
+	}
+}
+
+func TestCopyCodes(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+	codeTime := time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)
+	seed, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime)
+	seedseed, _ := totp.Secret{Value: "SEEDSEED"}.GenerateCodeWithTime(codeTime)
+
+	// All codes listed are copied
+	copier, copied, _ := testCopier(false)
+	if err := listAllCodes(&bytes.Buffer{}, []string{"name[12]"}, nil, codeTime, copier); err != nil || len(*copied) != 1 || (*copied)[0] != seed+"\n"+seedseed {
+		t.Errorf("listAllCodes() copied %q, %v", *copied, err)
+	}
+
+	// HOTP codes are cleared after the default period
+	c, _ := collectionFile.loader()
+	_, _ = c.UpdateSecretWithOptions("counter", "SEED", totp.SecretOptions{Type: totp.TypeHOTP})
+	copier, copied, clock := testCopier(true)
+	if err := generateHOTPCode(&bytes.Buffer{}, c, "counter", copier); err != nil || len(*copied) != 1 {
+		t.Errorf("generateHOTPCode() copied %q, %v", *copied, err)
+	}
+	copier.finish()
+	if clock.slept != 30*time.Second || len(*copied) != 2 || (*copied)[1] != "" {
+		t.Errorf("copied %q, waited %v", *copied, clock.slept)
+	}
+
+	// The clear option needs the copy option
+	rootCmd := getRootCmd()
+	_ = rootCmd.Flags().Set(optionClear, "true")
+	if stdout := captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) }); !strings.HasPrefix(stdout, "TOTP Generator") {
+		t.Errorf("root command with clear option output %q, want help", stdout)
+	}
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"time"
+
+	api "github.com/arcanericky/totp"
+)
+
+// clock is the time source of the commands. It adds waiting to the
+// library's Clock so following codes, waiting for fresh codes, and clearing
+// the clipboard can be tested without waiting.
+type clock interface {
+	api.Clock
+	Sleep(time.Duration)
+}
+
+// systemClock is the clock of the system time
+type systemClock struct {
+	api.SystemClock
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// commandClock is the clock commands generate codes and wait with
+var commandClock clock = systemClock{}
+
+// offsetClock is a clock moved by an offset, such as the offset of the
+// local clock from an NTP server
+type offsetClock struct {
+	clock
+	offset time.Duration
+}
+
+func (c offsetClock) Now() time.Time {
+	return c.clock.Now().Add(c.offset)
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+// testClock is a clock whose time only moves when it sleeps
+type testClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Sleep(d time.Duration) {
+	if d > 0 {
+		c.now = c.now.Add(d)
+		c.slept += d
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	start := time.Now()
+	systemClock{}.Sleep(time.Millisecond)
+	if now := (systemClock{}).Now(); now.Sub(start) < time.Millisecond {
+		t.Errorf("Now() = %v after sleeping from %v", now, start)
+	}
+}
+
+func TestCallOnIntervalClock(t *testing.T) {
+	start := time.Date(2019, 6, 23, 20, 0, 0, 0, time.UTC)
+	clock := &testClock{now: start}
+
+	// Intervals missed while suspended are skipped rather than caught up
+	var calls []time.Duration
+	callOnInterval(clock, 3*time.Minute, 30*time.Second, func() bool {
+		calls = append(calls, clock.Now().Sub(start))
+		if len(calls) == 2 {
+			clock.now = clock.now.Add(95 * time.Second)
+		}
+		return false
+	})
+
+	want := []time.Duration{0, 30 * time.Second, 150 * time.Second}
+	if len(calls) != len(want) {
+		t.Fatalf("calls at %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("calls at %v, want %v", calls, want)
+		}
+	}
+}
@@ -1,11 +1,33 @@
 package commands
 
 import (
+	"os"
 	"testing"
 
 	"github.com/arcanericky/totp"
 )
 
+func TestMain(m *testing.M) {
+	// codes must come from the test collection, not a running agent
+	os.Unsetenv(envAgentSocket)
+
+	code := m.Run()
+
+	// commands that modify the test collection leave its lock file behind
+	os.Remove("testcollection.json.lock")
+	removeTestBackups()
+
+	os.Exit(code)
+}
+
+// removeTestBackups removes the backups of the test collection
+func removeTestBackups() {
+	backups, _ := totp.Backups("testcollection.json")
+	for _, b := range backups {
+		os.Remove(b.Filename)
+	}
+}
+
 type secretItem struct {
 	name  string
 	value string
@@ -16,6 +16,15 @@ func getConfigCmd(rootCmd *cobra.Command) *cobra.Command {
 	cobraCmd.AddCommand(getConfigUpdateCmd(rootCmd))
 	cobraCmd.AddCommand(getConfigDeleteCmd())
 	cobraCmd.AddCommand(getConfigResetCmd())
+	cobraCmd.AddCommand(getConfigEncryptCmd())
+	cobraCmd.AddCommand(getConfigDecryptCmd())
+	cobraCmd.AddCommand(getConfigCounterCmd(rootCmd))
+	cobraCmd.AddCommand(getConfigImportCmd(rootCmd))
+	cobraCmd.AddCommand(getConfigTransferCmd(rootCmd))
+	cobraCmd.AddCommand(getConfigExportCmd(rootCmd))
+	cobraCmd.AddCommand(getConfigBackupsCmd())
+	cobraCmd.AddCommand(getConfigRestoreCmd(rootCmd))
+	cobraCmd.AddCommand(getConfigMigrateCmd())
 
 	return cobraCmd
 }
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+// listBackups writes a table of the backups of the collection file, newest
+// first
+func listBackups(writer io.Writer, filename string) error {
+	const (
+		timeFormat  = "Jan _2 2006 15:04:05"
+		dateTitle   = "Date"
+		backupTitle = "Backup"
+	)
+
+	backups, err := totp.Backups(filename)
+	if err != nil {
+		printError("Error listing backups", err)
+		return err
+	}
+
+	if structuredOutput() {
+		documents := make([]backupDocument, 0, len(backups))
+		for _, b := range backups {
+			documents = append(documents, backupDocument{Time: b.Time, Backup: filepath.Base(b.Filename)})
+		}
+
+		return outputDocument(writer, documents)
+	}
+
+	if len(backups) == 0 {
+		fmt.Fprintf(os.Stderr, "No backups of %s\n", filename)
+		return nil
+	}
+
+	maxDateLen := len(timeFormat)
+	fmt.Fprintf(writer, "%-*s %s\n", maxDateLen, dateTitle, backupTitle)
+	fmt.Fprintf(writer, "%s %s\n", titleLine(maxDateLen), titleLine(len(filepath.Base(backups[0].Filename))))
+	for _, b := range backups {
+		fmt.Fprintf(writer, "%s %s\n", b.Time.Local().Format(timeFormat), filepath.Base(b.Filename))
+	}
+
+	return nil
+}
+
+func getConfigBackupsListCmd() *cobra.Command {
+	var cobraCmd = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls", "l"},
+		Short:   "List collection backups",
+		Long:    `List collection backups, newest first`,
+		Run: func(_ *cobra.Command, _ []string) {
+			filename, err := collectionFilePath()
+			if err != nil {
+				printError("Error listing backups", err)
+				return
+			}
+
+			_ = listBackups(os.Stdout, filename)
+		},
+	}
+
+	return cobraCmd
+}
+
+func getConfigBackupsCmd() *cobra.Command {
+	var cobraCmd = &cobra.Command{
+		Use:   "backups",
+		Short: "Manage collection backups",
+		Long: `Manage collection backups
+
+Every save of the collection keeps a copy of the previous file next to it.
+The number of backups kept is set with the ` + envBackups + ` environment
+variable, which defaults to 5. Zero disables backups.`,
+	}
+
+	cobraCmd.AddCommand(getConfigBackupsListCmd())
+
+	return cobraCmd
+}
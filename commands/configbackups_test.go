@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestConfigBackupsList(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	collectionFile.backups = 2
+	defer func() { collectionFile.backups = 0 }()
+	defer removeTestBackups()
+	defer os.Remove(collectionFile.filename)
+
+	// no backups yet
+	removeTestBackups()
+	os.Remove(collectionFile.filename)
+	writer := &bytes.Buffer{}
+	if err := listBackups(writer, collectionFile.filename); err != nil || writer.Len() != 0 {
+		t.Errorf("listBackups() = %q, %v", writer.String(), err)
+	}
+
+	// each save that changes an existing collection keeps a backup
+	createTestData(t)
+	for i := 0; i < 3; i++ {
+		c, _ := collectionFile.loader()
+		_, _ = c.UpdateSecret("backup"+strconv.Itoa(i), "SEED")
+		if err := c.Save(); err != nil {
+			t.Fatal("Save() error:", err)
+		}
+	}
+
+	if err := listBackups(writer, collectionFile.filename); err != nil {
+		t.Fatal("listBackups() error:", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[2], " testcollection.json.") {
+		t.Errorf("listBackups() = %q", lines)
+	}
+
+	configBackupsCmd := getConfigBackupsCmd()
+	configBackupsListCmd, _, _ := configBackupsCmd.Find([]string{"list"})
+	configBackupsListCmd.Run(configBackupsListCmd, []string{})
+
+	if err := listBackups(writer, "nosuchdir/testcollection.json"); err == nil {
+		t.Error("listBackups() of missing directory did not fail")
+	}
+}
+
+func TestSetBackupCount(t *testing.T) {
+	defer func() { collectionFile.backups = 0 }()
+
+	os.Setenv(envBackups, "")
+	setBackupCount()
+	if collectionFile.backups != totp.DefaultBackupCount {
+		t.Errorf("backups = %d, want %d", collectionFile.backups, totp.DefaultBackupCount)
+	}
+
+	os.Setenv(envBackups, "0")
+	setBackupCount()
+	if collectionFile.backups != 0 {
+		t.Errorf("backups = %d, want 0", collectionFile.backups)
+	}
+
+	os.Setenv(envBackups, "many")
+	setBackupCount()
+	if collectionFile.backups != totp.DefaultBackupCount {
+		t.Errorf("backups = %d, want %d", collectionFile.backups, totp.DefaultBackupCount)
+	}
+
+	os.Unsetenv(envBackups)
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	api "github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+func showCounter(name string) {
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return
+	}
+
+	secret, err := c.GetSecret(name)
+	if err != nil {
+		printError("Error getting secret", err)
+		return
+	}
+
+	if !secret.IsHOTP() {
+		printError("Error getting counter", api.ErrNotHOTP)
+		return
+	}
+
+	if structuredOutput() {
+		if err := outputDocument(os.Stdout, counterDocument{Name: name, Counter: secret.Counter}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return
+	}
+
+	fmt.Println(secret.Counter)
+}
+
+func setCounter(name, value string) {
+	counter, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		printError("Error parsing counter", err)
+		return
+	}
+
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return
+	}
+	defer unlock()
+
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return
+	}
+
+	if _, err := c.SetCounter(name, counter); err != nil {
+		printError("Error setting counter", err)
+		return
+	}
+
+	if err := c.Save(); err != nil {
+		printError("Error saving settings", err)
+		return
+	}
+
+	document := resultDocument{Result: "counter set", Name: name, Counter: &counter}
+	if err := printResult(document, "Set counter for secret %s to %d\n", name, counter); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+}
+
+func resyncCounter(name string, codes []string, window uint64) {
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return
+	}
+	defer unlock()
+
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return
+	}
+
+	secret, err := c.ResyncCounter(name, codes, window)
+	if err != nil {
+		printError("Error resynchronizing counter", err)
+		return
+	}
+
+	if err := c.Save(); err != nil {
+		printError("Error saving settings", err)
+		return
+	}
+
+	document := resultDocument{Result: "counter resynchronized", Name: name, Counter: &secret.Counter}
+	if err := printResult(document, "Resynchronized counter for secret %s to %d\n", name, secret.Counter); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+}
+
+func getConfigCounterCmd(rootCmd *cobra.Command) *cobra.Command {
+	var (
+		resync   []string
+		window   uint64
+		cobraCmd = &cobra.Command{
+			Use:   "counter",
+			Short: "Show, set, or resynchronize an HOTP counter",
+			Long: `Show, set, or resynchronize an HOTP counter
+
+With only a secret name, the counter used for the next code is shown. Giving
+a counter value sets it. The --resync option takes one or more consecutive
+codes from the token and searches ahead of the current counter for them,
+setting the counter to follow the last code.`,
+			ValidArgsFunction: validArgs,
+			Run: func(_ *cobra.Command, args []string) {
+				switch {
+				case len(args) == 1 && len(resync) != 0:
+					resyncCounter(args[0], resync, window)
+				case len(args) == 1:
+					showCounter(args[0])
+				case len(args) == 2 && len(resync) == 0:
+					setCounter(args[0], args[1])
+				default:
+					printError("Error getting counter", errors.New("a secret name and optionally a counter value or --resync codes are required"))
+				}
+			},
+		}
+	)
+
+	cobraCmd.Flags().BoolP(optionStdio, "", false, "load with stdin, save with stdout")
+	cobraCmd.Flags().StringSliceVarP(&resync, "resync", "", nil, "consecutive codes to resynchronize with (ex. \"123456,654321\")")
+	cobraCmd.Flags().Uint64VarP(&window, "window", "", api.DefaultResyncWindow, "number of counter values to search when resynchronizing")
+	cobraCmd.SetUsageTemplate(strings.Replace(rootCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [secret name] [counter]", 1))
+
+	return cobraCmd
+}
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"os"
+	"testing"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestConfigCounter(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+
+	createTestData(t)
+	configUpdateCmd := getConfigUpdateCmd(getRootCmd())
+	_ = configUpdateCmd.Flags().Set(optionType, "hotp")
+	configUpdateCmd.Run(configUpdateCmd, []string{"hotpname", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"})
+
+	configCounterCmd := getConfigCounterCmd(getRootCmd())
+
+	// Show counter
+	configCounterCmd.Run(nil, []string{"hotpname"})
+
+	// Set counter
+	configCounterCmd.Run(nil, []string{"hotpname", "1"})
+	c, _ := totp.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("hotpname"); s.Counter != 1 {
+		t.Error("Counter not set", s)
+	}
+
+	// Resync counter
+	_ = configCounterCmd.Flags().Set("resync", "359152,969429")
+	configCounterCmd.Run(nil, []string{"hotpname"})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("hotpname"); s.Counter != 4 {
+		t.Error("Counter not resynchronized", s)
+	}
+
+	// Resync failure
+	_ = configCounterCmd.Flags().Set("resync", "000000")
+	configCounterCmd.Run(nil, []string{"hotpname"})
+
+	// Resync with counter value
+	configCounterCmd.Run(nil, []string{"hotpname", "1"})
+	configCounterCmd = getConfigCounterCmd(getRootCmd())
+
+	// Not an HOTP secret
+	configCounterCmd.Run(nil, []string{"name0"})
+	configCounterCmd.Run(nil, []string{"name0", "1"})
+
+	// Invalid counter and secret name
+	configCounterCmd.Run(nil, []string{"hotpname", "invalid"})
+	configCounterCmd.Run(nil, []string{"invalidname"})
+
+	// No parameters passed
+	configCounterCmd.Run(nil, []string{})
+
+	// No collection file
+	os.Remove(collectionFile.filename)
+	configCounterCmd.Run(nil, []string{"hotpname"})
+	configCounterCmd.Run(nil, []string{"hotpname", "1"})
+	_ = configCounterCmd.Flags().Set("resync", "359152")
+	configCounterCmd.Run(nil, []string{"hotpname"})
+}
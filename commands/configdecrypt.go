@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func decryptCollection() {
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return
+	}
+	defer unlock()
+
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return
+	}
+
+	if !c.Encrypted() {
+		printError("Error decrypting collection", errors.New("the collection is not encrypted"))
+		return
+	}
+
+	c.SetPassphrase("")
+
+	if err := c.Save(); err != nil {
+		printError("Error saving settings", err)
+		return
+	}
+
+	if err := printResult(resultDocument{Result: "decrypted"}, "Decrypted collection\n"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+}
+
+func getConfigDecryptCmd() *cobra.Command {
+	var cobraCmd = &cobra.Command{
+		Use:   "decrypt",
+		Short: "Remove passphrase encryption from the collection",
+		Long:  `Remove passphrase encryption from the collection`,
+		Run: func(_ *cobra.Command, _ []string) {
+			decryptCollection()
+		},
+	}
+
+	cobraCmd.Flags().BoolP(optionStdio, "", false, "load with stdin, save with stdout")
+
+	return cobraCmd
+}
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"os"
+	"testing"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestConfigDecrypt(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	totp.SetPassphraseFunc(getPassphrase)
+	defer os.Unsetenv(envPassphrase)
+
+	createTestData(t)
+
+	configDecryptCmd := getConfigDecryptCmd()
+
+	// Collection not encrypted
+	configDecryptCmd.Run(nil, []string{})
+
+	os.Setenv(envPassphrase, "testpassphrase")
+	encryptCollection()
+	configDecryptCmd.Run(nil, []string{})
+
+	os.Unsetenv(envPassphrase)
+	c, err := totp.NewCollectionWithFile(collectionFile.filename)
+	if err != nil {
+		t.Fatal("Could not load decrypted collection:", err)
+	}
+
+	if c.Encrypted() {
+		t.Error("Collection still encrypted")
+	}
+
+	// No collection file
+	os.Remove(collectionFile.filename)
+	configDecryptCmd.Run(nil, []string{})
+}
@@ -2,73 +2,140 @@ package commands
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	api "github.com/arcanericky/totp"
 	"github.com/spf13/cobra"
 )
 
-func deleteSecret(name string) {
-	s, err := collectionFile.loader()
+// selectSecretsToDelete returns the names of the secrets matching the names
+// or glob patterns and the tags. Names without glob characters must exist.
+func selectSecretsToDelete(c *api.Collection, args, tags []string) ([]string, error) {
+	if len(args) == 0 && len(tags) == 0 {
+		return nil, errors.New("must provide a secret name, pattern, or tag to delete")
+	}
+
+	for _, arg := range args {
+		if strings.ContainsAny(arg, `*?[\`) {
+			continue
+		}
+		if _, err := c.GetSecret(arg); err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+	}
+
+	secrets, err := filterSecrets(c.GetSecrets(), args, tags)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading settings:", err)
-		return
+		return nil, err
 	}
 
-	if _, err := s.DeleteSecret(name); err != nil {
-		fmt.Fprintln(os.Stderr, "Error deleting secret:", err)
-		return
+	if len(secrets) == 0 {
+		return nil, errors.New("no secrets matched")
+	}
+
+	names := make([]string, 0, len(secrets))
+	for _, s := range secrets {
+		names = append(names, s.Name)
 	}
 
-	if err := s.Save(); err != nil {
-		fmt.Fprintln(os.Stderr, "Error saving settings:", err)
+	return names, nil
+}
+
+func deleteSecrets(c *api.Collection, names []string) {
+	for _, name := range names {
+		if _, err := c.DeleteSecret(name); err != nil {
+			printError("Error deleting secret", err)
+			return
+		}
+	}
+
+	if err := c.Save(); err != nil {
+		printError("Error saving settings", err)
 		return
 	}
 
-	if _, err := printResultf("Deleted secret %s\n", name); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	if structuredOutput() {
+		if err := printResult(resultDocument{Result: "deleted", Names: names}, ""); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		return
 	}
+
+	for _, name := range names {
+		if _, err := printResultf("Deleted secret %s\n", name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+	}
 }
 
 func getConfigDeleteCmd() *cobra.Command {
 	var (
 		confirmAll bool
+		tags       []string
 		cobraCmd   = &cobra.Command{
-			Use:               "delete",
-			Aliases:           []string{"remove", "erase", "rm", "del"},
-			Short:             "Delete a secret",
-			Long:              `Delete a secret`,
-			ValidArgsFunction: validArgs,
+			Use:     "delete",
+			Aliases: []string{"remove", "erase", "rm", "del"},
+			Short:   "Delete secrets",
+			Long: `Delete secrets
+
+Deletes the secrets with the names or names matching the glob patterns given
+and with all of the --tag tags.`,
+			ValidArgsFunction: validPatternArgs,
 			Run: func(_ *cobra.Command, args []string) {
-				if len(args) != 1 {
-					fmt.Fprintln(os.Stderr, "Must provide a secret name to delete.")
+				unlock, err := lockCollection()
+				if err != nil {
+					// lockCollection will output error text
 					return
 				}
+				defer unlock()
 
-				secretName := args[0]
+				c, err := collectionFile.loader()
+				if err != nil {
+					printError("Error loading settings", err)
+					return
+				}
+
+				names, err := selectSecretsToDelete(c, args, tags)
+				if err != nil {
+					printError("Error selecting secrets", err)
+					return
+				}
 
 				if !confirmAll {
-					confirm, err := userConfirm(bufio.NewReader(os.Stdin),
-						fmt.Sprintf("This will delete secret %s.", secretName))
+					prompt := fmt.Sprintf("This will delete secret %s.", names[0])
+					if len(names) > 1 {
+						prompt = fmt.Sprintf("This will delete %d secrets: %s.", len(names), strings.Join(names, ", "))
+					}
+
+					confirm, err := userConfirm(bufio.NewReader(os.Stdin), prompt)
 					if err != nil {
-						fmt.Fprintln(os.Stderr, "Error getting response:", err)
+						printError("Error getting response", err)
 						return
 					}
 
 					if !confirm {
-						fmt.Println("Skipping delete")
+						if structuredOutput() {
+							_ = outputDocument(os.Stdout, resultDocument{Result: "skipped", Names: names})
+						} else {
+							fmt.Println("Skipping delete")
+						}
 						return
 					}
 				}
 
-				deleteSecret(secretName)
+				deleteSecrets(c, names)
 			},
 		}
 	)
 
 	cobraCmd.Flags().BoolP(optionStdio, "", false, "load with stdin, save with stdout")
 	cobraCmd.Flags().BoolVarP(&confirmAll, optionYes, "y", false, "confirm all prompts")
+	addTagFilterFlag(cobraCmd, &tags)
+	cobraCmd.SetUsageTemplate(strings.Replace(cobraCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [name | pattern]... [--tag tag]...", 1))
 
 	return cobraCmd
 }
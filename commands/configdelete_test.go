@@ -36,7 +36,55 @@ func TestConfigDelete(t *testing.T) {
 		t.Error("Secret not deleted")
 	}
 
+	// Pattern and tag delete
+	_, _ = c.SetMetadata("name1", totp.SecretMetadata{Tags: []string{"prod"}})
+	_ = c.Save()
+	configDeleteCmd = getConfigDeleteCmd()
+	_ = configDeleteCmd.Flags().Set(optionYes, "true")
+	_ = configDeleteCmd.Flags().Set(optionTag, "prod")
+	configDeleteCmd.Run(nil, []string{"name*"})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if _, err := c.GetSecret("name1"); err == nil {
+		t.Error("Tagged secret not deleted")
+	}
+	if _, err := c.GetSecret("name0"); err != nil {
+		t.Error("Untagged secret deleted")
+	}
+
+	// Nothing matched
+	configDeleteCmd.Run(nil, []string{"test*"})
+
+	configDeleteCmd = getConfigDeleteCmd()
+	_ = configDeleteCmd.Flags().Set(optionYes, "true")
+	configDeleteCmd.Run(nil, []string{"name*"})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if len(c.GetSecrets()) != 1 {
+		t.Error("Secrets matching pattern not deleted", c.GetSecrets())
+	}
+
+	// Invalid pattern
+	configDeleteCmd.Run(nil, []string{"["})
+
 	// No collections file
 	os.Remove(collectionFile.filename)
-	deleteSecret(secretList[3].name)
+	configDeleteCmd.Run(nil, []string{secretList[3].name})
+}
+
+func Test_selectSecretsToDelete(t *testing.T) {
+	c := totp.NewCollection()
+	_, _ = c.UpdateSecret("name0", "SEED")
+	_, _ = c.UpdateSecret("name1", "SEED")
+
+	if _, err := selectSecretsToDelete(c, nil, nil); err == nil {
+		t.Error("selectSecretsToDelete() with no arguments did not fail")
+	}
+
+	if _, err := selectSecretsToDelete(c, []string{"name0", "secret"}, nil); err == nil {
+		t.Error("selectSecretsToDelete() with missing name did not fail")
+	}
+
+	names, err := selectSecretsToDelete(c, []string{"name1", "name0"}, nil)
+	if err != nil || len(names) != 2 || names[0] != "name0" {
+		t.Errorf("selectSecretsToDelete() = %v, %v", names, err)
+	}
 }
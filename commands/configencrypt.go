@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func encryptCollection() {
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return
+	}
+	defer unlock()
+
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return
+	}
+
+	passphrase, err := getNewPassphrase()
+	if err != nil {
+		printError("Error getting passphrase", err)
+		return
+	}
+
+	if len(passphrase) == 0 {
+		printError("Error encrypting collection", errors.New("the passphrase cannot be empty"))
+		return
+	}
+
+	c.SetPassphrase(passphrase)
+
+	if err := c.Save(); err != nil {
+		printError("Error saving settings", err)
+		return
+	}
+
+	if err := printResult(resultDocument{Result: "encrypted"}, "Encrypted collection\n"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+}
+
+func getConfigEncryptCmd() *cobra.Command {
+	var cobraCmd = &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt the collection with a passphrase",
+		Long: `Encrypt the collection with a passphrase
+
+The passphrase is read from the ` + envPassphrase + ` environment variable or
+prompted for on the terminal. Encrypting an encrypted collection changes
+its passphrase.`,
+		Run: func(_ *cobra.Command, _ []string) {
+			encryptCollection()
+		},
+	}
+
+	cobraCmd.Flags().BoolP(optionStdio, "", false, "load with stdin, save with stdout")
+
+	return cobraCmd
+}
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"os"
+	"testing"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestConfigEncrypt(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	totp.SetPassphraseFunc(getPassphrase)
+	defer os.Unsetenv(envPassphrase)
+
+	createTestData(t)
+
+	configEncryptCmd := getConfigEncryptCmd()
+
+	// Encrypt with passphrase from environment
+	os.Setenv(envPassphrase, "testpassphrase")
+	configEncryptCmd.Run(nil, []string{})
+
+	c, err := totp.NewCollectionWithFile(collectionFile.filename)
+	if err != nil {
+		t.Fatal("Could not load encrypted collection:", err)
+	}
+
+	if !c.Encrypted() {
+		t.Error("Collection not encrypted")
+	}
+
+	// Wrong passphrase
+	os.Setenv(envPassphrase, "wrongpassphrase")
+	configEncryptCmd.Run(nil, []string{})
+
+	// Add with wrong passphrase must not overwrite the collection
+	updateSecret("newsecret", "seed", secretOptionChanges{}, secretMetadataChanges{})
+	os.Setenv(envPassphrase, "testpassphrase")
+	if _, err := totp.NewCollectionWithFile(collectionFile.filename); err != nil {
+		t.Error("Encrypted collection overwritten:", err)
+	}
+
+	// Empty passphrase
+	os.Unsetenv(envPassphrase)
+	savedPassphraseReader := passphraseReader
+	passphraseReader = func(string) (string, error) { return "", nil }
+	os.Remove(collectionFile.filename)
+	createTestData(t)
+	configEncryptCmd.Run(nil, []string{})
+	passphraseReader = savedPassphraseReader
+
+	// No collection file
+	os.Remove(collectionFile.filename)
+	configEncryptCmd.Run(nil, []string{})
+}
+
+func Test_getNewPassphrase(t *testing.T) {
+	os.Unsetenv(envPassphrase)
+	savedPassphraseReader := passphraseReader
+	defer func() { passphraseReader = savedPassphraseReader }()
+
+	responses := []string{}
+	passphraseReader = func(string) (string, error) {
+		r := responses[0]
+		responses = responses[1:]
+		return r, nil
+	}
+
+	responses = []string{"passphrase", "passphrase"}
+	if got, err := getNewPassphrase(); err != nil || got != "passphrase" {
+		t.Errorf("getNewPassphrase() = %s, %v", got, err)
+	}
+
+	responses = []string{"passphrase", "mismatch"}
+	if _, err := getNewPassphrase(); err != errPassphraseMismatch {
+		t.Errorf("getNewPassphrase() error = %v, want %v", err, errPassphraseMismatch)
+	}
+
+	responses = []string{"passphrase"}
+	if got, err := getPassphrase(); err != nil || got != "passphrase" {
+		t.Errorf("getPassphrase() = %s, %v", got, err)
+	}
+}
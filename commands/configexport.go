@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	api "github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+// exportSecrets writes the secrets matching the patterns and tags in the
+// given format to the output file or, if none is given, to writer
+func exportSecrets(writer io.Writer, patterns, tags []string, format, outputFile string) error {
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	secrets, err := filterSecrets(c.GetSecrets(), patterns, tags)
+	if err != nil {
+		printError("Error matching secrets", err)
+		return err
+	}
+
+	// export to a buffer first so a failed export does not leave a
+	// partial file behind
+	var buf bytes.Buffer
+	if err := api.Export(&buf, format, secrets); err != nil {
+		printError("Error exporting secrets", err)
+		return err
+	}
+
+	if len(outputFile) == 0 {
+		_, err = writer.Write(buf.Bytes())
+		return err
+	}
+
+	if err := os.WriteFile(outputFile, buf.Bytes(), 0600); err != nil {
+		printError("Error writing export", err)
+		return err
+	}
+
+	if structuredOutput() {
+		names := make([]string, 0, len(secrets))
+		for _, s := range secrets {
+			names = append(names, s.Name)
+		}
+
+		return outputDocument(writer, resultDocument{Result: "exported", Names: names, File: outputFile})
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d secrets to %s\n", len(secrets), outputFile)
+
+	return nil
+}
+
+func getConfigExportCmd(rootCmd *cobra.Command) *cobra.Command {
+	var (
+		format     string
+		outputFile string
+		tags       []string
+		cobraCmd   = &cobra.Command{
+			Use:   "export",
+			Short: "Export secrets to other authenticators",
+			Long: `Export secrets to other authenticators
+
+The secrets with names matching any of the glob patterns, or all secrets if
+no patterns are given, that have all of the --tag tags are written to stdout
+or the --output-file file in one of these formats:
+
+  uri     otpauth:// URIs, one per line
+  csv     comma separated values with a header row
+  aegis   unencrypted Aegis authenticator vault
+  andotp  unencrypted andOTP backup
+  json    plain JSON that is stable across versions
+
+Every format contains the secret values in plaintext.`,
+			ValidArgsFunction: validPatternArgs,
+			Run: func(_ *cobra.Command, args []string) {
+				_ = exportSecrets(os.Stdout, args, tags, format, outputFile)
+			},
+		}
+	)
+
+	cobraCmd.Flags().BoolP(optionStdio, "", false, "load data from stdin")
+	cobraCmd.Flags().StringVarP(&format, "format", "", api.ExportJSON, "export format ("+strings.Join(api.ExportFormats(), ", ")+")")
+	cobraCmd.Flags().StringVarP(&outputFile, "output-file", "", "", "write the export to a file instead of stdout")
+	addTagFilterFlag(cobraCmd, &tags)
+	cobraCmd.SetUsageTemplate(strings.Replace(rootCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [name pattern]...", 1))
+
+	return cobraCmd
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestConfigExport(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	const exportFile = "testexport.txt"
+	defer os.Remove(exportFile)
+
+	createTestData(t)
+
+	// Filtered URI list round trips through import
+	writer := &bytes.Buffer{}
+	if err := exportSecrets(writer, []string{"name[12]", "test*"}, nil, totp.ExportURI, ""); err != nil {
+		t.Fatal("exportSecrets() error:", err)
+	}
+
+	uris := strings.Fields(writer.String())
+	if len(uris) != 3 || !strings.Contains(uris[0], "/name1?") || !strings.Contains(uris[2], "/testname?") {
+		t.Fatalf("exportSecrets() = %v", uris)
+	}
+
+	// Export to a file
+	configExportCmd := getConfigExportCmd(getRootCmd())
+	_ = configExportCmd.Flags().Set("format", totp.ExportCSV)
+	_ = configExportCmd.Flags().Set("output-file", exportFile)
+	configExportCmd.Run(nil, []string{"name0"})
+	if data, _ := os.ReadFile(exportFile); len(strings.Split(strings.TrimSpace(string(data)), "\n")) != 2 {
+		t.Errorf("Export file = %s", data)
+	}
+
+	// Invalid pattern and format
+	if err := exportSecrets(writer, []string{"["}, nil, totp.ExportJSON, ""); err == nil {
+		t.Error("exportSecrets() with invalid pattern did not fail")
+	}
+	if err := exportSecrets(writer, nil, nil, "xml", ""); err == nil {
+		t.Error("exportSecrets() with invalid format did not fail")
+	}
+
+	// Unwritable output file
+	if err := exportSecrets(writer, nil, nil, totp.ExportJSON, "nosuchdir/export.json"); err == nil {
+		t.Error("exportSecrets() to invalid file did not fail")
+	}
+
+	// Missing collection
+	os.Remove(collectionFile.filename)
+	if err := exportSecrets(writer, nil, nil, totp.ExportJSON, ""); err == nil {
+		t.Error("exportSecrets() with missing collection did not fail")
+	}
+}
@@ -0,0 +1,290 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	api "github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+// readURIs returns the otpauth URIs in data, which is either a PNG or JPEG
+// QR code image or text with one URI per line
+func readURIs(data []byte) ([]string, error) {
+	switch http.DetectContentType(data) {
+	case "image/png", "image/jpeg":
+		uri, err := decodeQrCode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []string{uri}, nil
+	}
+
+	var uris []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) != 0 && !strings.HasPrefix(line, "#") {
+			uris = append(uris, line)
+		}
+	}
+
+	return uris, scanner.Err()
+}
+
+// getImportURIs returns the URIs given by the import arguments. Arguments
+// that are not otpauth URIs are files to read. With no arguments, URIs are
+// read from stdin.
+func getImportURIs(args []string, stdin io.Reader) ([]string, error) {
+	if len(args) == 0 {
+		if collectionFile.useStdio {
+			return nil, errors.New("URIs must be given as arguments or files when using stdio")
+		}
+
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, err
+		}
+
+		return readURIs(data)
+	}
+
+	var uris []string
+	for _, arg := range args {
+		if strings.Contains(arg, "://") {
+			uris = append(uris, arg)
+			continue
+		}
+
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		fileURIs, err := readURIs(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+
+		uris = append(uris, fileURIs...)
+	}
+
+	return uris, nil
+}
+
+// importSecrets adds the secrets to the collection, reporting those
+// skipped, and saves the collection
+func importSecrets(secrets []api.Secret, overwrite bool) {
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return
+	}
+	defer unlock()
+
+	// ignore error because file may not exist, unless the collection
+	// exists but could not be read
+	c, err := collectionFile.loader()
+	if isUnreadableCollectionError(err) {
+		printError("Error loading collection", err)
+		return
+	}
+
+	var imported []string
+	for _, secret := range secrets {
+		if isReservedCommand(secret.Name) {
+			printError("Skipped secret "+secret.Name, fmt.Errorf("the name is reserved for the %s command", secret.Name))
+			continue
+		}
+
+		if _, err := c.ImportSecret(secret, overwrite); err != nil {
+			printError("Skipped secret "+secret.Name, err)
+			continue
+		}
+
+		if !structuredOutput() {
+			if _, err := printResultf("Imported secret %s\n", secret.Name); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		imported = append(imported, secret.Name)
+	}
+
+	// stdio always outputs the collection so it is not lost from a pipeline
+	if len(imported) == 0 && !collectionFile.useStdio {
+		return
+	}
+
+	if err := c.Save(); err != nil {
+		printError("Error saving settings", err)
+		return
+	}
+
+	if structuredOutput() {
+		if err := printResult(resultDocument{Result: "imported", Names: imported}, ""); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// parseURIs parses otpauth and otpauth-migration URIs into secrets,
+// reporting the URIs skipped and incomplete migration batches
+func parseURIs(uris []string) []api.Secret {
+	var (
+		secrets  []api.Secret
+		payloads []api.MigrationPayload
+	)
+
+	for _, uri := range uris {
+		if strings.HasPrefix(uri, "otpauth-migration:") {
+			payload, err := api.ParseMigrationURI(uri)
+			if err != nil {
+				printError("Skipped URI", err)
+				continue
+			}
+
+			payloads = append(payloads, payload)
+			secrets = append(secrets, payload.Secrets...)
+			continue
+		}
+
+		secret, err := api.ParseURI(uri)
+		if err != nil {
+			printError("Skipped URI", err)
+			continue
+		}
+
+		secrets = append(secrets, secret)
+	}
+
+	if err := api.CheckMigrationBatches(payloads); err != nil {
+		printError("Warning", err)
+	}
+
+	return secrets
+}
+
+func importURIs(args []string, name string, overwrite bool) {
+	uris, err := getImportURIs(args, os.Stdin)
+	if err != nil {
+		printError("Error reading URIs", err)
+		return
+	}
+
+	secrets := parseURIs(uris)
+
+	if len(name) != 0 {
+		if len(secrets) != 1 {
+			printError("Error importing secrets", errors.New("a name can only be given when importing one secret"))
+			return
+		}
+
+		secrets[0].Name = name
+	}
+
+	importSecrets(secrets, overwrite)
+}
+
+// readAegisSecrets returns the secrets in the Aegis vault files, or the
+// vault read from stdin if no files are given, reporting skipped entries
+func readAegisSecrets(files []string, stdin io.Reader) ([]api.Secret, error) {
+	var vaults [][]byte
+	if len(files) == 0 {
+		if collectionFile.useStdio {
+			return nil, errors.New("Aegis files must be given as arguments when using stdio")
+		}
+
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, err
+		}
+		vaults = append(vaults, data)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		vaults = append(vaults, data)
+	}
+
+	var secrets []api.Secret
+	for i, data := range vaults {
+		vaultSecrets, skipped, err := api.ParseAegis(data, getImportPassphrase)
+		if err != nil {
+			if len(files) != 0 {
+				err = fmt.Errorf("%s: %w", files[i], err)
+			}
+			return nil, err
+		}
+
+		for _, err := range skipped {
+			printError("Skipped entry", err)
+		}
+
+		secrets = append(secrets, vaultSecrets...)
+	}
+
+	return secrets, nil
+}
+
+func importAegis(files []string, overwrite bool) {
+	secrets, err := readAegisSecrets(files, os.Stdin)
+	if err != nil {
+		printError("Error reading Aegis vault", err)
+		return
+	}
+
+	importSecrets(secrets, overwrite)
+}
+
+func getConfigImportCmd(rootCmd *cobra.Command) *cobra.Command {
+	var (
+		aegis     bool
+		name      string
+		overwrite bool
+		cobraCmd  = &cobra.Command{
+			Use:   "import",
+			Short: "Import secrets from otpauth URIs, QR code images or Aegis exports",
+			Long: `Import secrets from otpauth URIs, QR code images or Aegis exports
+
+Each argument is an otpauth:// or Google Authenticator otpauth-migration://
+URI or a file containing a PNG or JPEG QR code image or URIs, one per line.
+With no arguments, URIs are read from stdin. The secret name is taken from
+the URI label and issuer unless --name is given.
+
+With --aegis, each argument is an Aegis authenticator JSON export, plain or
+encrypted. The password for an encrypted export is read from the
+TOTP_IMPORT_PASSPHRASE environment variable or prompted for. Entry types
+other than TOTP and HOTP are skipped.`,
+			Run: func(_ *cobra.Command, args []string) {
+				if aegis {
+					if len(name) != 0 {
+						printError("Error importing secrets", errors.New("a name cannot be given when importing an Aegis vault"))
+						return
+					}
+
+					importAegis(args, overwrite)
+					return
+				}
+
+				importURIs(args, name, overwrite)
+			},
+		}
+	)
+
+	cobraCmd.Flags().BoolP(optionStdio, "", false, "load with stdin, save with stdout")
+	cobraCmd.Flags().StringVarP(&name, "name", "n", "", "name for a single imported secret")
+	cobraCmd.Flags().BoolVarP(&aegis, "aegis", "", false, "import Aegis authenticator JSON exports")
+	cobraCmd.Flags().BoolVarP(&overwrite, optionOverwrite, "", false, "replace existing secrets with the same name")
+	cobraCmd.SetUsageTemplate(strings.Replace(rootCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [otpauth URI | file]...", 1))
+
+	return cobraCmd
+}
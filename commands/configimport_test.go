@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/arcanericky/totp"
+	"github.com/skip2/go-qrcode"
+)
+
+func TestConfigImport(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	const (
+		uriFile   = "testimport.txt"
+		imageFile = "testimport.png"
+	)
+	defer os.Remove(uriFile)
+	defer os.Remove(imageFile)
+
+	_ = os.WriteFile(uriFile, []byte("# comment\n\notpauth://totp/fileissuer:fileaccount?secret=SEED\notpauth://totp/invalid\n"), 0600)
+	png, _ := qrcode.Encode("otpauth://totp/imageissuer:imageaccount?secret=SEEDSEED&digits=8", qrcode.Medium, 256)
+	_ = os.WriteFile(imageFile, png, 0600)
+
+	createTestData(t)
+
+	configImportCmd := getConfigImportCmd(getRootCmd())
+
+	// URI argument, URI file, and QR code image
+	configImportCmd.Run(nil, []string{"otpauth://totp/name5?secret=SEED&issuer=argissuer", uriFile, imageFile})
+	c, _ := totp.NewCollectionWithFile(collectionFile.filename)
+	for _, name := range []string{"argissuer:name5", "fileissuer:fileaccount", "imageissuer:imageaccount"} {
+		if _, err := c.GetSecret(name); err != nil {
+			t.Errorf("Secret %s not imported", name)
+		}
+	}
+
+	if s, _ := c.GetSecret("imageissuer:imageaccount"); s.Digits != 8 {
+		t.Error("Secret options not imported", s)
+	}
+
+	// Existing secret not overwritten
+	configImportCmd.Run(nil, []string{"otpauth://totp/name0?secret=SEEDSEED"})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("name0"); s.Value != "SEED" {
+		t.Error("Existing secret overwritten", s)
+	}
+
+	// Existing secret overwritten with name option
+	_ = configImportCmd.Flags().Set(optionOverwrite, "true")
+	_ = configImportCmd.Flags().Set("name", "name0")
+	configImportCmd.Run(nil, []string{"otpauth://totp/other?secret=SEEDSEED"})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("name0"); s.Value != "SEEDSEED" {
+		t.Error("Existing secret not overwritten", s)
+	}
+
+	// Name with multiple URIs
+	configImportCmd.Run(nil, []string{uriFile})
+	configImportCmd = getConfigImportCmd(getRootCmd())
+
+	// Migration URI with one part of a batch
+	configImportCmd.Run(nil, []string{"otpauth-migration://offline?data=CjEKCkhlbGxvId6tvu8SGEV4YW1wbGU6YWxpY2VAZ29vZ2xlLmNvbRoHRXhhbXBsZTACGAIgAA%3D%3D", "otpauth-migration://offline?data=!!!"})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("Example:alice@google.com"); s.Value != "JBSWY3DPEHPK3PXP" {
+		t.Error("Migration secret not imported", s)
+	}
+
+	// Reserved name
+	configImportCmd.Run(nil, []string{"otpauth://totp/config?secret=SEED"})
+
+	// Missing file
+	configImportCmd.Run(nil, []string{"nosuchfile.txt"})
+}
+
+func Test_getImportURIs(t *testing.T) {
+	uris, err := getImportURIs([]string{}, strings.NewReader("otpauth://totp/a?secret=SEED\notpauth://totp/b?secret=SEED\n"))
+	if err != nil || len(uris) != 2 {
+		t.Errorf("getImportURIs() = %v, %v", uris, err)
+	}
+
+	collectionFile.useStdio = true
+	if _, err := getImportURIs([]string{}, strings.NewReader("")); err == nil {
+		t.Error("getImportURIs() from stdin with stdio did not fail")
+	}
+	collectionFile.useStdio = false
+
+	// Corrupt image
+	if _, err := readURIs([]byte("\x89PNG\r\n\x1a\n")); err == nil {
+		t.Error("readURIs() with corrupt image did not fail")
+	}
+}
+
+func TestConfigImportAegis(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	const vaultFile = "testaegis.json"
+	defer os.Remove(vaultFile)
+
+	_ = os.WriteFile(vaultFile, []byte(`{"version": 1, "header": {"slots": null, "params": null}, "db": {"version": 2, "entries": [
+		{"type": "totp", "name": "alice", "issuer": "Example", "info": {"secret": "SEEDSEED", "algo": "SHA512", "digits": 8, "period": 30}},
+		{"type": "totp", "name": "name0", "issuer": "", "info": {"secret": "SEEDSEED", "algo": "SHA1", "digits": 6, "period": 30}},
+		{"type": "steam", "name": "game", "issuer": "Steam", "info": {"secret": "SEEDSEED", "algo": "SHA1", "digits": 5, "period": 30}}
+	]}}`), 0600)
+
+	createTestData(t)
+
+	configImportCmd := getConfigImportCmd(getRootCmd())
+	_ = configImportCmd.Flags().Set("aegis", "true")
+
+	configImportCmd.Run(nil, []string{vaultFile})
+	c, _ := totp.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("Example:alice"); s.Algorithm != "SHA512" || s.Digits != 8 {
+		t.Error("Aegis secret not imported", s)
+	}
+	if _, err := c.GetSecret("Steam:game"); err == nil {
+		t.Error("Unsupported Aegis entry imported")
+	}
+	if s, _ := c.GetSecret("name0"); s.Value != "SEED" {
+		t.Error("Existing secret overwritten", s)
+	}
+
+	// Overwrite existing secret
+	_ = configImportCmd.Flags().Set(optionOverwrite, "true")
+	configImportCmd.Run(nil, []string{vaultFile})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("name0"); s.Value != "SEEDSEED" {
+		t.Error("Existing secret not overwritten", s)
+	}
+
+	// Name not allowed with a vault
+	_ = configImportCmd.Flags().Set("name", "name1")
+	configImportCmd.Run(nil, []string{vaultFile})
+
+	// Invalid vault and missing file
+	configImportCmd = getConfigImportCmd(getRootCmd())
+	_ = configImportCmd.Flags().Set("aegis", "true")
+	configImportCmd.Run(nil, []string{collectionFile.filename})
+	configImportCmd.Run(nil, []string{"nosuchfile.json"})
+}
+
+func Test_readAegisSecrets(t *testing.T) {
+	t.Setenv(envImportPassphrase, "password")
+
+	secrets, err := readAegisSecrets([]string{}, strings.NewReader(`{"header": {}, "db": {"entries": [{"type": "hotp", "name": "a", "info": {"secret": "SEED", "counter": 3}}]}}`))
+	if err != nil || len(secrets) != 1 || secrets[0].Counter != 3 {
+		t.Errorf("readAegisSecrets() = %v, %v", secrets, err)
+	}
+
+	collectionFile.useStdio = true
+	if _, err := readAegisSecrets([]string{}, strings.NewReader("")); err == nil {
+		t.Error("readAegisSecrets() from stdin with stdio did not fail")
+	}
+	collectionFile.useStdio = false
+}
@@ -1,10 +1,10 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"sort"
 	"strings"
 
 	"github.com/arcanericky/totp"
@@ -28,71 +28,99 @@ func listSecretNames(writer io.Writer, secrets []totp.Secret) {
 	}
 }
 
+// listColumn is a column of the secret info table
+type listColumn struct {
+	title string
+	value func(totp.Secret) string
+}
+
 func listInfo(writer io.Writer, secrets []totp.Secret, all bool) {
-	const (
-		nameTitle         = "Name"
-		secretTitle       = "Secret"
-		addedDateTitle    = "Date Added"
-		modifiedDateTitle = "Date Modified"
+	const timeFormat = "Jan _2 2006 15:04:05"
+
+	columns := []listColumn{{"Name", func(s totp.Secret) string { return s.Name }}}
+	if all {
+		columns = append(columns, listColumn{"Secret", func(s totp.Secret) string { return s.Value }})
+	}
+	columns = append(columns,
+		listColumn{"Issuer", func(s totp.Secret) string { return s.GetIssuer() }},
+		listColumn{"Account", func(s totp.Secret) string { return s.GetAccount() }},
+		listColumn{"Date Added", func(s totp.Secret) string { return s.DateAdded.Format(timeFormat) }},
+		listColumn{"Date Modified", func(s totp.Secret) string { return s.DateModified.Format(timeFormat) }},
+		listColumn{"Tags", func(s totp.Secret) string { return strings.Join(s.Tags, ",") }},
 	)
+	if all {
+		columns = append(columns, listColumn{"Notes", func(s totp.Secret) string { return s.Notes }})
+	}
 
-	maxNameLen := len(nameTitle)
-	maxSecretLen := len(secretTitle)
-	for _, s := range secrets {
-		nameLen := len(s.Name)
-		if nameLen > maxNameLen {
-			maxNameLen = nameLen
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = len(column.title)
+		for _, s := range secrets {
+			if l := len(column.value(s)); l > widths[i] {
+				widths[i] = l
+			}
 		}
+	}
 
-		secretLen := len(s.Value)
-		if secretLen > maxSecretLen {
-			maxSecretLen = secretLen
+	// lines are trimmed so they have no trailing space
+	printRow := func(value func(i int) string) {
+		fields := make([]string, len(columns))
+		for i := range columns {
+			fields[i] = fmt.Sprintf("%-*s", widths[i], value(i))
 		}
+		fmt.Fprintln(writer, strings.TrimRight(strings.Join(fields, " "), " "))
 	}
 
-	const timeFormat = "Jan _2 2006 15:04:05"
-	timeFormatLen := len(timeFormat)
-	timeFormatLine := titleLine(len(timeFormat))
+	printRow(func(i int) string { return columns[i].title })
+	printRow(func(i int) string { return titleLine(widths[i]) })
+	for _, s := range secrets {
+		printRow(func(i int) string { return columns[i].value(s) })
+	}
+}
 
-	if all {
-		fmt.Fprintf(writer, "%-*s %-*s %-*s %-*s\n",
-			maxNameLen, nameTitle,
-			maxSecretLen, secretTitle,
-			timeFormatLen, addedDateTitle,
-			timeFormatLen, modifiedDateTitle)
-		fmt.Fprintf(writer, "%s %s %s %s\n", titleLine(maxNameLen), titleLine(maxSecretLen), timeFormatLine, timeFormatLine)
+// listDocuments writes the names, or the info, of the secrets as a
+// document
+func listDocuments(writer io.Writer, secrets []totp.Secret, names, all bool) {
+	var document interface{}
+	if names {
+		secretNames := make([]string, 0, len(secrets))
 		for _, s := range secrets {
-			fmt.Fprintf(writer, "%-*s %-*s %s %s\n", maxNameLen, s.Name, maxSecretLen, s.Value, s.DateAdded.Format(timeFormat), s.DateModified.Format(timeFormat))
+			secretNames = append(secretNames, s.Name)
 		}
-		return
+		document = secretNames
+	} else {
+		documents := make([]secretDocument, 0, len(secrets))
+		for _, s := range secrets {
+			documents = append(documents, newSecretDocument(s, all))
+		}
+		document = documents
 	}
 
-	fmt.Fprintf(writer, "%-*s %-*s %-*s\n",
-		maxNameLen, nameTitle,
-		timeFormatLen, addedDateTitle,
-		timeFormatLen, modifiedDateTitle)
-	fmt.Fprintf(writer, "%s %s %s\n", titleLine(maxNameLen), timeFormatLine, timeFormatLine)
-	for _, s := range secrets {
-		fmt.Fprintf(writer, "%-*s %s %s\n", maxNameLen, s.Name, s.DateAdded.Format(timeFormat), s.DateModified.Format(timeFormat))
+	if err := outputDocument(writer, document); err != nil {
+		printError("Error writing secrets", err)
 	}
 }
 
-func listSecrets(names, all bool) {
+// listSecrets lists the secrets matching the patterns and tags
+func listSecrets(writer io.Writer, patterns, tags []string, names, all bool) {
 	c, err := collectionFile.loader()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading collection", err)
+		printError("Error loading collection", err)
 		return
 	}
 
-	secrets := c.GetSecrets()
-	sort.Slice(secrets, func(i, j int) bool {
-		return secrets[i].Name < secrets[j].Name
-	})
+	secrets, err := filterSecrets(c.GetSecrets(), patterns, tags)
+	if err != nil {
+		printError("Error matching secrets", err)
+		return
+	}
 
-	if names {
-		listSecretNames(os.Stdout, secrets)
+	if structuredOutput() {
+		listDocuments(writer, secrets, names, all)
+	} else if names {
+		listSecretNames(writer, secrets)
 	} else {
-		listInfo(os.Stdout, secrets, all)
+		listInfo(writer, secrets, all)
 	}
 }
 
@@ -100,23 +128,29 @@ func getConfigListCmd() *cobra.Command {
 	var (
 		names    bool
 		all      bool
+		tags     []string
 		cobraCmd = &cobra.Command{
 			Use:     "list",
 			Aliases: []string{"ls", "l"},
 			Short:   "List secrets",
-			Long:    `List secrets`,
-			Run: func(listCmd *cobra.Command, _ []string) {
+			Long: `List secrets
+
+Only secrets with names matching any of the glob patterns given, and with all
+of the --tag tags, are listed.`,
+			ValidArgsFunction: validPatternArgs,
+			Run: func(listCmd *cobra.Command, args []string) {
 				if names && all {
-					fmt.Fprintln(os.Stderr, "Only one of --names or --all can be used.")
+					printError("Error listing secrets", errors.New("only one of --names or --all can be used"))
 					return
 				}
-				listSecrets(names, all)
+				listSecrets(os.Stdout, args, tags, names, all)
 			},
 		}
 	)
 
 	cobraCmd.Flags().BoolVarP(&names, "names", "n", false, "list only secret names")
 	cobraCmd.Flags().BoolVarP(&all, "all", "a", false, "list all secret info")
+	addTagFilterFlag(cobraCmd, &tags)
 
 	cobraCmd.Flags().BoolP(optionStdio, "", false, "load data from stdin")
 
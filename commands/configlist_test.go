@@ -1,8 +1,13 @@
 package commands
 
 import (
+	"bytes"
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
 )
 
 func TestConfigList(t *testing.T) {
@@ -37,3 +42,60 @@ func TestConfigList(t *testing.T) {
 	os.Remove(collectionFile.filename)
 	configListCmd.Run(configListCmd, []string{})
 }
+
+func Test_listInfo(t *testing.T) {
+	added := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	secrets := []totp.Secret{
+		{Name: "Example:alice", Value: "SEED", DateAdded: added, DateModified: added, Tags: []string{"acme", "prod"}, Notes: "backup codes in safe"},
+		{Name: "work", Value: "SEEDSEED", DateAdded: added, DateModified: added, Issuer: "Corp", Account: "bob"},
+	}
+
+	writer := &bytes.Buffer{}
+	listInfo(writer, secrets, false)
+	want := `Name          Issuer  Account Date Added           Date Modified        Tags
+------------- ------- ------- -------------------- -------------------- ---------
+Example:alice Example alice   Jan  2 2020 03:04:05 Jan  2 2020 03:04:05 acme,prod
+work          Corp    bob     Jan  2 2020 03:04:05 Jan  2 2020 03:04:05
+`
+	if writer.String() != want {
+		t.Errorf("listInfo() = \n%s, want \n%s", writer.String(), want)
+	}
+
+	writer.Reset()
+	listInfo(writer, secrets, true)
+	if lines := strings.Split(writer.String(), "\n"); !strings.HasPrefix(lines[0], "Name          Secret   Issuer") || !strings.HasSuffix(lines[2], "acme,prod backup codes in safe") {
+		t.Errorf("listInfo() all = \n%s", writer.String())
+	}
+}
+
+func Test_listSecrets(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+	c, _ := totp.NewCollectionWithFile(collectionFile.filename)
+	_, _ = c.SetMetadata("name1", totp.SecretMetadata{Tags: []string{"acme", "prod"}})
+	_, _ = c.SetMetadata("name2", totp.SecretMetadata{Tags: []string{"acme"}})
+	_, _ = c.SetMetadata("testname", totp.SecretMetadata{Tags: []string{"acme"}})
+	_ = c.Save()
+
+	writer := &bytes.Buffer{}
+	listSecrets(writer, []string{"name*"}, []string{"acme"}, true, false)
+	if got := writer.String(); got != "name1\nname2\n" {
+		t.Errorf("listSecrets() = %q", got)
+	}
+
+	writer.Reset()
+	listSecrets(writer, nil, []string{"acme", "prod"}, true, false)
+	if got := writer.String(); got != "name1\n" {
+		t.Errorf("listSecrets() = %q", got)
+	}
+
+	// Invalid pattern
+	writer.Reset()
+	listSecrets(writer, []string{"["}, nil, true, false)
+	if writer.Len() != 0 {
+		t.Errorf("listSecrets() with invalid pattern = %q", writer.String())
+	}
+}
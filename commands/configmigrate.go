@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	api "github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+// migrateCollection upgrades the collection to the current schema version
+// and saves it or, for a dry run, writes the upgrades that would be made
+func migrateCollection(writer io.Writer, dryRun bool) error {
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return err
+	}
+	defer unlock()
+
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	upgrades := c.SchemaUpgrades()
+
+	// stdio always outputs the collection so it is not lost from a pipeline
+	if !dryRun && (len(upgrades) != 0 || collectionFile.useStdio) {
+		if err := c.Save(); err != nil {
+			printError("Error saving settings", err)
+			return err
+		}
+	}
+
+	if structuredOutput() {
+		document := migrationDocument{SchemaVersion: api.SchemaVersion, Upgrades: append([]string{}, upgrades...), DryRun: dryRun}
+		if err := outputDocument(writer, document); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return err
+		}
+		return nil
+	}
+
+	switch {
+	case len(upgrades) == 0:
+		fmt.Fprintf(writer, "Collection is at schema version %d, nothing to migrate\n", api.SchemaVersion)
+	case dryRun:
+		fmt.Fprintln(writer, "Migrating would upgrade the collection schema:")
+		for _, upgrade := range upgrades {
+			fmt.Fprintln(writer, " ", upgrade)
+		}
+	default:
+		for _, upgrade := range upgrades {
+			if _, err := printResultf("Upgraded collection schema %s\n", upgrade); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func getConfigMigrateCmd() *cobra.Command {
+	var (
+		dryRun   bool
+		cobraCmd = &cobra.Command{
+			Use:   "migrate",
+			Short: "Upgrade the collection to the current schema version",
+			Long: `Upgrade the collection to the current schema version
+
+Collections saved by older versions of totp are upgraded when loaded and
+written in the current format the next time they are saved. This command
+saves the upgraded collection right away, or with --dry-run, shows the
+upgrades that would be made. Collections saved by newer versions of totp
+are refused rather than risk losing data.`,
+			Run: func(_ *cobra.Command, _ []string) {
+				writer := io.Writer(os.Stdout)
+				if collectionFile.useStdio {
+					writer = os.Stderr
+				}
+
+				_ = migrateCollection(writer, dryRun)
+			},
+		}
+	)
+
+	cobraCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "show the upgrades without saving")
+	cobraCmd.Flags().BoolP(optionStdio, "", false, "load with stdin, save with stdout")
+
+	return cobraCmd
+}
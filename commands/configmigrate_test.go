@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestConfigMigrate(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	collectionFile.useStdio = false
+	defer os.Remove(collectionFile.filename)
+
+	const oldCollection = `{"Secrets": {"name": {"Name": "name", "Value": "SEED"}}}`
+	if err := os.WriteFile(collectionFile.filename, []byte(oldCollection), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// dry run leaves the file alone
+	writer := &bytes.Buffer{}
+	if err := migrateCollection(writer, true); err != nil {
+		t.Fatal("migrateCollection() error:", err)
+	}
+	if !strings.Contains(writer.String(), "version 0 to 1") {
+		t.Errorf("migrateCollection() = %q", writer.String())
+	}
+	if data, _ := os.ReadFile(collectionFile.filename); string(data) != oldCollection {
+		t.Error("Dry run changed the collection")
+	}
+
+	// migrate
+	if err := migrateCollection(writer, false); err != nil {
+		t.Fatal("migrateCollection() error:", err)
+	}
+	if data, _ := os.ReadFile(collectionFile.filename); !strings.Contains(string(data), `"Version": 1`) {
+		t.Errorf("Migrated collection = %s", data)
+	}
+
+	// nothing left to migrate
+	writer.Reset()
+	configMigrateCmd := getConfigMigrateCmd()
+	configMigrateCmd.Run(configMigrateCmd, []string{})
+	if err := migrateCollection(writer, false); err != nil || !strings.Contains(writer.String(), "nothing to migrate") {
+		t.Errorf("migrateCollection() = %q, %v", writer.String(), err)
+	}
+
+	// newer collections are refused and not overwritten
+	const newCollection = `{"Version": 99, "Secrets": {}}`
+	_ = os.WriteFile(collectionFile.filename, []byte(newCollection), 0600)
+	if err := migrateCollection(writer, false); !errors.Is(err, totp.ErrNewerSchema) {
+		t.Errorf("migrateCollection() error = %v, want %v", err, totp.ErrNewerSchema)
+	}
+
+	updateSecret("name", "SEED", secretOptionChanges{}, secretMetadataChanges{})
+	importSecrets([]totp.Secret{{Name: "other", Value: "SEED"}}, false)
+	if data, _ := os.ReadFile(collectionFile.filename); string(data) != newCollection {
+		t.Error("Newer collection was overwritten")
+	}
+}
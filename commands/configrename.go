@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -10,22 +11,37 @@ import (
 
 func renameSecret(source, target string) {
 	if isReservedCommand(target) {
-		fmt.Fprintln(os.Stderr, "The name \""+target+"\" is reserved for the "+target+" command")
+		printError("Error renaming secret", reservedNameError(target))
+		return
+	}
+
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return
+	}
+	defer unlock()
+
+	// ignore error because file may not exist, unless the collection
+	// exists but could not be read
+	s, err := collectionFile.loader()
+	if isUnreadableCollectionError(err) {
+		printError("Error loading collection", err)
 		return
 	}
 
-	s, _ := collectionFile.loader()
 	if _, err := s.RenameSecret(source, target); err != nil {
-		fmt.Fprintln(os.Stderr, "Error renaming secret:", err)
+		printError("Error renaming secret", err)
 		return
 	}
 
 	if err := s.Save(); err != nil {
-		fmt.Fprintln(os.Stderr, "Error saving settings:", err)
+		printError("Error saving settings", err)
 		return
 	}
 
-	if _, err := printResultf("Renamed secret %s to %s\n", source, target); err != nil {
+	document := resultDocument{Result: "renamed", Name: source, NewName: target}
+	if err := printResult(document, "Renamed secret %s to %s\n", source, target); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return
 	}
@@ -40,7 +56,7 @@ func getConfigRenameCmd(rootCmd *cobra.Command) *cobra.Command {
 		ValidArgsFunction: validArgs,
 		Run: func(_ *cobra.Command, args []string) {
 			if len(args) != 2 {
-				fmt.Fprintln(os.Stderr, "Must provide source and target.")
+				printError("Error renaming secret", errors.New("must provide source and target"))
 				return
 			}
 
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/arcanericky/totp"
@@ -9,6 +10,7 @@ import (
 
 func TestConfigRename(t *testing.T) {
 	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
 
 	secrets := createTestData(t)
 
@@ -42,6 +44,16 @@ func TestConfigRename(t *testing.T) {
 		t.Error("Secret should not have been renamed to \"" + configCmdUse + "\"")
 	}
 
+	// A collection that cannot be read is not overwritten
+	const newCollection = `{"Version": 99, "Secrets": {}}`
+	_ = os.WriteFile(collectionFile.filename, []byte(newCollection), 0600)
+	if stderr := captureStderr(t, func() { configRenameCmd.Run(nil, []string{newName, "other"}) }); !strings.HasPrefix(stderr, "Error loading collection") {
+		t.Errorf("rename error = %q", stderr)
+	}
+	if data, _ := os.ReadFile(collectionFile.filename); string(data) != newCollection {
+		t.Error("Unreadable collection was overwritten")
+	}
+
 	// No collections file
 	os.Remove(collectionFile.filename)
 	configRenameCmd.Run(nil, []string{secrets[0].name, "newname"})
@@ -2,20 +2,71 @@ package commands
 
 import (
 	"bufio"
-	"fmt"
+	"errors"
 	"os"
+	"path/filepath"
 
+	"github.com/arcanericky/totp"
 	"github.com/spf13/cobra"
 )
 
 func configReset(filename string) error {
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return err
+	}
+	defer unlock()
+
+	backup, err := totp.BackupFile(filename, collectionFile.backups)
+	if err != nil {
+		printError("Error backing up collection", err)
+		return err
+	}
+
 	if err := os.Remove(filename); err != nil {
-		fmt.Fprintf(os.Stderr, "Error removing collection file %s: %s\n", filename, err)
+		printError("Error removing collection file "+filename, err)
+		return err
+	}
+
+	format, a := "Collection file %s removed\n", []interface{}{filename}
+	if len(backup) != 0 {
+		backup = filepath.Base(backup)
+		format, a = format+"Restore it with: totp config restore %s\n", append(a, backup)
+	}
+
+	return printResult(resultDocument{Result: "reset", File: filename, Backup: backup}, format, a...)
+}
+
+// resetStore deletes every secret from a collection kept in a store other
+// than a file
+func resetStore() error {
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return err
+	}
+	defer unlock()
+
+	c, err := collectionFile.loader()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	for _, secret := range c.GetSecrets() {
+		if _, err := c.DeleteSecret(secret.Name); err != nil {
+			printError("Error deleting secret", err)
+			return err
+		}
+	}
+
+	if err := c.Save(); err != nil {
+		printError("Error saving settings", err)
 		return err
 	}
 
-	fmt.Printf("Collection file %s removed\n", filename)
-	return nil
+	return printResult(resultDocument{Result: "reset"}, "Collection reset\n")
 }
 
 func getConfigResetCmd() *cobra.Command {
@@ -29,17 +80,26 @@ func getConfigResetCmd() *cobra.Command {
 				if !confirmAll {
 					confirm, err := userConfirm(bufio.NewReader(os.Stdin), "This will remove all secrets.")
 					if err != nil {
-						fmt.Fprintln(os.Stderr, "Error getting response:", err)
+						printError("Error getting response", err)
 						return
 					}
 
 					if !confirm {
-						fmt.Println("Skipping reset")
+						_ = printResult(resultDocument{Result: "skipped"}, "Skipping reset\n")
 						return
 					}
 				}
 
-				_ = configReset(collectionFile.filename)
+				filename, err := collectionFilePath()
+				if errors.Is(err, errNotFileStore) {
+					_ = resetStore()
+					return
+				} else if err != nil {
+					printError("Error resetting collection", err)
+					return
+				}
+
+				_ = configReset(filename)
 			},
 		}
 	)
@@ -1,7 +1,9 @@
 package commands
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -25,3 +27,37 @@ func TestConfigReset(t *testing.T) {
 		t.Error("Failed to generate error removing invalid collection file")
 	}
 }
+
+func TestConfigResetStore(t *testing.T) {
+	dir := t.TempDir()
+	collectionFile.filename = "dir://" + filepath.ToSlash(dir)
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer func() { collectionFile.filename = "testcollection.json" }()
+
+	updateSecret("name", "SEED", secretOptionChanges{}, secretMetadataChanges{})
+
+	configResetCmd := getConfigResetCmd()
+	_ = configResetCmd.Flags().Set(optionYes, "true")
+	configResetCmd.Run(nil, []string{})
+
+	if c, err := collectionFile.loader(); err != nil || len(c.Secrets) != 0 {
+		t.Errorf("reset store = %v, %v", c.Secrets, err)
+	}
+
+	// backups are only kept for files
+	configBackupsCmd := getConfigBackupsCmd()
+	configBackupsListCmd, _, _ := configBackupsCmd.Find([]string{"list"})
+	configBackupsListCmd.Run(configBackupsListCmd, []string{})
+
+	if err := restoreBackup(os.Stdout, nil, "backup.bak", true); !errors.Is(err, errNotFileStore) {
+		t.Errorf("restoreBackup() error = %v, want %v", err, errNotFileStore)
+	}
+
+	if names, _ := validBackupArgs(nil, nil, ""); names != nil {
+		t.Errorf("validBackupArgs() = %v", names)
+	}
+
+	// unknown store
+	collectionFile.filename = "nosuchstore://somewhere"
+	configResetCmd.Run(nil, []string{})
+}
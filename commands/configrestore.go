@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+// resolveBackup returns the path of a backup given as a path or as a file
+// name in the directory of the collection file filename
+func resolveBackup(filename, backup string) string {
+	if _, err := os.Stat(backup); err == nil || strings.ContainsRune(backup, filepath.Separator) {
+		return backup
+	}
+
+	return filepath.Join(filepath.Dir(filename), backup)
+}
+
+// backupDiff returns the names of the secrets a restore adds and removes
+func backupDiff(current, backup *totp.Collection) (added, removed []string) {
+	for name := range backup.Secrets {
+		if _, ok := current.Secrets[name]; !ok {
+			added = append(added, name)
+		}
+	}
+
+	for name := range current.Secrets {
+		if _, ok := backup.Secrets[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
+
+func writeBackupDiff(writer io.Writer, added, removed []string) {
+	if len(added) == 0 && len(removed) == 0 {
+		fmt.Fprintln(writer, "No secrets are added or removed")
+		return
+	}
+
+	for _, name := range added {
+		fmt.Fprintln(writer, "+", name)
+	}
+
+	for _, name := range removed {
+		fmt.Fprintln(writer, "-", name)
+	}
+}
+
+// restoreBackup shows the secrets added and removed by restoring the
+// backup and, once confirmed, replaces the collection with it
+func restoreBackup(writer io.Writer, reader *bufio.Reader, backup string, confirmAll bool) error {
+	filename, err := collectionFilePath()
+	if err != nil {
+		printError("Error restoring backup", err)
+		return err
+	}
+
+	unlock, err := lockCollection()
+	if err != nil {
+		// lockCollection will output error text
+		return err
+	}
+	defer unlock()
+
+	backup = resolveBackup(filename, backup)
+
+	backupCollection, err := totp.NewCollectionWithFile(backup)
+	if err != nil {
+		printError("Error loading backup", err)
+		return err
+	}
+
+	// ignore error because the collection may have been reset, unless
+	// it exists but could not be read
+	current, err := collectionFile.loader()
+	if isUnreadableCollectionError(err) {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	added, removed := backupDiff(current, backupCollection)
+	document := restoreDocument{Backup: filepath.Base(backup), Added: append([]string{}, added...), Removed: append([]string{}, removed...)}
+	if !structuredOutput() {
+		fmt.Fprintf(writer, "Restoring %s:\n", filepath.Base(backup))
+		writeBackupDiff(writer, added, removed)
+	}
+
+	if !confirmAll {
+		confirm, err := userConfirm(reader, "This will replace the collection with the backup.")
+		if err != nil {
+			printError("Error getting response", err)
+			return err
+		}
+
+		if !confirm {
+			document.Result = "skipped"
+			return outputRestore(writer, document, "Skipping restore\n")
+		}
+	}
+
+	if err := totp.RestoreBackup(filename, backup, collectionFile.backups); err != nil {
+		printError("Error restoring backup", err)
+		return err
+	}
+
+	document.Result = "restored"
+	return outputRestore(writer, document, "Restored collection from %s\n", filepath.Base(backup))
+}
+
+// outputRestore writes the result of a restore as text, or as a document in
+// structured output
+func outputRestore(writer io.Writer, document restoreDocument, format string, a ...interface{}) error {
+	if structuredOutput() {
+		return outputDocument(writer, document)
+	}
+
+	_, err := fmt.Fprintf(writer, format, a...)
+	return err
+}
+
+func validBackupArgs(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	filename, err := collectionFilePath()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	backups, _ := totp.Backups(filename)
+
+	var names []string
+	for _, b := range backups {
+		if name := filepath.Base(b.Filename); strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func getConfigRestoreCmd(rootCmd *cobra.Command) *cobra.Command {
+	var (
+		confirmAll bool
+		cobraCmd   = &cobra.Command{
+			Use:   "restore",
+			Short: "Restore the collection from a backup",
+			Long: `Restore the collection from a backup
+
+The names of the secrets the backup adds and removes are shown before the
+collection is replaced. The collection is backed up first, so a restore can
+also be undone. Use "config backups list" to show the backups.`,
+			ValidArgsFunction: validBackupArgs,
+			Run: func(_ *cobra.Command, args []string) {
+				if len(args) != 1 {
+					printError("Error restoring backup", errors.New("a backup to restore is required"))
+					return
+				}
+
+				_ = restoreBackup(os.Stdout, bufio.NewReader(os.Stdin), args[0], confirmAll)
+			},
+		}
+	)
+
+	cobraCmd.Flags().BoolVarP(&confirmAll, optionYes, "y", false, "confirm all prompts")
+	cobraCmd.SetUsageTemplate(strings.Replace(rootCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [backup]", 1))
+
+	return cobraCmd
+}
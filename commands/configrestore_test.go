@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arcanericky/totp"
+)
+
+func Test_backupDiff(t *testing.T) {
+	current := totp.NewCollection()
+	_, _ = current.UpdateSecret("kept", "SEED")
+	_, _ = current.UpdateSecret("new", "SEED")
+
+	backup := totp.NewCollection()
+	_, _ = backup.UpdateSecret("kept", "SEED")
+	_, _ = backup.UpdateSecret("old2", "SEED")
+	_, _ = backup.UpdateSecret("old1", "SEED")
+
+	added, removed := backupDiff(current, backup)
+	if !reflect.DeepEqual(added, []string{"old1", "old2"}) || !reflect.DeepEqual(removed, []string{"new"}) {
+		t.Errorf("backupDiff() = %v, %v", added, removed)
+	}
+
+	writer := &bytes.Buffer{}
+	writeBackupDiff(writer, added, removed)
+	if got := writer.String(); got != "+ old1\n+ old2\n- new\n" {
+		t.Errorf("writeBackupDiff() = %q", got)
+	}
+
+	writer.Reset()
+	writeBackupDiff(writer, nil, nil)
+	if got := writer.String(); got != "No secrets are added or removed\n" {
+		t.Errorf("writeBackupDiff() = %q", got)
+	}
+}
+
+func TestConfigRestore(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	collectionFile.backups = 5
+	defer func() { collectionFile.backups = 0 }()
+	defer removeTestBackups()
+	defer os.Remove(collectionFile.filename)
+
+	removeTestBackups()
+	os.Remove(collectionFile.filename)
+	createTestData(t)
+
+	// reset keeps a backup to restore
+	if err := configReset(collectionFile.filename); err != nil {
+		t.Fatal("configReset() error:", err)
+	}
+
+	backups, _ := totp.Backups(collectionFile.filename)
+	if len(backups) != 1 {
+		t.Fatalf("Backups() after reset = %v", backups)
+	}
+	backup := filepath.Base(backups[0].Filename)
+
+	// declined
+	writer := &bytes.Buffer{}
+	if err := restoreBackup(writer, bufio.NewReader(strings.NewReader("n\n")), backup, false); err != nil {
+		t.Fatal("restoreBackup() error:", err)
+	}
+	if _, err := os.Stat(collectionFile.filename); !os.IsNotExist(err) {
+		t.Error("Declined restore replaced the collection")
+	}
+	if !strings.Contains(writer.String(), "+ name0\n") {
+		t.Errorf("restoreBackup() = %q", writer.String())
+	}
+
+	// confirmed
+	if err := restoreBackup(writer, bufio.NewReader(strings.NewReader("y\n")), backup, false); err != nil {
+		t.Fatal("restoreBackup() error:", err)
+	}
+	c, err := totp.NewCollectionWithFile(collectionFile.filename)
+	if err != nil || len(c.Secrets) != 6 {
+		t.Errorf("restored collection = %v, %v", c.Secrets, err)
+	}
+
+	// completion offers the backups
+	if names, _ := validBackupArgs(nil, nil, "testcollection"); len(names) != 1 || names[0] != backup {
+		t.Errorf("validBackupArgs() = %v", names)
+	}
+	if names, _ := validBackupArgs(nil, []string{backup}, ""); names != nil {
+		t.Errorf("validBackupArgs() with args = %v", names)
+	}
+
+	// missing backup
+	if err := restoreBackup(writer, nil, "nosuchbackup.bak", true); err == nil {
+		t.Error("restoreBackup() of missing backup did not fail")
+	}
+
+	configRestoreCmd := getConfigRestoreCmd(getRootCmd())
+	configRestoreCmd.Run(configRestoreCmd, []string{})
+	_ = configRestoreCmd.Flags().Set(optionYes, "true")
+	configRestoreCmd.Run(configRestoreCmd, []string{backup})
+}
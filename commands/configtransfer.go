@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	api "github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+// getTransferSecrets returns the named secrets, or all secrets if no names
+// are given, that have all of the tags, sorted by name
+func getTransferSecrets(c *api.Collection, names, tags []string) ([]api.Secret, error) {
+	var secrets []api.Secret
+	if len(names) == 0 {
+		secrets = c.GetSecrets()
+	} else {
+		for _, name := range names {
+			s, err := c.GetSecret(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			secrets = append(secrets, s)
+		}
+	}
+
+	return filterSecrets(secrets, nil, tags)
+}
+
+func transferSecrets(writer io.Writer, names, tags []string, batchSize int, uriOnly bool) error {
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	secrets, err := getTransferSecrets(c, names, tags)
+	if err != nil {
+		printError("Error getting secret", err)
+		return err
+	}
+
+	uris, err := api.NewMigrationURIs(secrets, batchSize)
+	if err != nil {
+		printError("Error creating migration data", err)
+		return err
+	}
+
+	if structuredOutput() {
+		return outputDocument(writer, append([]string{}, uris...))
+	}
+
+	for i, uri := range uris {
+		if uriOnly {
+			fmt.Fprintln(writer, uri)
+			continue
+		}
+
+		fmt.Fprintf(writer, "QR code %d of %d\n", i+1, len(uris))
+		if err := printQrCode(writer, uri); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getConfigTransferCmd(rootCmd *cobra.Command) *cobra.Command {
+	var (
+		batchSize int
+		uriOnly   bool
+		tags      []string
+		cobraCmd  = &cobra.Command{
+			Use:   "transfer",
+			Short: "Output QR codes to transfer secrets to Google Authenticator",
+			Long: `Output QR codes to transfer secrets to Google Authenticator
+
+The named secrets, or all secrets if none are named, that have all of the
+--tag tags are encoded as
+otpauth-migration QR codes that can be scanned with the Google Authenticator
+"Transfer accounts" feature. Secrets that cannot be represented, such as
+those with a period other than 30 seconds, cause an error.`,
+			ValidArgsFunction: validPatternArgs,
+			Run: func(_ *cobra.Command, args []string) {
+				_ = transferSecrets(os.Stdout, args, tags, batchSize, uriOnly)
+			},
+		}
+	)
+
+	cobraCmd.Flags().BoolP(optionStdio, "", false, "load data from stdin")
+	cobraCmd.Flags().IntVarP(&batchSize, "batch-size", "", api.DefaultMigrationBatchSize, "number of secrets in each QR code")
+	cobraCmd.Flags().BoolVarP(&uriOnly, "uri", "", false, "output otpauth-migration URIs instead of QR codes")
+	addTagFilterFlag(cobraCmd, &tags)
+	cobraCmd.SetUsageTemplate(strings.Replace(rootCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [secret name]...", 1))
+
+	return cobraCmd
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestConfigTransfer(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	secretList := createTestData(t)
+
+	// URIs for all secrets round trip through import
+	writer := &bytes.Buffer{}
+	if err := transferSecrets(writer, nil, nil, 4, true); err != nil {
+		t.Fatal("transferSecrets() error:", err)
+	}
+
+	uris := strings.Fields(writer.String())
+	if len(uris) != 2 {
+		t.Fatalf("transferSecrets() = %v, want 2 URIs", uris)
+	}
+
+	os.Remove(collectionFile.filename)
+	importURIs(uris, "", false)
+	c, _ := totp.NewCollectionWithFile(collectionFile.filename)
+	for _, s := range secretList {
+		if _, err := c.GetSecret(s.name); err != nil {
+			t.Errorf("Secret %s not transferred", s.name)
+		}
+	}
+
+	// QR codes for named secrets
+	writer.Reset()
+	if err := transferSecrets(writer, []string{"name1", "name0"}, nil, 10, false); err != nil || !strings.HasPrefix(writer.String(), "QR code 1 of 1\n") {
+		t.Errorf("transferSecrets() = %v, %v", writer.String(), err)
+	}
+
+	configTransferCmd := getConfigTransferCmd(getRootCmd())
+	configTransferCmd.Run(nil, []string{"name0"})
+
+	// Every argument is completed with secret names
+	if names, _ := configTransferCmd.ValidArgsFunction(configTransferCmd, []string{"name0"}, "name"); len(names) == 0 {
+		t.Error("second argument not completed")
+	}
+
+	// Only secrets with the tags
+	_, _ = c.SetMetadata("name1", totp.SecretMetadata{Tags: []string{"work"}})
+	_ = c.Save()
+	writer.Reset()
+	if err := transferSecrets(writer, nil, []string{"work"}, 10, true); err != nil || len(strings.Fields(writer.String())) != 1 {
+		t.Errorf("transferSecrets() = %v, %v", writer.String(), err)
+	}
+	writer.Reset()
+	if err := transferSecrets(writer, []string{"name0"}, []string{"work"}, 10, true); err != nil || writer.Len() != 0 {
+		t.Errorf("transferSecrets() without matching secrets = %v, %v", writer.String(), err)
+	}
+
+	// Secret not found
+	if err := transferSecrets(writer, []string{"invalidname"}, nil, 10, false); err == nil {
+		t.Error("transferSecrets() with invalid name did not fail")
+	}
+
+	// Unsupported secret
+	_, _ = c.UpdateSecretWithOptions("period", "seed", totp.SecretOptions{Period: 60})
+	_ = c.Save()
+	if err := transferSecrets(writer, []string{"period"}, nil, 10, false); err == nil {
+		t.Error("transferSecrets() with unsupported secret did not fail")
+	}
+
+	// No collection file
+	os.Remove(collectionFile.filename)
+	if err := transferSecrets(writer, nil, nil, 10, false); err == nil {
+		t.Error("transferSecrets() without collection did not fail")
+	}
+}
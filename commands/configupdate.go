@@ -1,62 +1,227 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
+	api "github.com/arcanericky/totp"
 	"github.com/spf13/cobra"
 )
 
-func updateSecret(name, value string) {
+// secretOptionChanges holds the options given on the command line. Nil
+// members were not given and keep the value of an existing secret.
+type secretOptionChanges struct {
+	secretType *string
+	algorithm  *string
+	digits     *int
+	period     *uint
+}
+
+func (c secretOptionChanges) apply(opts api.SecretOptions) api.SecretOptions {
+	if c.secretType != nil {
+		opts.Type = *c.secretType
+	}
+
+	if c.algorithm != nil {
+		opts.Algorithm = *c.algorithm
+	}
+
+	if c.digits != nil {
+		opts.Digits = *c.digits
+	}
+
+	if c.period != nil {
+		opts.Period = *c.period
+	}
+
+	return opts
+}
+
+// secretMetadataChanges holds the metadata given on the command line. Nil
+// members were not given and keep the value of an existing secret.
+type secretMetadataChanges struct {
+	issuer  *string
+	account *string
+	notes   *string
+	tags    *[]string
+}
+
+func (c secretMetadataChanges) changed() bool {
+	return c.issuer != nil || c.account != nil || c.notes != nil || c.tags != nil
+}
+
+func (c secretMetadataChanges) apply(meta api.SecretMetadata) api.SecretMetadata {
+	if c.issuer != nil {
+		meta.Issuer = *c.issuer
+	}
+
+	if c.account != nil {
+		meta.Account = *c.account
+	}
+
+	if c.notes != nil {
+		meta.Notes = *c.notes
+	}
+
+	if c.tags != nil {
+		meta.Tags = *c.tags
+	}
+
+	return meta
+}
+
+func updateSecret(name, value string, changes secretOptionChanges, metaChanges secretMetadataChanges) {
 	if isReservedCommand(name) {
-		fmt.Fprintln(os.Stderr, "The name \""+name+"\" is reserved for the "+name+" command")
+		printError("Error updating secret", reservedNameError(name))
 		return
 	}
 
-	// ignore error because file may not exist
-	s, _ := collectionFile.loader()
-
-	secret, err := s.UpdateSecret(name, value)
+	unlock, err := lockCollection()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error updating secret:", err)
+		// lockCollection will output error text
 		return
 	}
+	defer unlock()
 
-	if err := s.Save(); err != nil {
-		fmt.Fprintln(os.Stderr, "Error saving settings:", err)
+	// ignore error because file may not exist, unless the collection
+	// exists but could not be read
+	s, err := collectionFile.loader()
+	if isUnreadableCollectionError(err) {
+		printError("Error loading collection", err)
 		return
 	}
 
+	// an existing secret keeps the value, options, and metadata not given
+	existing, err := s.GetSecret(name)
+	added := err != nil
+	if len(value) == 0 {
+		if added {
+			printError("Error updating secret", errors.New("a secret value is required to add a secret"))
+			return
+		}
+		value = existing.Value
+	}
+
+	opts := changes.apply(existing.Options())
+	if added {
+		// a new secret is imported with its metadata so it is only stamped
+		// once and its dates added and modified are the same
+		meta := metaChanges.apply(existing.Metadata())
+		secret := api.Secret{
+			Name:      name,
+			Value:     value,
+			Type:      opts.Type,
+			Algorithm: opts.Algorithm,
+			Digits:    opts.Digits,
+			Period:    opts.Period,
+			Issuer:    meta.Issuer,
+			Account:   meta.Account,
+			Notes:     meta.Notes,
+			Tags:      meta.Tags,
+		}
+		if _, err := s.ImportSecret(secret, false); err != nil {
+			printError("Error updating secret", err)
+			return
+		}
+	} else {
+		if _, err := s.UpdateSecretWithOptions(name, value, opts); err != nil {
+			printError("Error updating secret", err)
+			return
+		}
+
+		if metaChanges.changed() {
+			if _, err := s.SetMetadata(name, metaChanges.apply(existing.Metadata())); err != nil {
+				printError("Error updating secret", err)
+				return
+			}
+		}
+	}
+
 	action := "Updated"
-	if secret.DateAdded == secret.DateModified {
+	if added {
 		action = "Added"
 	}
 
-	if _, err := printResultf("%s secret %s\n", action, name); err != nil {
+	if err := s.Save(); err != nil {
+		printError("Error saving settings", err)
+		return
+	}
+
+	document := resultDocument{Result: strings.ToLower(action), Name: name}
+	if err := printResult(document, "%s secret %s\n", action, name); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return
 	}
 }
 
 func getConfigUpdateCmd(rootCmd *cobra.Command) *cobra.Command {
+	var (
+		opts api.SecretOptions
+		meta api.SecretMetadata
+	)
+
 	var cobraCmd = &cobra.Command{
-		Use:               "update",
-		Aliases:           []string{"add"},
-		Short:             "Add or update a secret",
-		Long:              `Add or update a secret`,
+		Use:     "update",
+		Aliases: []string{"add"},
+		Short:   "Add or update a secret",
+		Long: `Add or update a secret
+
+The secret value can be left out when updating the options or metadata of
+an existing secret. Options and metadata not given keep their values.`,
 		ValidArgsFunction: validArgs,
-		Run: func(_ *cobra.Command, args []string) {
-			if len(args) != 2 {
-				fmt.Fprintln(os.Stderr, "Must provide name and secret")
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 1 && len(args) != 2 {
+				printError("Error updating secret", errors.New("must provide name and secret"))
 				return
 			}
 
-			updateSecret(args[0], args[1])
+			value := ""
+			if len(args) == 2 {
+				value = args[1]
+			}
+
+			var changes secretOptionChanges
+			if cmd.Flags().Changed(optionType) {
+				changes.secretType = &opts.Type
+			}
+			if cmd.Flags().Changed(optionAlgorithm) {
+				changes.algorithm = &opts.Algorithm
+			}
+			if cmd.Flags().Changed(optionDigits) {
+				changes.digits = &opts.Digits
+			}
+			if cmd.Flags().Changed(optionPeriod) {
+				changes.period = &opts.Period
+			}
+
+			var metaChanges secretMetadataChanges
+			if cmd.Flags().Changed(optionIssuer) {
+				metaChanges.issuer = &meta.Issuer
+			}
+			if cmd.Flags().Changed(optionAccount) {
+				metaChanges.account = &meta.Account
+			}
+			if cmd.Flags().Changed(optionNotes) {
+				metaChanges.notes = &meta.Notes
+			}
+			if cmd.Flags().Changed(optionTag) {
+				metaChanges.tags = &meta.Tags
+			}
+
+			updateSecret(args[0], value, changes, metaChanges)
 		},
 	}
 
 	cobraCmd.Flags().BoolP(optionStdio, "", false, "load with stdin, save with stdout")
+	cobraCmd.Flags().StringVarP(&opts.Type, optionType, "", "", "secret type (totp, hotp)")
+	addSecretOptionFlags(cobraCmd, &opts, "")
+	cobraCmd.Flags().StringVarP(&meta.Issuer, optionIssuer, "", "", "issuer of the secret")
+	cobraCmd.Flags().StringVarP(&meta.Account, optionAccount, "", "", "account name of the secret")
+	cobraCmd.Flags().StringVarP(&meta.Notes, optionNotes, "", "", "notes about the secret")
+	cobraCmd.Flags().StringSliceVarP(&meta.Tags, optionTag, "", nil, "tags for the secret, replacing any existing tags (repeat or separate with commas)")
+	_ = cobraCmd.RegisterFlagCompletionFunc(optionTag, validTagArgs)
 	cobraCmd.SetUsageTemplate(strings.Replace(rootCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [secret name] [secret value]", 1))
 	return cobraCmd
 }
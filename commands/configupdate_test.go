@@ -2,6 +2,7 @@ package commands
 
 import (
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/arcanericky/totp"
@@ -9,6 +10,7 @@ import (
 
 func TestConfigUpdate(t *testing.T) {
 	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
 
 	createTestData(t)
 
@@ -16,7 +18,7 @@ func TestConfigUpdate(t *testing.T) {
 
 	// Valid parameters
 	secretName := "testsecret"
-	configUpdateCmd.Run(nil, []string{secretName, "seed"})
+	configUpdateCmd.Run(configUpdateCmd, []string{secretName, "seed"})
 	c, err := totp.NewCollectionWithFile(collectionFile.filename)
 	if err != nil {
 		t.Error("Could not load collection for update test from file")
@@ -29,7 +31,7 @@ func TestConfigUpdate(t *testing.T) {
 
 	// Test update secret
 	newSecret := "SEEDSEED"
-	configUpdateCmd.Run(nil, []string{secretName, newSecret})
+	configUpdateCmd.Run(configUpdateCmd, []string{secretName, newSecret})
 	c, err = totp.NewCollectionWithFile(collectionFile.filename)
 	if err != nil {
 		t.Error("Could not load collection for update test from file")
@@ -42,7 +44,7 @@ func TestConfigUpdate(t *testing.T) {
 
 	// Test using secret named 'config'
 	secretName = "config"
-	configUpdateCmd.Run(nil, []string{secretName, "seed"})
+	configUpdateCmd.Run(configUpdateCmd, []string{secretName, "seed"})
 	c, err = totp.NewCollectionWithFile(collectionFile.filename)
 	if err != nil {
 		t.Error("Could not load collection for update test from file")
@@ -53,13 +55,78 @@ func TestConfigUpdate(t *testing.T) {
 		t.Error("Secret named \"" + secretName + "\" should not have been saved")
 	}
 
+	// Set options
+	_ = configUpdateCmd.Flags().Set(optionAlgorithm, "sha256")
+	_ = configUpdateCmd.Flags().Set(optionDigits, "8")
+	_ = configUpdateCmd.Flags().Set(optionPeriod, "60")
+	configUpdateCmd.Run(configUpdateCmd, []string{"testsecret", newSecret})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	secret, _ = c.GetSecret("testsecret")
+	if secret.Algorithm != "SHA256" || secret.Digits != 8 || secret.Period != 60 {
+		t.Error("Secret options not updated", secret)
+	}
+
+	// Options not given are kept
+	configUpdateCmd = getConfigUpdateCmd(getRootCmd())
+	_ = configUpdateCmd.Flags().Set(optionDigits, "7")
+	configUpdateCmd.Run(configUpdateCmd, []string{"testsecret", newSecret})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	secret, _ = c.GetSecret("testsecret")
+	if secret.Algorithm != "SHA256" || secret.Digits != 7 || secret.Period != 60 {
+		t.Error("Secret options not kept", secret)
+	}
+
+	// Metadata with the value left out
+	configUpdateCmd = getConfigUpdateCmd(getRootCmd())
+	_ = configUpdateCmd.Flags().Set(optionIssuer, "Example")
+	_ = configUpdateCmd.Flags().Set(optionNotes, "shared account")
+	_ = configUpdateCmd.Flags().Set(optionTag, "prod,acme")
+	configUpdateCmd.Run(configUpdateCmd, []string{"testsecret"})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	secret, _ = c.GetSecret("testsecret")
+	if secret.Value != newSecret || secret.Digits != 7 || secret.Issuer != "Example" || secret.Notes != "shared account" || !reflect.DeepEqual(secret.Tags, []string{"acme", "prod"}) {
+		t.Error("Secret metadata not updated", secret)
+	}
+
+	// Metadata not given is kept
+	configUpdateCmd = getConfigUpdateCmd(getRootCmd())
+	_ = configUpdateCmd.Flags().Set(optionAccount, "alice")
+	configUpdateCmd.Run(configUpdateCmd, []string{"testsecret", newSecret})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	secret, _ = c.GetSecret("testsecret")
+	if secret.Account != "alice" || secret.Issuer != "Example" || len(secret.Tags) != 2 {
+		t.Error("Secret metadata not kept", secret)
+	}
+
+	// New secret with metadata
+	configUpdateCmd.Run(configUpdateCmd, []string{"newsecret", "seed"})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if secret, _ = c.GetSecret("newsecret"); secret.Account != "alice" {
+		t.Error("New secret metadata not set", secret)
+	}
+	if !secret.DateAdded.Equal(secret.DateModified) {
+		t.Error("New secret dates differ", secret.DateAdded, secret.DateModified)
+	}
+
+	// Value required for a new secret
+	configUpdateCmd.Run(configUpdateCmd, []string{"othersecret"})
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if _, err := c.GetSecret("othersecret"); err == nil {
+		t.Error("Secret added without a value")
+	}
+
+	// Invalid options
+	_ = configUpdateCmd.Flags().Set(optionAlgorithm, "invalid")
+	configUpdateCmd.Run(configUpdateCmd, []string{"testsecret", newSecret})
+	configUpdateCmd = getConfigUpdateCmd(getRootCmd())
+
 	// No parameters passed
-	configUpdateCmd.Run(nil, []string{})
+	configUpdateCmd.Run(configUpdateCmd, []string{})
 
 	// Invalid secret value
-	configUpdateCmd.Run(nil, []string{"testsecret", "seed1"})
+	configUpdateCmd.Run(configUpdateCmd, []string{"testsecret", "seed1"})
 
 	// No collections file
 	os.Remove(collectionFile.filename)
-	configUpdateCmd.Run(nil, []string{"testsecret", "seed"})
+	configUpdateCmd.Run(configUpdateCmd, []string{"testsecret", "seed"})
 }
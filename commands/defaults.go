@@ -1,9 +1,15 @@
 package commands
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"time"
 
 	"github.com/arcanericky/totp"
 )
@@ -14,23 +20,141 @@ const (
 	cmdVersion    = "version"
 	cmdConfig     = "config"
 	cmdCompletion = "completion"
+	cmdVerify     = "verify"
+	cmdServe      = "serve"
+	cmdAgent      = "agent"
+	cmdTime       = "time"
+
+	envBackups = "TOTP_BACKUPS"
 )
 
 var collectionFile struct {
 	filename string
 	useStdio bool
+	stdin    []byte
+	backups  int
 	loader   func() (*totp.Collection, error)
 }
 
+// errNotFileStore is returned by commands that only work with collection
+// files
+var errNotFileStore = errors.New("the collection is not stored in a file")
+
+// openCollectionStore opens the store of the collection, which is stdin and
+// stdout or the --file store
+func openCollectionStore() (totp.Store, error) {
+	if collectionFile.useStdio {
+		return &totp.StdioStore{Reader: os.Stdin, Writer: os.Stdout}, nil
+	}
+
+	return openFileStore()
+}
+
+// openFileStore opens the --file store, which is a store URL or the name
+// of a collection file
+func openFileStore() (totp.Store, error) {
+	store, err := totp.OpenStore(collectionFile.filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileStore, ok := store.(*totp.FileStore); ok {
+		fileStore.Backups = collectionFile.backups
+	}
+
+	return store, nil
+}
+
+// collectionFilePath returns the name of the collection file, or
+// errNotFileStore if the collection is kept in another store
+func collectionFilePath() (string, error) {
+	store, err := openCollectionStore()
+	if err != nil {
+		return "", err
+	}
+
+	fileStore, ok := store.(*totp.FileStore)
+	if !ok {
+		return "", errNotFileStore
+	}
+
+	return fileStore.Filename, nil
+}
+
+// readStdin returns the collection data read from stdin, which is only read
+// once so the same data can be identified and loaded
+func readStdin() ([]byte, error) {
+	if collectionFile.stdin == nil {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		collectionFile.stdin = data
+	}
+
+	return collectionFile.stdin, nil
+}
+
 func loadCollectionFromStdin() (*totp.Collection, error) {
-	c, err := totp.NewCollectionWithReader(os.Stdin)
-	c.SetWriter(os.Stdout)
+	data, err := readStdin()
+	if err != nil {
+		return totp.NewCollection(), err
+	}
 
-	return c, err
+	return totp.NewCollectionWithStore(&totp.StdioStore{Reader: bytes.NewReader(data), Writer: os.Stdout})
 }
 
 func loadCollectionFromDefaultFile() (*totp.Collection, error) {
-	return totp.NewCollectionWithFile(collectionFile.filename)
+	store, err := openFileStore()
+	if err != nil {
+		return totp.NewCollection(), err
+	}
+
+	return totp.NewCollectionWithStore(store)
+}
+
+// isUnreadableCollectionError reports whether a load error means an
+// existing collection could not be read, because of its passphrase or
+// because it was saved by a newer version, in which case it must not be
+// overwritten
+func isUnreadableCollectionError(err error) bool {
+	return isPassphraseError(err) || errors.Is(err, totp.ErrNewerSchema)
+}
+
+// collectionLockTimeout is how long to wait for another totp process to
+// release the collection
+var collectionLockTimeout = 10 * time.Second
+
+// lockCollection locks the collection store so commands that load, modify,
+// and save it do not lose each other's updates. The returned function
+// releases the lock. Collections read from stdin are not locked, and
+// neither are collections in directories the lock file cannot be created
+// in, which are read unlocked as saving them would fail anyway.
+func lockCollection() (func(), error) {
+	store, err := openCollectionStore()
+	if err != nil {
+		printError("Error opening collection", err)
+		return nil, err
+	}
+
+	lock, err := store.Lock(collectionLockTimeout)
+	if errors.Is(err, totp.ErrLockUnavailable) {
+		return func() {}, nil
+	}
+	if err != nil {
+		if errors.Is(err, totp.ErrLockTimeout) {
+			printError("Error locking collection", fmt.Errorf("%w (is another totp command running?)", err))
+		} else {
+			printError("Error locking collection", err)
+		}
+		return nil, err
+	}
+
+	return func() {
+		if err := lock.Unlock(); err != nil {
+			printError("Error unlocking collection", err)
+		}
+	}, nil
 }
 
 func setCollectionFile(goos string) {
@@ -47,7 +171,23 @@ func setCollectionFile(goos string) {
 	collectionFile.filename = filepath.Join(os.Getenv("HOME"), "."+defaultBaseCollectionFile)
 }
 
-var reservedCommands = []string{cmdConfig, cmdVersion, cmdCompletion}
+// setBackupCount sets the number of collection backups kept from the
+// TOTP_BACKUPS environment variable
+func setBackupCount() {
+	collectionFile.backups = totp.DefaultBackupCount
+
+	if backups := os.Getenv(envBackups); backups != "" {
+		count, err := strconv.Atoi(backups)
+		if err != nil || count < 0 {
+			fmt.Fprintf(os.Stderr, "Ignoring invalid %s value %q\n", envBackups, backups)
+			return
+		}
+
+		collectionFile.backups = count
+	}
+}
+
+var reservedCommands = []string{cmdConfig, cmdVersion, cmdCompletion, cmdVerify, cmdServe, cmdAgent, cmdTime}
 
 func isReservedCommand(name string) bool {
 	for _, c := range reservedCommands {
@@ -59,7 +199,14 @@ func isReservedCommand(name string) bool {
 	return false
 }
 
+// reservedNameError returns the error for a secret named after a command
+func reservedNameError(name string) error {
+	return fmt.Errorf("the name %q is reserved for the %s command", name, name)
+}
+
 func defaults() {
 	setCollectionFile(runtime.GOOS)
+	setBackupCount()
 	collectionFile.loader = loadCollectionFromDefaultFile
+	totp.SetPassphraseFunc(getPassphrase)
 }
@@ -1,9 +1,13 @@
 package commands
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
 )
 
 func TestDefaults(t *testing.T) {
@@ -36,3 +40,102 @@ func TestDefaults(t *testing.T) {
 		t.Error("Error checking invalid reserved command")
 	}
 }
+
+func TestLockCollection(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	collectionFile.useStdio = false
+	defer os.Remove(collectionFile.filename)
+
+	savedTimeout := collectionLockTimeout
+	collectionLockTimeout = 100 * time.Millisecond
+	defer func() { collectionLockTimeout = savedTimeout }()
+
+	createTestData(t)
+
+	lock, err := totp.LockFile(collectionFile.filename, time.Second)
+	if err != nil {
+		t.Fatal("LockFile() error:", err)
+	}
+
+	if _, err := lockCollection(); !errors.Is(err, totp.ErrLockTimeout) {
+		t.Errorf("lockCollection() of held lock error = %v, want %v", err, totp.ErrLockTimeout)
+	}
+
+	// a locked collection is not modified
+	updateSecret("lockedname", "SEED", secretOptionChanges{}, secretMetadataChanges{})
+	if c, _ := totp.NewCollectionWithFile(collectionFile.filename); c.Secrets["lockedname"].Name != "" {
+		t.Error("Locked collection was modified")
+	}
+
+	_ = lock.Unlock()
+
+	unlock, err := lockCollection()
+	if err != nil {
+		t.Fatal("lockCollection() error:", err)
+	}
+	unlock()
+
+	// stdin collections are not locked
+	collectionFile.useStdio = true
+	defer func() { collectionFile.useStdio = false }()
+	if _, err := lockCollection(); err != nil {
+		t.Error("lockCollection() with stdio error:", err)
+	}
+
+	// unwritable lock file
+	collectionFile.useStdio = false
+	collectionFile.filename = "nosuchdir/testcollection.json"
+	if _, err := lockCollection(); err == nil {
+		t.Error("lockCollection() in missing directory did not fail")
+	}
+	collectionFile.filename = "testcollection.json"
+}
+
+func TestCollectionStores(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "secrets")
+	defer func() { collectionFile.filename = "testcollection.json" }()
+
+	collectionFile.loader = loadCollectionFromDefaultFile
+	collectionFile.useStdio = false
+
+	// a collection file
+	collectionFile.filename = "testcollection.json"
+	if filename, err := collectionFilePath(); err != nil || filename != "testcollection.json" {
+		t.Errorf("collectionFilePath() = %q, %v", filename, err)
+	}
+
+	// a directory store
+	collectionFile.filename = "dir://" + filepath.ToSlash(dir)
+	if _, err := collectionFilePath(); !errors.Is(err, errNotFileStore) {
+		t.Errorf("collectionFilePath() error = %v, want %v", err, errNotFileStore)
+	}
+
+	updateSecret("name", "SEED", secretOptionChanges{}, secretMetadataChanges{})
+	c, err := collectionFile.loader()
+	if err != nil || len(c.Secrets) != 1 {
+		t.Errorf("loadCollectionFromDefaultFile() = %v, %v", c.Secrets, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "name.json")); err != nil {
+		t.Error("Secret file not saved:", err)
+	}
+
+	// stdio is not a file
+	collectionFile.useStdio = true
+	if _, err := collectionFilePath(); !errors.Is(err, errNotFileStore) {
+		t.Errorf("collectionFilePath() with stdio error = %v, want %v", err, errNotFileStore)
+	}
+	collectionFile.useStdio = false
+
+	// unknown store
+	collectionFile.filename = "nosuchstore://somewhere"
+	if _, err := collectionFile.loader(); !errors.Is(err, totp.ErrUnknownStore) {
+		t.Errorf("loadCollectionFromDefaultFile() error = %v, want %v", err, totp.ErrUnknownStore)
+	}
+	if _, err := collectionFilePath(); !errors.Is(err, totp.ErrUnknownStore) {
+		t.Errorf("collectionFilePath() error = %v, want %v", err, totp.ErrUnknownStore)
+	}
+	if _, err := lockCollection(); !errors.Is(err, totp.ErrUnknownStore) {
+		t.Errorf("lockCollection() error = %v, want %v", err, totp.ErrUnknownStore)
+	}
+}
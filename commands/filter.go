@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	api "github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+// filterSecrets returns the secrets with names matching any of the glob
+// patterns, or all secrets if no patterns are given, that also have all of
+// the tags, sorted by name
+func filterSecrets(secrets []api.Secret, patterns, tags []string) ([]api.Secret, error) {
+	var filtered []api.Secret
+	for _, s := range secrets {
+		if !s.HasTags(tags) {
+			continue
+		}
+
+		matched := len(patterns) == 0
+		for _, pattern := range patterns {
+			m, err := path.Match(pattern, s.Name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", pattern, err)
+			}
+			if m {
+				matched = true
+				break
+			}
+		}
+
+		if matched {
+			filtered = append(filtered, s)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].Name < filtered[j].Name
+	})
+
+	return filtered, nil
+}
+
+// getTagsForCompletion returns the tags in the collection starting with
+// toComplete
+func getTagsForCompletion(toComplete string) []string {
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return nil
+	}
+
+	var tags []string
+	for _, tag := range c.GetTags() {
+		if strings.HasPrefix(tag, toComplete) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+func validTagArgs(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return getTagsForCompletion(toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// validPatternArgs completes any number of secret names
+func validPatternArgs(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return getSecretNamesForCompletion(toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// addTagFilterFlag adds the --tag filter flag, completed with the tags in
+// the collection, to a command
+func addTagFilterFlag(cobraCmd *cobra.Command, tags *[]string) {
+	cobraCmd.Flags().StringSliceVarP(tags, optionTag, "", nil, "only secrets with the tag (repeat or comma separate for all of several tags)")
+	_ = cobraCmd.RegisterFlagCompletionFunc(optionTag, validTagArgs)
+}
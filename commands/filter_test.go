@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/arcanericky/totp"
+)
+
+func Test_filterSecrets(t *testing.T) {
+	secrets := []totp.Secret{
+		{Name: "b", Tags: []string{"acme"}},
+		{Name: "Example:a", Tags: []string{"acme", "prod"}},
+		{Name: "a"},
+	}
+
+	got, err := filterSecrets(secrets, nil, nil)
+	if err != nil || len(got) != 3 || got[0].Name != "Example:a" || got[2].Name != "b" {
+		t.Errorf("filterSecrets() = %v, %v", got, err)
+	}
+
+	got, err = filterSecrets(secrets, []string{"Example:*", "b"}, nil)
+	if err != nil || len(got) != 2 {
+		t.Errorf("filterSecrets() = %v, %v", got, err)
+	}
+
+	// tags must all match, alone and with patterns
+	got, err = filterSecrets(secrets, nil, []string{"acme"})
+	if err != nil || len(got) != 2 {
+		t.Errorf("filterSecrets() with tag = %v, %v", got, err)
+	}
+
+	got, err = filterSecrets(secrets, nil, []string{"acme", "prod"})
+	if err != nil || len(got) != 1 || got[0].Name != "Example:a" {
+		t.Errorf("filterSecrets() with tags = %v, %v", got, err)
+	}
+
+	got, err = filterSecrets(secrets, []string{"b"}, []string{"prod"})
+	if err != nil || len(got) != 0 {
+		t.Errorf("filterSecrets() with pattern and tag = %v, %v", got, err)
+	}
+
+	if _, err := filterSecrets(secrets, []string{"["}, nil); err == nil {
+		t.Error("filterSecrets() with invalid pattern did not fail")
+	}
+}
+
+func Test_getTagsForCompletion(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+	c, _ := totp.NewCollectionWithFile(collectionFile.filename)
+	_, _ = c.SetMetadata("name0", totp.SecretMetadata{Tags: []string{"prod", "acme"}})
+	_, _ = c.SetMetadata("name1", totp.SecretMetadata{Tags: []string{"dev"}})
+	_ = c.Save()
+
+	if got := getTagsForCompletion(""); !reflect.DeepEqual(got, []string{"acme", "dev", "prod"}) {
+		t.Errorf("getTagsForCompletion() = %v", got)
+	}
+
+	if got, _ := validTagArgs(nil, nil, "p"); !reflect.DeepEqual(got, []string{"prod"}) {
+		t.Errorf("validTagArgs() = %v", got)
+	}
+
+	if got, _ := validPatternArgs(nil, []string{"name0"}, "testn"); !reflect.DeepEqual(got, []string{"testname"}) {
+		t.Errorf("validPatternArgs() = %v", got)
+	}
+
+	os.Remove(collectionFile.filename)
+	if got := getTagsForCompletion(""); got != nil {
+		t.Errorf("getTagsForCompletion() with missing collection = %v", got)
+	}
+}
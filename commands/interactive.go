@@ -0,0 +1,369 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	api "github.com/arcanericky/totp"
+	"golang.org/x/term"
+)
+
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyEnter     = "enter"
+	keyEscape    = "esc"
+	keyBackspace = "backspace"
+	keyInterrupt = "ctrl-c"
+
+	// interactiveRefresh is how often the screen is redrawn
+	interactiveRefresh = 250 * time.Millisecond
+
+	// countdownWidth is the number of characters in a countdown bar
+	countdownWidth = 10
+
+	// interactiveHeaderLines is the number of screen lines above the
+	// secret list
+	interactiveHeaderLines = 2
+)
+
+// interactiveState is the state of the interactive display. It does not
+// touch the terminal so it can be tested.
+type interactiveState struct {
+	secrets   []api.Secret
+	search    string
+	searching bool
+	selected  int
+	offset    int
+	revealed  map[string]bool
+	message   string
+	quit      bool
+}
+
+func newInteractiveState(secrets []api.Secret) *interactiveState {
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+
+	return &interactiveState{secrets: secrets, revealed: map[string]bool{}}
+}
+
+// visible returns the secrets with names containing the search text,
+// ignoring case
+func (s *interactiveState) visible() []api.Secret {
+	if len(s.search) == 0 {
+		return s.secrets
+	}
+
+	search := strings.ToLower(s.search)
+	var secrets []api.Secret
+	for _, secret := range s.secrets {
+		if strings.Contains(strings.ToLower(secret.Name), search) {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	return secrets
+}
+
+// current returns the selected secret, if any
+func (s *interactiveState) current() (api.Secret, bool) {
+	secrets := s.visible()
+	if s.selected < 0 || s.selected >= len(secrets) {
+		return api.Secret{}, false
+	}
+
+	return secrets[s.selected], true
+}
+
+func (s *interactiveState) move(delta int) {
+	s.selected += delta
+	if last := len(s.visible()) - 1; s.selected > last {
+		s.selected = last
+	}
+	if s.selected < 0 {
+		s.selected = 0
+	}
+}
+
+// handleSearchKey edits the search text. The selection restarts at the
+// first match whenever the text changes.
+func (s *interactiveState) handleSearchKey(key string) {
+	switch key {
+	case keyEnter:
+		s.searching = false
+	case keyEscape:
+		s.searching = false
+		s.search = ""
+	case keyBackspace:
+		if len(s.search) != 0 {
+			_, size := utf8.DecodeLastRuneInString(s.search)
+			s.search = s.search[:len(s.search)-size]
+		}
+	case keyUp, keyDown:
+		return
+	default:
+		s.search += key
+	}
+
+	s.selected = 0
+	s.offset = 0
+}
+
+// handleKey updates the state for a key press. Copied codes are placed on
+// the clipboard.
+func (s *interactiveState) handleKey(key string, clipboard clipboardFunc, now time.Time) {
+	s.message = ""
+
+	if key == keyInterrupt {
+		s.quit = true
+		return
+	}
+
+	if s.searching {
+		s.handleSearchKey(key)
+		return
+	}
+
+	switch key {
+	case "q":
+		s.quit = true
+	case keyUp, "k":
+		s.move(-1)
+	case keyDown, "j":
+		s.move(1)
+	case "/":
+		s.searching = true
+	case keyEscape:
+		s.search = ""
+		s.selected = 0
+		s.offset = 0
+	case "r", " ":
+		if secret, ok := s.current(); ok {
+			s.revealed[secret.Name] = !s.revealed[secret.Name]
+		}
+	case "c", keyEnter:
+		secret, ok := s.current()
+		if !ok {
+			return
+		}
+
+		if secret.IsHOTP() {
+			s.message = fmt.Sprintf("HOTP codes are generated with: totp %s", secret.Name)
+			return
+		}
+
+		code, err := secret.GenerateCodeWithTime(now)
+		if err != nil {
+			s.message = fmt.Sprintf("Error generating code for %s: %s", secret.Name, err)
+			return
+		}
+
+		if err := clipboard(code); err != nil {
+			s.message = fmt.Sprintf("Error copying code for %s: %s", secret.Name, err)
+			return
+		}
+		s.message = fmt.Sprintf("Copied code for %s", secret.Name)
+	}
+}
+
+// countdownBar returns a bar showing the fraction of the period remaining
+func countdownBar(remaining, period time.Duration) string {
+	filled := int(int64(countdownWidth) * int64(remaining) / int64(period))
+	if filled > countdownWidth {
+		filled = countdownWidth
+	}
+
+	return strings.Repeat("█", filled) + strings.Repeat("░", countdownWidth-filled)
+}
+
+// codeColumn returns the code, or a mask if it has not been revealed,
+// along with the countdown for a TOTP secret
+func (s *interactiveState) codeColumn(secret api.Secret, now time.Time) string {
+	if secret.IsHOTP() {
+		return "(hotp)"
+	}
+
+	code, err := secret.GenerateCodeWithTime(now)
+	if err != nil {
+		return "(error)"
+	}
+
+	if !s.revealed[secret.Name] {
+		code = strings.Repeat("*", len(code))
+	}
+
+	period := secret.Options().PeriodDuration()
+	remaining := durationToNextInterval(now, period)
+
+	return fmt.Sprintf("%-8s %s %2ds", code, countdownBar(remaining, period), secondsRemaining(secret, now))
+}
+
+// render returns the screen lines for a terminal of the given height
+func (s *interactiveState) render(now time.Time, height int) []string {
+	secrets := s.visible()
+
+	header := "/ search  ↑↓ move  r reveal  c copy  q quit"
+	if s.searching || len(s.search) != 0 {
+		header = "Search: " + s.search
+		if s.searching {
+			header += "_"
+		}
+	}
+
+	lines := []string{header, s.message}
+
+	if len(secrets) == 0 {
+		return append(lines, "No matching secrets")
+	}
+
+	// keep the selection on screen
+	rows := height - interactiveHeaderLines
+	if rows < 1 {
+		rows = 1
+	}
+	if s.selected < s.offset {
+		s.offset = s.selected
+	}
+	if s.selected >= s.offset+rows {
+		s.offset = s.selected - rows + 1
+	}
+
+	maxNameLen := 0
+	for _, secret := range secrets {
+		if len(secret.Name) > maxNameLen {
+			maxNameLen = len(secret.Name)
+		}
+	}
+
+	for i := s.offset; i < len(secrets) && i < s.offset+rows; i++ {
+		marker := " "
+		if i == s.selected {
+			marker = ">"
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %-*s %s", marker, maxNameLen, secrets[i].Name, s.codeColumn(secrets[i], now)))
+	}
+
+	return lines
+}
+
+// parseKeys splits terminal input into key names. Printable characters
+// are returned as themselves.
+func parseKeys(input []byte) []string {
+	var keys []string
+	for len(input) != 0 {
+		switch {
+		case input[0] == 0x03:
+			keys = append(keys, keyInterrupt)
+		case input[0] == '\r' || input[0] == '\n':
+			keys = append(keys, keyEnter)
+		case input[0] == 0x7f || input[0] == 0x08:
+			keys = append(keys, keyBackspace)
+		case input[0] == 0x1b:
+			if len(input) >= 3 && input[1] == '[' {
+				switch input[2] {
+				case 'A':
+					keys = append(keys, keyUp)
+				case 'B':
+					keys = append(keys, keyDown)
+				}
+				input = input[3:]
+				continue
+			}
+			keys = append(keys, keyEscape)
+		case input[0] < ' ':
+			// ignore other control characters
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, string(r))
+			input = input[size:]
+			continue
+		}
+
+		input = input[1:]
+	}
+
+	return keys
+}
+
+// runInteractive shows a live updating display of the secrets in the
+// collection until the user quits. Codes are generated for the current
+// time moved by timeOffset, as generateCodes does.
+func runInteractive(args, tags []string, timeOffset time.Duration) error {
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	secrets, err := filterSecrets(c.GetSecrets(), allCodePatterns(args), tags)
+	if err != nil {
+		printError("Error matching secrets", err)
+		return err
+	}
+
+	tty, err := openTerminal()
+	if err != nil {
+		printError("Error opening terminal", err)
+		return err
+	}
+	defer tty.Close()
+
+	fd := int(tty.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		printError("Error setting terminal mode", err)
+		return err
+	}
+	defer func() { _ = term.Restore(fd, oldState) }()
+
+	// use the alternate screen and hide the cursor while running
+	fmt.Fprint(tty, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(tty, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := tty.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			for _, key := range parseKeys(buf[:n]) {
+				keys <- key
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interactiveRefresh)
+	defer ticker.Stop()
+
+	clipboard := newClipboard(tty)
+	state := newInteractiveState(secrets)
+	for !state.quit {
+		now := commandClock.Now().Add(timeOffset)
+
+		_, height, err := term.GetSize(fd)
+		if err != nil {
+			height = 24
+		}
+
+		fmt.Fprint(tty, "\x1b[H\x1b[2J"+strings.Join(state.render(now, height), "\r\n"))
+
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			state.handleKey(key, clipboard, now)
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
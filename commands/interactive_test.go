@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
+)
+
+func Test_parseKeys(t *testing.T) {
+	got := parseKeys([]byte("a/\x1b[A\x1b[B\x1b\r\x7f\x03é\x01\x1b[C"))
+	want := []string{"a", "/", keyUp, keyDown, keyEscape, keyEnter, keyBackspace, keyInterrupt, "é"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseKeys() = %v, want %v", got, want)
+	}
+}
+
+func Test_countdownBar(t *testing.T) {
+	if got := countdownBar(15*time.Second, 30*time.Second); got != "█████░░░░░" {
+		t.Errorf("countdownBar() = %s", got)
+	}
+	if got := countdownBar(30*time.Second, 30*time.Second); got != strings.Repeat("█", countdownWidth) {
+		t.Errorf("countdownBar() = %s", got)
+	}
+}
+
+func TestInteractiveState(t *testing.T) {
+	now := time.Date(2019, 6, 23, 20, 0, 5, 0, time.UTC)
+	code, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(now)
+
+	state := newInteractiveState([]totp.Secret{
+		{Name: "work:bob", Value: "SEED"},
+		{Name: "Example:alice", Value: "SEED", Period: 60},
+		{Name: "hotpname", Value: "SEED", Type: totp.TypeHOTP},
+		{Name: "invalid", Value: "SEED", Digits: 9},
+	})
+
+	lines := state.render(now, 24)
+	if len(lines) != 6 || !strings.HasPrefix(lines[2], "> Example:alice ******") || !strings.HasSuffix(lines[2], "55s") {
+		t.Fatalf("render() = %q", lines)
+	}
+
+	// Reveal and copy the selected code
+	copied := &bytes.Buffer{}
+	clipboard := osc52Clipboard(copied)
+	for _, key := range []string{keyDown, keyDown, keyDown, "r", "c"} {
+		state.handleKey(key, clipboard, now)
+	}
+	if secret, _ := state.current(); secret.Name != "work:bob" {
+		t.Errorf("current() = %v", secret)
+	}
+	lines = state.render(now, 24)
+	if !strings.Contains(lines[5], code) || !strings.Contains(copied.String(), "52;c;") || lines[1] != "Copied code for work:bob" {
+		t.Errorf("render() = %q, clipboard %q", lines, copied.String())
+	}
+
+	// Moving past the ends stays on the list
+	state.handleKey(keyDown, clipboard, now)
+	state.handleKey("k", clipboard, now)
+	if secret, _ := state.current(); secret.Name != "invalid" {
+		t.Errorf("current() = %v", secret)
+	}
+	state.handleKey(keyEnter, clipboard, now)
+	if !strings.HasPrefix(state.message, "Error generating code") {
+		t.Errorf("message = %s", state.message)
+	}
+
+	// Search
+	for _, key := range []string{"/", "H", "O", "T", "X", keyBackspace, keyUp, keyEnter} {
+		state.handleKey(key, clipboard, now)
+	}
+	if state.searching || state.search != "HOT" || len(state.visible()) != 1 {
+		t.Errorf("search = %q, visible %v", state.search, state.visible())
+	}
+	state.handleKey("c", clipboard, now)
+	if !strings.HasPrefix(state.message, "HOTP codes") {
+		t.Errorf("message = %s", state.message)
+	}
+	if lines = state.render(now, 24); lines[0] != "Search: HOT" || !strings.HasSuffix(lines[2], "(hotp)") {
+		t.Errorf("render() = %q", lines)
+	}
+
+	// No matches, then clear the search
+	for _, key := range []string{"/", "z", keyEnter} {
+		state.handleKey(key, clipboard, now)
+	}
+	state.handleKey("r", clipboard, now)
+	state.handleKey("c", clipboard, now)
+	if lines = state.render(now, 24); lines[2] != "No matching secrets" {
+		t.Errorf("render() = %q", lines)
+	}
+	state.handleKey(keyEscape, clipboard, now)
+	for _, key := range []string{"/", "x", keyEscape} {
+		state.handleKey(key, clipboard, now)
+	}
+	if len(state.visible()) != 4 {
+		t.Errorf("visible() = %v", state.visible())
+	}
+
+	// The selection scrolls into view on a short screen
+	state.selected = 3
+	if lines = state.render(now, 4); len(lines) != 4 || !strings.HasPrefix(lines[3], "> work:bob") {
+		t.Errorf("render() = %q", lines)
+	}
+	state.selected = 0
+	if lines = state.render(now, 1); len(lines) != 3 || !strings.HasPrefix(lines[2], "> Example:alice") {
+		t.Errorf("render() = %q", lines)
+	}
+
+	// Clipboard errors are shown
+	state.selected = 3
+	state.handleKey("c", func(string) error { return errors.New("no clipboard") }, now)
+	if state.message != "Error copying code for work:bob: no clipboard" {
+		t.Errorf("message = %s", state.message)
+	}
+
+	state.handleKey("q", clipboard, now)
+	if !state.quit {
+		t.Error("q did not quit")
+	}
+	state.quit = false
+	state.handleKey(keyInterrupt, clipboard, now)
+	if !state.quit {
+		t.Error("ctrl-c did not quit")
+	}
+}
+
+func TestRunInteractive(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	os.Remove(collectionFile.filename)
+
+	if err := runInteractive(nil, nil, 0); err == nil {
+		t.Error("runInteractive() with missing collection did not fail")
+	}
+
+	rootCmd := getRootCmd()
+	_ = rootCmd.Flags().Set(optionInteractive, "true")
+	_ = rootCmd.RunE(rootCmd, []string{"name0"})
+
+	_ = rootCmd.Flags().Set(optionSecret, "SEED")
+	_ = rootCmd.RunE(rootCmd, []string{})
+	_ = rootCmd.Flags().Set(optionSecret, "")
+
+	_ = rootCmd.Flags().Set(optionTime, "noon")
+	_ = rootCmd.RunE(rootCmd, []string{})
+}
@@ -0,0 +1,414 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	api "github.com/arcanericky/totp"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	optionFormat = "format"
+	optionOutput = "output"
+
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// outputFormat is the --output format of command results
+var outputFormat = outputText
+
+// validOutputFormats are the values of --output
+var validOutputFormats = []string{outputText, outputJSON, outputYAML}
+
+// structuredOutput reports whether results are output as JSON or YAML
+// documents instead of text
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// setOutputFormat sets the --output format
+func setOutputFormat(format string) error {
+	for _, f := range validOutputFormats {
+		if format == f {
+			outputFormat = format
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid output format %q, must be text, json, or yaml", format)
+}
+
+// outputFormatFlag is the --output flag value, which sets outputFormat
+type outputFormatFlag struct{}
+
+func (outputFormatFlag) String() string {
+	return outputFormat
+}
+
+func (outputFormatFlag) Set(format string) error {
+	return setOutputFormat(format)
+}
+
+func (outputFormatFlag) Type() string {
+	return "format"
+}
+
+// outputDocument writes v as a JSON document on one line, or as a YAML
+// document starting with "---", so a stream of documents can be parsed
+func outputDocument(writer io.Writer, v interface{}) error {
+	if outputFormat == outputYAML {
+		if _, err := fmt.Fprintln(writer, "---"); err != nil {
+			return err
+		}
+
+		encoder := yaml.NewEncoder(writer)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	}
+
+	return json.NewEncoder(writer).Encode(v)
+}
+
+// errorDocument is the structured output of an error
+type errorDocument struct {
+	Error string `json:"error" yaml:"error"`
+}
+
+// printError writes an error to stderr as "context: error" text, or as a
+// document in structured output
+func printError(context string, err error) {
+	if !structuredOutput() {
+		fmt.Fprintln(os.Stderr, context+":", err)
+		return
+	}
+
+	if err := outputDocument(os.Stderr, errorDocument{Error: context + ": " + err.Error()}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// printResult writes the result of a command as text, or as a document in
+// structured output. Like printResultf, nothing is output when the
+// collection is written to stdout.
+func printResult(document interface{}, format string, a ...interface{}) error {
+	if !structuredOutput() {
+		_, err := printResultf(format, a...)
+		return err
+	}
+
+	if collectionFile.useStdio {
+		return nil
+	}
+
+	return outputDocument(os.Stdout, document)
+}
+
+// resultDocument is the structured output of a command changing secrets
+// or the collection
+type resultDocument struct {
+	Result  string   `json:"result" yaml:"result"`
+	Name    string   `json:"name,omitempty" yaml:"name,omitempty"`
+	NewName string   `json:"new_name,omitempty" yaml:"new_name,omitempty"`
+	Names   []string `json:"names,omitempty" yaml:"names,omitempty"`
+	Counter *uint64  `json:"counter,omitempty" yaml:"counter,omitempty"`
+	File    string   `json:"file,omitempty" yaml:"file,omitempty"`
+	Backup  string   `json:"backup,omitempty" yaml:"backup,omitempty"`
+}
+
+// counterDocument is the structured output of an HOTP counter
+type counterDocument struct {
+	Name    string `json:"name" yaml:"name"`
+	Counter uint64 `json:"counter" yaml:"counter"`
+}
+
+// backupDocument is the structured output of a listed backup
+type backupDocument struct {
+	Time   time.Time `json:"time" yaml:"time"`
+	Backup string    `json:"backup" yaml:"backup"`
+}
+
+// restoreDocument is the structured output of a restore, with the names of
+// the secrets it adds and removes
+type restoreDocument struct {
+	Result  string   `json:"result" yaml:"result"`
+	Backup  string   `json:"backup" yaml:"backup"`
+	Added   []string `json:"added" yaml:"added"`
+	Removed []string `json:"removed" yaml:"removed"`
+}
+
+// migrationDocument is the structured output of a migration. The upgrades
+// are not saved in a dry run.
+type migrationDocument struct {
+	SchemaVersion int      `json:"schema_version" yaml:"schema_version"`
+	Upgrades      []string `json:"upgrades" yaml:"upgrades"`
+	DryRun        bool     `json:"dry_run" yaml:"dry_run"`
+}
+
+// qrCodeDocument is the structured output of a QR code, the key URI it
+// encodes
+type qrCodeDocument struct {
+	Name string `json:"name" yaml:"name"`
+	URI  string `json:"uri" yaml:"uri"`
+}
+
+// codeDocument is the structured output of a generated code. TOTP codes
+// have a period and expiry, and HOTP codes the counter they were generated
+// with.
+type codeDocument struct {
+	Name         string     `json:"name,omitempty" yaml:"name,omitempty"`
+	Issuer       string     `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	Account      string     `json:"account,omitempty" yaml:"account,omitempty"`
+	Tags         []string   `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Type         string     `json:"type" yaml:"type"`
+	Code         string     `json:"code,omitempty" yaml:"code,omitempty"`
+	Period       uint       `json:"period,omitempty" yaml:"period,omitempty"`
+	Remaining    int        `json:"remaining,omitempty" yaml:"remaining,omitempty"`
+	Expires      *time.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+	Counter      *uint64    `json:"counter,omitempty" yaml:"counter,omitempty"`
+	NextCode     string     `json:"next,omitempty" yaml:"next,omitempty"`
+	PreviousCode string     `json:"previous,omitempty" yaml:"previous,omitempty"`
+
+	// step generates the code a number of periods or counters from this one
+	step func(steps int) (string, error)
+	// showRemaining adds the seconds remaining to text output
+	showRemaining bool
+}
+
+// Next returns the code after this one, the code of the next period or
+// counter, for --format templates
+func (d codeDocument) Next() (string, error) {
+	return d.stepCode(1)
+}
+
+// stepCode returns the code a number of periods or counters from this one
+func (d codeDocument) stepCode(steps int) (string, error) {
+	if d.step == nil {
+		return "", errors.New("the next and previous codes are not available")
+	}
+
+	return d.step(steps)
+}
+
+// text returns the code followed by the details requested with the
+// --remaining, --next, and --previous options
+func (d codeDocument) text() string {
+	var details []string
+	if d.showRemaining {
+		details = append(details, fmt.Sprintf("%ds remaining", d.Remaining))
+	}
+	if len(d.NextCode) != 0 {
+		details = append(details, "next "+d.NextCode)
+	}
+	if len(d.PreviousCode) != 0 {
+		details = append(details, "previous "+d.PreviousCode)
+	}
+
+	if len(details) == 0 {
+		return d.Code
+	}
+
+	return d.Code + " (" + strings.Join(details, ", ") + ")"
+}
+
+// Previous returns the code before this one, the code of the previous
+// period or counter, for --format templates
+func (d codeDocument) Previous() (string, error) {
+	return d.stepCode(-1)
+}
+
+// remaining returns the time from t until the code expires, or the default
+// period for HOTP codes, which do not expire
+func (d codeDocument) remaining(t time.Time) time.Duration {
+	if d.Expires == nil {
+		return api.DefaultPeriod * time.Second
+	}
+
+	return d.Expires.Sub(t)
+}
+
+// newCodeDocument returns the document of a secret's code. For TOTP
+// secrets, t is the time the code was generated for.
+func newCodeDocument(secret api.Secret, code string, t time.Time) codeDocument {
+	opts := secret.Options()
+	document := codeDocument{
+		Name:    secret.Name,
+		Issuer:  secret.GetIssuer(),
+		Account: secret.GetAccount(),
+		Tags:    secret.Tags,
+		Type:    opts.GetType(),
+		Code:    code,
+	}
+
+	if secret.IsHOTP() {
+		counter := secret.Counter
+		document.Counter = &counter
+
+		document.step = func(steps int) (string, error) {
+			if steps < 0 && uint64(-steps) > counter {
+				return "", errors.New("there is no code before the first counter")
+			}
+
+			step := secret
+			step.Counter = counter + uint64(steps)
+			return step.GenerateCodeWithTime(t)
+		}
+
+		return document
+	}
+
+	period := opts.PeriodDuration()
+	expires := t.Add(durationToNextInterval(t, period))
+	document.Period = opts.GetPeriod()
+	document.Remaining = secondsRemaining(secret, t)
+	document.Expires = &expires
+	document.step = func(steps int) (string, error) {
+		return secret.GenerateCodeWithTime(t.Add(time.Duration(steps) * period))
+	}
+
+	return document
+}
+
+// codeDetails are the details output with each code: the seconds
+// remaining, and the codes of the next and previous periods
+type codeDetails struct {
+	remaining bool
+	next      bool
+	previous  bool
+}
+
+// any reports whether any details were requested
+func (d codeDetails) any() bool {
+	return d.remaining || d.next || d.previous
+}
+
+// wrap returns generate with the requested details added to each code
+func (d codeDetails) wrap(generate codeFunc) codeFunc {
+	if !d.any() {
+		return generate
+	}
+
+	return func(t time.Time) (codeDocument, error) {
+		document, err := generate(t)
+		if err != nil {
+			return document, err
+		}
+
+		document.showRemaining = d.remaining
+		if d.next {
+			if document.NextCode, err = document.Next(); err != nil {
+				return document, err
+			}
+		}
+		if d.previous {
+			if document.PreviousCode, err = document.Previous(); err != nil {
+				return document, err
+			}
+		}
+
+		return document, nil
+	}
+}
+
+// secretDocument is the structured output of a listed secret. The value
+// and notes are only included when all info is listed.
+type secretDocument struct {
+	Name         string    `json:"name" yaml:"name"`
+	Secret       string    `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Type         string    `json:"type" yaml:"type"`
+	Algorithm    string    `json:"algorithm" yaml:"algorithm"`
+	Digits       int       `json:"digits" yaml:"digits"`
+	Period       uint      `json:"period,omitempty" yaml:"period,omitempty"`
+	Counter      *uint64   `json:"counter,omitempty" yaml:"counter,omitempty"`
+	Issuer       string    `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	Account      string    `json:"account,omitempty" yaml:"account,omitempty"`
+	Tags         []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Notes        string    `json:"notes,omitempty" yaml:"notes,omitempty"`
+	DateAdded    time.Time `json:"date_added" yaml:"date_added"`
+	DateModified time.Time `json:"date_modified" yaml:"date_modified"`
+}
+
+func newSecretDocument(secret api.Secret, all bool) secretDocument {
+	opts := secret.Options()
+	document := secretDocument{
+		Name:         secret.Name,
+		Type:         opts.GetType(),
+		Algorithm:    opts.GetAlgorithm(),
+		Digits:       opts.GetDigits(),
+		Issuer:       secret.GetIssuer(),
+		Account:      secret.GetAccount(),
+		Tags:         secret.Tags,
+		DateAdded:    secret.DateAdded,
+		DateModified: secret.DateModified,
+	}
+
+	if secret.IsHOTP() {
+		counter := secret.Counter
+		document.Counter = &counter
+	} else {
+		document.Period = opts.GetPeriod()
+	}
+
+	if all {
+		document.Secret = secret.Value
+		document.Notes = secret.Notes
+	}
+
+	return document
+}
+
+// codeTemplate is the --format template codes are output with, if given
+var codeTemplate *template.Template
+
+// setCodeTemplate parses the --format template. Codes are output as text
+// or documents when it is empty.
+func setCodeTemplate(format string) error {
+	if len(format) == 0 {
+		codeTemplate = nil
+		return nil
+	}
+
+	tmpl, err := template.New(optionFormat).Option("missingkey=error").Parse(format)
+	if err != nil {
+		return err
+	}
+
+	codeTemplate = tmpl
+	return nil
+}
+
+// formatCode writes a code with the --format template followed by a new
+// line
+func formatCode(writer io.Writer, document codeDocument) error {
+	var builder strings.Builder
+	if err := codeTemplate.Execute(&builder, document); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(writer, builder.String())
+	return err
+}
+
+// versionDocument is the structured output of the version command
+type versionDocument struct {
+	Version string `json:"version" yaml:"version"`
+	OS      string `json:"os" yaml:"os"`
+	Arch    string `json:"arch" yaml:"arch"`
+}
+
+func newVersionDocument() versionDocument {
+	return versionDocument{Version: versionText, OS: runtime.GOOS, Arch: runtime.GOARCH}
+}
@@ -0,0 +1,467 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
+	"gopkg.in/yaml.v3"
+)
+
+// captureStderr returns what f writes to stderr
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := os.Stderr
+	os.Stderr = w
+	f()
+	os.Stderr = saved
+	w.Close()
+
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestOutputFormat(t *testing.T) {
+	defer func() { outputFormat = outputText }()
+
+	if err := setOutputFormat("xml"); err == nil || outputFormat != outputText {
+		t.Errorf("setOutputFormat(xml) = %v, format %s", err, outputFormat)
+	}
+
+	rootCmd := getRootCmd()
+	if err := rootCmd.PersistentFlags().Set(optionOutput, outputYAML); err != nil || !structuredOutput() {
+		t.Errorf("--output yaml error = %v, format %s", err, outputFormat)
+	}
+	if got := rootCmd.PersistentFlags().Lookup(optionOutput).Value.String(); got != outputYAML {
+		t.Errorf("--output value = %s", got)
+	}
+
+	// YAML documents are separated so streams can be parsed
+	writer := &bytes.Buffer{}
+	_ = outputDocument(writer, errorDocument{Error: "one"})
+	_ = outputDocument(writer, errorDocument{Error: "two"})
+	if writer.String() != "---\nerror: one\n---\nerror: two\n" {
+		t.Errorf("outputDocument() YAML = %q", writer.String())
+	}
+
+	_ = setOutputFormat(outputJSON)
+	writer.Reset()
+	_ = outputDocument(writer, errorDocument{Error: "one"})
+	if writer.String() != `{"error":"one"}`+"\n" {
+		t.Errorf("outputDocument() JSON = %q", writer.String())
+	}
+
+	// Errors
+	if got := captureStderr(t, func() { printError("Error testing", os.ErrNotExist) }); got != `{"error":"Error testing: file does not exist"}`+"\n" {
+		t.Errorf("printError() JSON = %q", got)
+	}
+	_ = setOutputFormat(outputText)
+	if got := captureStderr(t, func() { printError("Error testing", os.ErrNotExist) }); got != "Error testing: file does not exist\n" {
+		t.Errorf("printError() text = %q", got)
+	}
+
+	// Version
+	_ = setOutputFormat(outputJSON)
+	var version versionDocument
+	stdout := captureStdout(t, func() { getVersionCmd().Run(nil, nil) })
+	if err := json.Unmarshal([]byte(stdout), &version); err != nil || version != newVersionDocument() {
+		t.Errorf("version output = %q, %v", stdout, err)
+	}
+}
+
+func TestCodeDocuments(t *testing.T) {
+	codeTime := time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)
+	secret := totp.Secret{Name: "Example:alice", Value: "SEED", Period: 60, Tags: []string{"work"}}
+
+	document := newCodeDocument(secret, "123456", codeTime)
+	expires := time.Date(2019, 6, 23, 20, 1, 0, 0, time.UTC)
+	if document.Issuer != "Example" || document.Account != "alice" || document.Type != totp.TypeTOTP ||
+		document.Period != 60 || document.Remaining != 50 || !document.Expires.Equal(expires) || document.Counter != nil {
+		t.Errorf("newCodeDocument() = %+v", document)
+	}
+	if got := document.remaining(codeTime); got != 50*time.Second {
+		t.Errorf("remaining() = %v", got)
+	}
+
+	document = newCodeDocument(totp.Secret{Name: "counter", Value: "SEED", Type: totp.TypeHOTP, Counter: 4}, "123456", codeTime)
+	if document.Type != totp.TypeHOTP || document.Counter == nil || *document.Counter != 4 || document.Expires != nil || document.Period != 0 {
+		t.Errorf("newCodeDocument() HOTP = %+v", document)
+	}
+	if got := document.remaining(codeTime); got != totp.DefaultPeriod*time.Second {
+		t.Errorf("remaining() HOTP = %v", got)
+	}
+
+	// Secret values and notes are only listed with all info
+	secret.Notes = "notes"
+	if d := newSecretDocument(secret, false); d.Secret != "" || d.Notes != "" || d.Algorithm != totp.DefaultAlgorithm || d.Digits != totp.DefaultDigits || d.Period != 60 {
+		t.Errorf("newSecretDocument() = %+v", d)
+	}
+	if d := newSecretDocument(secret, true); d.Secret != "SEED" || d.Notes != "notes" {
+		t.Errorf("newSecretDocument() all = %+v", d)
+	}
+	if d := newSecretDocument(totp.Secret{Value: "SEED", Type: totp.TypeHOTP}, false); d.Counter == nil || d.Period != 0 {
+		t.Errorf("newSecretDocument() HOTP = %+v", d)
+	}
+}
+
+func TestStructuredOutput(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	collectionFile.useStdio = false
+	defer os.Remove(collectionFile.filename)
+	defer func() { outputFormat = outputText }()
+
+	createTestData(t)
+	_ = setOutputFormat(outputJSON)
+	codeTime := time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)
+
+	// Codes
+	writer := &bytes.Buffer{}
+	var code codeDocument
+	if err := generateCode(writer, secretCodeFunc(totp.Secret{Name: "name0", Value: "SEED"}), codeTime); err != nil {
+		t.Fatal("generateCode() error:", err)
+	}
+	want, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime)
+	if err := json.Unmarshal(writer.Bytes(), &code); err != nil || code.Code != want || code.Name != "name0" || code.Remaining != 20 {
+		t.Errorf("generateCode() = %q, %v", writer.String(), err)
+	}
+
+	writer.Reset()
+	var codes []codeDocument
+	if err := listAllCodes(writer, []string{"name[12]"}, nil, codeTime, nil); err != nil {
+		t.Fatal("listAllCodes() error:", err)
+	}
+	if err := json.Unmarshal(writer.Bytes(), &codes); err != nil || len(codes) != 2 || codes[0].Name != "name1" || codes[0].Code != want {
+		t.Errorf("listAllCodes() = %q, %v", writer.String(), err)
+	}
+
+	// Secret lists
+	writer.Reset()
+	var names []string
+	listSecrets(writer, []string{"name[12]"}, nil, true, false)
+	if err := json.Unmarshal(writer.Bytes(), &names); err != nil || strings.Join(names, ",") != "name1,name2" {
+		t.Errorf("listSecrets() names = %q, %v", writer.String(), err)
+	}
+
+	_ = setOutputFormat(outputYAML)
+	writer.Reset()
+	var secrets []secretDocument
+	listSecrets(writer, []string{"name1"}, nil, false, true)
+	if err := yaml.Unmarshal(writer.Bytes(), &secrets); err != nil || len(secrets) != 1 || secrets[0].Secret != "SEED" || secrets[0].DateAdded.IsZero() {
+		t.Errorf("listSecrets() all = %q, %v", writer.String(), err)
+	}
+
+	// Changes
+	_ = setOutputFormat(outputJSON)
+	stdout := captureStdout(t, func() {
+		updateSecret("newname", "SEED", secretOptionChanges{}, secretMetadataChanges{})
+		updateSecret("newname", "SEEDSEED", secretOptionChanges{}, secretMetadataChanges{})
+		renameSecret("newname", "renamed")
+		c, _ := collectionFile.loader()
+		deleteSecrets(c, []string{"renamed", "name0"})
+	})
+	wantLines := []string{
+		`{"result":"added","name":"newname"}`,
+		`{"result":"updated","name":"newname"}`,
+		`{"result":"renamed","name":"newname","new_name":"renamed"}`,
+		`{"result":"deleted","names":["renamed","name0"]}`,
+	}
+	if stdout != strings.Join(wantLines, "\n")+"\n" {
+		t.Errorf("change output = %q", stdout)
+	}
+
+	stderr := captureStderr(t, func() { updateSecret("missing", "", secretOptionChanges{}, secretMetadataChanges{}) })
+	if stderr != `{"error":"Error updating secret: a secret value is required to add a secret"}`+"\n" {
+		t.Errorf("update error output = %q", stderr)
+	}
+
+	// Nothing is output when the collection is written to stdout
+	collectionFile.useStdio = true
+	defer func() { collectionFile.useStdio = false }()
+	if err := printResult(resultDocument{Result: "added"}, "Added\n"); err != nil {
+		t.Error("printResult() error:", err)
+	}
+}
+
+func TestCodeTemplate(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+	defer func() { codeTemplate = nil }()
+
+	createTestData(t)
+	codeTime := time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)
+	code, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime)
+	next, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime.Add(30 * time.Second))
+
+	if err := setCodeTemplate("{{.Code"); err == nil {
+		t.Error("setCodeTemplate() with invalid template succeeded")
+	}
+
+	// Single codes
+	_ = setCodeTemplate(`{{.Name}} {{.Code}} {{.Next}} {{.Remaining}} {{.Expires.Format "15:04:05"}}`)
+	writer := &bytes.Buffer{}
+	if err := generateCode(writer, secretCodeFunc(totp.Secret{Name: "name0", Value: "SEED"}), codeTime); err != nil || writer.String() != "name0 "+code+" "+next+" 20 20:00:30\n" {
+		t.Errorf("generateCode() = %q, %v", writer.String(), err)
+	}
+
+	// HOTP codes have no expiry, and the next code is the next counter's
+	hotpNext, _ := totp.Secret{Value: "SEED", Type: totp.TypeHOTP, Counter: 1}.GenerateCodeWithTime(codeTime)
+	document := newCodeDocument(totp.Secret{Name: "counter", Value: "SEED", Type: totp.TypeHOTP}, "123456", codeTime)
+	_ = setCodeTemplate("{{.Counter}} {{.Next}}")
+	writer.Reset()
+	if err := outputCode(writer, document); err != nil || writer.String() != "0 "+hotpNext+"\n" {
+		t.Errorf("outputCode() HOTP = %q, %v", writer.String(), err)
+	}
+
+	_ = setCodeTemplate("{{.Expires.Unix}}")
+	if err := outputCode(writer, document); err == nil {
+		t.Error("outputCode() HOTP expiry succeeded")
+	}
+	if _, err := (codeDocument{}).Next(); err == nil {
+		t.Error("Next() without a generator succeeded")
+	}
+
+	// Multiple codes
+	_ = setCodeTemplate("{{.Name}}={{.Code}}")
+	writer.Reset()
+	if err := listAllCodes(writer, []string{"name[12]"}, nil, codeTime, nil); err != nil || !strings.HasPrefix(writer.String(), "name1="+code+"\nname2=") {
+		t.Errorf("listAllCodes() = %q, %v", writer.String(), err)
+	}
+
+	_ = setCodeTemplate("{{.Missing}}")
+	if err := listAllCodes(writer, []string{"name1"}, nil, codeTime, nil); err == nil {
+		t.Error("listAllCodes() with invalid field succeeded")
+	}
+
+	// The root command rejects the format option with structured output
+	defer func() { outputFormat = outputText }()
+	_ = setOutputFormat(outputJSON)
+	rootCmd := getRootCmd()
+	_ = rootCmd.Flags().Set(optionFormat, "{{.Code}}")
+	if stdout := captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) }); !strings.HasPrefix(stdout, "TOTP Generator") {
+		t.Errorf("root command output = %q, want help", stdout)
+	}
+}
+
+func TestCodeDetails(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+
+	// Codes of other periods use the secret's period
+	codeTime := time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)
+	secret := totp.Secret{Name: "name0", Value: "SEED", Period: 60}
+	code, _ := secret.GenerateCodeWithTime(codeTime)
+	next, _ := secret.GenerateCodeWithTime(codeTime.Add(60 * time.Second))
+	previous, _ := secret.GenerateCodeWithTime(codeTime.Add(-60 * time.Second))
+
+	writer := &bytes.Buffer{}
+	generate := codeDetails{remaining: true, next: true, previous: true}.wrap(secretCodeFunc(secret))
+	if err := generateCode(writer, generate, codeTime); err != nil || writer.String() != code+" (50s remaining, next "+next+", previous "+previous+")\n" {
+		t.Errorf("generateCode() = %q, %v", writer.String(), err)
+	}
+
+	writer.Reset()
+	generate = codeDetails{next: true}.wrap(secretCodeFunc(secret))
+	if err := generateCode(writer, generate, codeTime); err != nil || writer.String() != code+" (next "+next+")\n" {
+		t.Errorf("generateCode() next = %q, %v", writer.String(), err)
+	}
+
+	// Without details, codes are output alone
+	writer.Reset()
+	if err := generateCode(writer, (codeDetails{}).wrap(secretCodeFunc(secret)), codeTime); err != nil || writer.String() != code+"\n" {
+		t.Errorf("generateCode() = %q, %v", writer.String(), err)
+	}
+
+	if _, err := (codeDetails{previous: true}).wrap(secretCodeFunc(totp.Secret{Value: "invalidseed"}))(codeTime); err == nil {
+		t.Error("wrap() with invalid seed succeeded")
+	}
+
+	// Structured output includes the codes
+	defer func() { outputFormat = outputText }()
+	_ = setOutputFormat(outputJSON)
+	writer.Reset()
+	generate = codeDetails{next: true, previous: true}.wrap(secretCodeFunc(secret))
+	var document codeDocument
+	if err := generateCode(writer, generate, codeTime); err != nil {
+		t.Fatal("generateCode() error:", err)
+	}
+	if err := json.Unmarshal(writer.Bytes(), &document); err != nil || document.NextCode != next || document.PreviousCode != previous {
+		t.Errorf("generateCode() JSON = %q, %v", writer.String(), err)
+	}
+	_ = setOutputFormat(outputText)
+
+	// HOTP codes step by counter, with no code before the first
+	hotp := totp.Secret{Value: "SEED", Type: totp.TypeHOTP, Counter: 1}
+	hotpPrevious, _ := totp.Secret{Value: "SEED", Type: totp.TypeHOTP}.GenerateCodeWithTime(codeTime)
+	if got, err := newCodeDocument(hotp, "", codeTime).Previous(); err != nil || got != hotpPrevious {
+		t.Errorf("Previous() HOTP = %q, %v", got, err)
+	}
+	hotp.Counter = 0
+	if _, err := newCodeDocument(hotp, "", codeTime).Previous(); err == nil {
+		t.Error("Previous() of the first HOTP counter succeeded")
+	}
+
+	// The options are refused with all codes and HOTP secrets
+	rootCmd := getRootCmd()
+	_ = rootCmd.Flags().Set(optionNext, "true")
+	_ = rootCmd.Flags().Set(optionAll, "true")
+	if stdout := captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, nil) }); !strings.HasPrefix(stdout, "TOTP Generator") {
+		t.Errorf("root command output = %q, want help", stdout)
+	}
+
+	c, _ := collectionFile.loader()
+	_, _ = c.UpdateSecretWithOptions("counter", "SEED", totp.SecretOptions{Type: totp.TypeHOTP})
+	_ = c.Save()
+	rootCmd = getRootCmd()
+	_ = rootCmd.Flags().Set(optionRemaining, "true")
+	stderr := captureStderr(t, func() { _ = rootCmd.RunE(rootCmd, []string{"counter"}) })
+	if c, _ = collectionFile.loader(); !strings.Contains(stderr, "HOTP") {
+		t.Errorf("root command error output = %q", stderr)
+	}
+	if secret, _ := c.GetSecret("counter"); secret.Counter != 0 {
+		t.Errorf("counter = %d, want 0", secret.Counter)
+	}
+}
+
+func TestStructuredConfigOutput(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	collectionFile.useStdio = false
+	collectionFile.backups = 2
+	defer func() { collectionFile.backups = 0 }()
+	defer removeTestBackups()
+	defer os.Remove(collectionFile.filename)
+	defer func() { outputFormat = outputText }()
+
+	removeTestBackups()
+	createTestData(t)
+	_ = setOutputFormat(outputJSON)
+
+	// Counters
+	stdout := captureStdout(t, func() {
+		importSecrets([]totp.Secret{{Name: "counter", Value: "SEED", Type: totp.TypeHOTP}}, false)
+		setCounter("counter", "5")
+		showCounter("counter")
+	})
+	wantLines := []string{
+		`{"result":"imported","names":["counter"]}`,
+		`{"result":"counter set","name":"counter","counter":5}`,
+		`{"name":"counter","counter":5}`,
+	}
+	if stdout != strings.Join(wantLines, "\n")+"\n" {
+		t.Errorf("counter output = %q", stdout)
+	}
+
+	stderr := captureStderr(t, func() { showCounter("name0") })
+	if stderr != `{"error":"Error getting counter: `+totp.ErrNotHOTP.Error()+`"}`+"\n" {
+		t.Errorf("counter error output = %q", stderr)
+	}
+
+	// Backups, of which counter changes keep none
+	writer := &bytes.Buffer{}
+	var backups []backupDocument
+	if err := listBackups(writer, collectionFile.filename); err != nil {
+		t.Fatal("listBackups() error:", err)
+	}
+	if err := json.Unmarshal(writer.Bytes(), &backups); err != nil || len(backups) != 1 || !strings.HasPrefix(backups[0].Backup, "testcollection.json.") {
+		t.Errorf("listBackups() = %q, %v", writer.String(), err)
+	}
+
+	// Exports, transfers, and QR codes
+	exportFile := filepath.Join(t.TempDir(), "export.json")
+	writer.Reset()
+	if err := exportSecrets(writer, []string{"name[12]"}, nil, totp.ExportJSON, exportFile); err != nil || writer.String() != `{"result":"exported","names":["name1","name2"],"file":"`+exportFile+`"}`+"\n" {
+		t.Errorf("exportSecrets() = %q, %v", writer.String(), err)
+	}
+
+	writer.Reset()
+	var uris []string
+	if err := transferSecrets(writer, []string{"name1"}, nil, totp.DefaultMigrationBatchSize, false); err != nil {
+		t.Fatal("transferSecrets() error:", err)
+	}
+	if err := json.Unmarshal(writer.Bytes(), &uris); err != nil || len(uris) != 1 || !strings.HasPrefix(uris[0], "otpauth-migration://") {
+		t.Errorf("transferSecrets() = %q, %v", writer.String(), err)
+	}
+
+	writer.Reset()
+	var qr qrCodeDocument
+	if err := qrCode(writer, "testname", "", totp.SecretOptions{}); err != nil {
+		t.Fatal("qrCode() error:", err)
+	}
+	if err := json.Unmarshal(writer.Bytes(), &qr); err != nil || qr != (qrCodeDocument{Name: "testname", URI: "otpauth://totp/testname?issuer=testname&secret=TESTSECRET"}) {
+		t.Errorf("qrCode() = %q, %v", writer.String(), err)
+	}
+
+	stderr = captureStderr(t, func() { _ = qrCode(writer, "", "", totp.SecretOptions{}) })
+	if stderr != `{"error":"Error generating qr code: a name is required for QR code generation"}`+"\n" {
+		t.Errorf("qrCode() error output = %q", stderr)
+	}
+
+	// Argument errors
+	listCmd := getConfigListCmd()
+	_ = listCmd.Flags().Set("names", "true")
+	_ = listCmd.Flags().Set("all", "true")
+	for _, tc := range []struct {
+		run  func()
+		want string
+	}{
+		{func() { getConfigUpdateCmd(getRootCmd()).Run(nil, nil) }, "Error updating secret: must provide name and secret"},
+		{func() { updateSecret(cmdVerify, "SEED", secretOptionChanges{}, secretMetadataChanges{}) }, `Error updating secret: the name \"verify\" is reserved for the verify command`},
+		{func() { getConfigRenameCmd(getRootCmd()).Run(nil, []string{"name0"}) }, "Error renaming secret: must provide source and target"},
+		{func() { renameSecret("name0", cmdVerify) }, `Error renaming secret: the name \"verify\" is reserved for the verify command`},
+		{func() { listCmd.Run(listCmd, nil) }, "Error listing secrets: only one of --names or --all can be used"},
+	} {
+		if stderr := captureStderr(t, tc.run); stderr != `{"error":"`+tc.want+`"}`+"\n" {
+			t.Errorf("error output = %q, want %q", stderr, tc.want)
+		}
+	}
+
+	// Migrations
+	writer.Reset()
+	if err := migrateCollection(writer, true); err != nil || writer.String() != `{"schema_version":`+strconv.Itoa(totp.SchemaVersion)+`,"upgrades":[],"dry_run":true}`+"\n" {
+		t.Errorf("migrateCollection() = %q, %v", writer.String(), err)
+	}
+
+	// Verification errors
+	stderr = captureStderr(t, func() {
+		if status := verifyCode(writer, []string{"name0"}, verifyVars{}); status != verifyExitError {
+			t.Errorf("verifyCode() = %d", status)
+		}
+	})
+	if !strings.HasPrefix(stderr, `{"error":"Error verifying code: `) {
+		t.Errorf("verifyCode() error output = %q", stderr)
+	}
+
+	// Resets and restores
+	stdout = captureStdout(t, func() { _ = configReset(collectionFile.filename) })
+	var reset resultDocument
+	if err := json.Unmarshal([]byte(stdout), &reset); err != nil || reset.Result != "reset" || reset.File != collectionFile.filename || !strings.HasPrefix(reset.Backup, "testcollection.json.") {
+		t.Errorf("configReset() = %q, %v", stdout, err)
+	}
+
+	writer.Reset()
+	var restore restoreDocument
+	if err := restoreBackup(writer, nil, reset.Backup, true); err != nil {
+		t.Fatal("restoreBackup() error:", err)
+	}
+	if err := json.Unmarshal(writer.Bytes(), &restore); err != nil || restore.Result != "restored" || len(restore.Added) != 7 || len(restore.Removed) != 0 {
+		t.Errorf("restoreBackup() = %q, %v", writer.String(), err)
+	}
+}
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	api "github.com/arcanericky/totp"
+	"golang.org/x/term"
+)
+
+const (
+	envPassphrase       = "TOTP_PASSPHRASE"
+	envImportPassphrase = "TOTP_IMPORT_PASSPHRASE"
+)
+
+var errPassphraseMismatch = errors.New("passphrases do not match")
+
+// passphraseReader reads a passphrase after displaying a prompt. It is a
+// variable so tests can avoid the terminal.
+var passphraseReader = readPassphraseFromTerminal
+
+// openTerminal opens the controlling terminal so passphrases can be read
+// even when stdin is used for collection data
+func openTerminal() (*os.File, error) {
+	if runtime.GOOS == "windows" {
+		return os.OpenFile("CONIN$", os.O_RDWR, 0)
+	}
+
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
+}
+
+func readPassphraseFromTerminal(prompt string) (string, error) {
+	tty, err := openTerminal()
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	return string(passphrase), nil
+}
+
+// getPassphrase returns the passphrase for an encrypted collection from
+// the environment or, if not set, from the terminal
+func getPassphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv(envPassphrase); ok {
+		return passphrase, nil
+	}
+
+	return passphraseReader("Collection passphrase: ")
+}
+
+// getImportPassphrase returns the password for an encrypted import file
+// from the environment or, if not set, from the terminal
+func getImportPassphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv(envImportPassphrase); ok {
+		return passphrase, nil
+	}
+
+	return passphraseReader("Import file password: ")
+}
+
+// getNewPassphrase returns a new passphrase from the environment or, if not
+// set, from the terminal with confirmation
+func getNewPassphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv(envPassphrase); ok {
+		return passphrase, nil
+	}
+
+	passphrase, err := passphraseReader("New collection passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	confirm, err := passphraseReader("Confirm collection passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase != confirm {
+		return "", errPassphraseMismatch
+	}
+
+	return passphrase, nil
+}
+
+// isPassphraseError reports whether a load error was caused by a missing or
+// wrong passphrase, in which case the collection must not be overwritten
+func isPassphraseError(err error) bool {
+	return errors.Is(err, api.ErrPassphraseRequired) ||
+		errors.Is(err, api.ErrDecryptionFailed) ||
+		errors.Is(err, api.ErrUnsupportedEncryption)
+}
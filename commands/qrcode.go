@@ -3,28 +3,54 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"image"
+	_ "image/jpeg" // register JPEG decoding for QR code images
+	_ "image/png"  // register PNG decoding for QR code images
 	"io"
-	"os"
-	"strings"
+	"net/url"
 	"time"
 
-	"github.com/pquerna/otp/totp"
+	api "github.com/arcanericky/totp"
+	"github.com/makiuchi-d/gozxing"
+	zxingqrcode "github.com/makiuchi-d/gozxing/qrcode"
 	"github.com/skip2/go-qrcode"
 )
 
-func getQrString(name, secret string) string {
-	secret = strings.ToUpper(secret)
+// getQrString returns the key URI of the secret for a QR code. A secret
+// without an issuer uses its name as the issuer so authenticator apps
+// display it as they always have.
+func getQrString(secret api.Secret) string {
+	uri := secret.URI()
+	if len(secret.GetIssuer()) != 0 {
+		return uri
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	query := u.Query()
+	query.Set("issuer", secret.Name)
+	u.RawQuery = query.Encode()
 
-	// https://github.com/google/google-authenticator/wiki/Key-Uri-Format
-	// otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example
-	return fmt.Sprintf("otpauth://totp/%s?secret=%s&issuer=%s", name, secret, name)
+	return u.String()
 }
 
-func outputQrCode(writer io.Writer, name, secret string) error {
-	qrString := getQrString(name, secret)
+// outputQrCode writes the QR code of the secret, or its key URI as a
+// document in structured output
+func outputQrCode(writer io.Writer, secret api.Secret) error {
+	if structuredOutput() {
+		return outputDocument(writer, qrCodeDocument{Name: secret.Name, URI: getQrString(secret)})
+	}
+
+	return printQrCode(writer, getQrString(secret))
+}
+
+func printQrCode(writer io.Writer, qrString string) error {
 	q, err := qrcode.New(qrString, qrcode.Medium)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error generating qr code:", err)
+		printError("Error generating qr code", err)
 		return err
 	}
 	fmt.Fprint(writer, q.ToSmallString(false))
@@ -32,32 +58,54 @@ func outputQrCode(writer io.Writer, name, secret string) error {
 	return nil
 }
 
-func qrCode(writer io.Writer, name, secret string) error {
+func qrCode(writer io.Writer, name, secret string, opts api.SecretOptions) error {
 	if len(name) == 0 {
-		fmt.Fprintln(os.Stderr, "Name required for QR code generation")
-		return errors.New("name required")
+		err := errors.New("a name is required for QR code generation")
+		printError("Error generating qr code", err)
+		return err
 	}
 
 	if len(secret) != 0 {
-		_, err := totp.GenerateCode(secret, time.Now())
+		s, _, _ := getSecret(name, secret, opts)
+		_, err := s.GenerateCodeWithTime(time.Now())
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Invalid secret:", err)
+			printError("Invalid secret", err)
 			return err
 		}
-		return outputQrCode(writer, name, secret)
+		return outputQrCode(writer, s)
 	}
 
 	c, err := collectionFile.loader()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading collection:", err)
+		printError("Error loading collection", err)
 		return err
 	}
 
 	s, err := c.GetSecret(name)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to get collection entry for %s: %s\n", name, err)
+		printError("Failed to get collection entry for "+name, err)
 		return err
 	}
 
-	return outputQrCode(writer, s.Name, s.Value)
+	return outputQrCode(writer, s)
+}
+
+// decodeQrCode returns the text of the QR code in a PNG or JPEG image
+func decodeQrCode(reader io.Reader) (string, error) {
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return "", err
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := zxingqrcode.NewQRCodeReader().Decode(bmp, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return result.GetText(), nil
 }
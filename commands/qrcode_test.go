@@ -3,53 +3,57 @@ package commands
 import (
 	"bytes"
 	"encoding/base64"
+	"image"
+	imagepng "image/png"
 	"math/rand"
 	"os"
 	"testing"
+
+	api "github.com/arcanericky/totp"
+	"github.com/skip2/go-qrcode"
 )
 
 var testQrCode = `4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI
 4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI4paI
 4paI4paI4paICuKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKW
 iOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKW
-iOKWiOKWiOKWiOKWiOKWiOKWiArilojilojilojilogg4paE4paE4paE4paE4paEIOKWiOKWhOKW
-hOKWgOKWiOKWiCDiloDiloQg4paI4paE4paA4paI4paA4paE4paE4paA4paIIOKWhOKWhOKWhOKW
-hOKWhCDilojilojilojilogK4paI4paI4paI4paIIOKWiCAgIOKWiCDilojiloDiloAg4paI4paA
-4paI4paEIOKWgOKWhOKWgOKWiOKWgOKWhOKWiCAg4paIIOKWiCAgIOKWiCDilojilojilojilogK
-4paI4paI4paI4paIIOKWiOKWhOKWhOKWhOKWiCDilogg4paEIOKWhOKWgCDilojiloDiloTiloTi
-loTiloDilojilojiloTiloTilojilogg4paI4paE4paE4paE4paIIOKWiOKWiOKWiOKWiAriloji
-lojilojilojiloTiloTiloTiloTiloTiloTiloTilogg4paAIOKWiCDilojiloTilojiloTiloAg
-4paA4paE4paAIOKWiOKWhOKWiOKWhOKWhOKWhOKWhOKWhOKWhOKWhOKWiOKWiOKWiOKWiAriloji
-lojilojilojiloTilojiloDilogg4paI4paE4paEIOKWiOKWgOKWiOKWiOKWiOKWhCDiloAg4paA
-4paE4paE4paE4paI4paA4paI4paE4paE4paE4paEIOKWgOKWiCDilojilojilojilogK4paI4paI
-4paI4paIIOKWgCAgIOKWgOKWhOKWgOKWiOKWiOKWiOKWgOKWhCDiloTiloQg4paA4paE4paA4paA
-IOKWiOKWhCDilojiloAg4paE4paE4paIIOKWiOKWiOKWiOKWiOKWiArilojilojilojilojiloDi
-loTilojiloTilogg4paE4paA4paE4paA4paIIOKWhOKWhOKWgOKWiOKWhOKWhOKWgOKWiOKWiCDi
-loQg4paI4paAICDilojiloTiloDilojiloTilojilojilojilogK4paI4paI4paI4paI4paIIOKW
-hCDiloDiloTiloTilojiloQg4paE4paE4paI4paIICDiloAgIOKWiCDiloDilogg4paE4paIIOKW
-gOKWgOKWiOKWhCDiloDilojilojilojilogK4paI4paI4paI4paI4paI4paE4paA4paI4paE4paA
-4paE4paI4paA4paA4paE4paIIOKWiOKWgOKWgCDiloTiloTiloTiloTiloQgIOKWhCDiloTiloDi
-loTiloQg4paA4paA4paI4paI4paI4paICuKWiOKWiOKWiOKWiOKWiOKWgOKWiCAg4paE4paE4paA
-4paEIOKWgOKWhCDilojiloDiloTiloAg4paA4paI4paAIOKWiCDiloAg4paI4paEIOKWhOKWiOKW
-hOKWiOKWiOKWiOKWiOKWiArilojilojilojilogg4paE4paA4paE4paI4paA4paE4paI4paA4paA
-4paIIOKWiOKWhOKWiOKWiOKWgCDiloDiloTiloTiloQg4paA4paE4paIIOKWgOKWiOKWgOKWgOKW
-gOKWhOKWiOKWiOKWiOKWiArilojilojilojilojilojilojilojiloTiloTiloTiloTiloTilogg
-4paE4paE4paE4paA4paA4paI4paE4paAIOKWiOKWgCDiloDiloTiloAgIOKWiCDiloTilogg4paI
-4paI4paI4paI4paICuKWiOKWiOKWiOKWiOKWhOKWhOKWiOKWiOKWiOKWiOKWhOKWiCAgIOKWiOKW
-gCDilogg4paAIOKWiOKWhOKWiOKWhOKWhOKWgCDiloTiloTiloQg4paEIOKWhOKWgOKWiOKWiOKW
-iOKWiArilojilojilojilogg4paE4paE4paE4paE4paEIOKWiOKWhOKWiOKWiCAg4paI4paAIOKW
-iCAg4paA4paE4paAIOKWiCDilojiloTilogg4paI4paIIOKWiOKWiOKWiOKWiOKWiArilojiloji
-lojilogg4paIICAg4paIIOKWiOKWhOKWiCDiloTilogg4paI4paI4paI4paE4paA4paE4paI4paE
-4paA4paA4paEICAgIOKWhOKWgOKWiOKWiOKWiOKWiOKWiOKWiArilojilojilojilogg4paI4paE
-4paE4paE4paIIOKWiOKWiOKWhOKWiOKWiCDiloAgIOKWiOKWgOKWgOKWiCAg4paI4paAIOKWgOKW
-hOKWiCDilojiloTilojilojilojilojilojilogK4paI4paI4paI4paI4paE4paE4paE4paE4paE
-4paE4paE4paI4paE4paI4paE4paE4paE4paE4paE4paI4paI4paE4paI4paI4paI4paE4paE4paI
-4paE4paE4paE4paE4paI4paI4paE4paI4paE4paI4paI4paI4paICuKWiOKWiOKWiOKWiOKWiOKW
-iOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKW
-iOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiOKWiAriloDiloDi
-loDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDi
-loDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDiloDi
-loAK`
+iOKWiOKWiOKWiOKWiOKWiOKWiArilojilojilojilogg4paE4paE4paE4paE4paEIOKWiOKWhCDi
+lojiloTiloTiloDiloDilojiloDilojiloTilojiloDiloTiloDilojilojilogg4paE4paE4paE
+4paE4paEIOKWiOKWiOKWiOKWiArilojilojilojilogg4paIICAg4paIIOKWiOKWhCDiloTilogg
+4paIIOKWhOKWgOKWhOKWiOKWhOKWhCDiloDiloTilojilogg4paIICAg4paIIOKWiOKWiOKWiOKW
+iArilojilojilojilogg4paI4paE4paE4paE4paIIOKWiOKWgOKWgOKWiOKWiCAg4paE4paEIOKW
+iOKWgOKWgOKWgOKWhOKWiOKWiOKWiOKWiCDilojiloTiloTiloTilogg4paI4paI4paI4paICuKW
+iOKWiOKWiOKWiOKWhOKWhOKWhOKWhOKWhOKWhOKWhOKWiOKWhOKWgCDiloAg4paI4paE4paI4paE
+4paIIOKWgOKWhOKWiOKWhOKWgOKWhOKWiOKWhOKWhOKWhOKWhOKWhOKWhOKWhOKWiOKWiOKWiOKW
+iArilojilojilojilojiloTiloAg4paA4paI4paI4paE4paE4paA4paA4paIICDiloggICDiloDi
+loggIOKWiOKWhCDiloDiloDiloDiloTilojiloDiloTiloAg4paI4paI4paI4paICuKWiOKWiOKW
+iOKWiOKWgCDiloTilojiloDiloDiloQg4paIIOKWhCDiloDiloQg4paE4paA4paAICAg4paE4paI
+IOKWgOKWgOKWhOKWhCAg4paE4paA4paE4paI4paI4paI4paICuKWiOKWiOKWiOKWiCDiloTiloDi
+loDiloDilojiloTiloDilojiloQg4paA4paA4paEIOKWhCDilojiloTiloDilojiloAg4paA4paA
+4paEICDilojilojilojiloAg4paI4paI4paI4paICuKWiOKWiOKWiOKWiOKWiOKWiOKWhOKWgOKW
+iOKWhOKWhOKWgOKWhOKWgOKWiOKWgOKWgCDiloTiloAg4paE4paA4paE4paI4paI4paAIOKWiOKW
+hOKWgOKWgCDiloDilojiloAg4paI4paI4paI4paICuKWiOKWiOKWiOKWiOKWgOKWgOKWhOKWhOKW
+gOKWgOKWhCDilojiloQg4paA4paAIOKWgOKWgOKWgOKWgCAgIOKWgOKWgCAg4paEICDilojiloji
+lojilojiloDilojilojilojilogK4paI4paI4paI4paIICAg4paIIOKWgOKWhOKWhCDiloDiloji
+loDiloTilojiloQg4paI4paEIOKWhCDiloTiloDiloQg4paIICDiloQg4paE4paI4paE4paI4paI
+4paI4paICuKWiOKWiOKWiOKWiOKWhOKWiOKWiCDiloTiloTiloTilojiloQg4paEICDiloDiloTi
+loTilojiloAgIOKWhOKWiOKWhCAg4paAICDilogg4paI4paIIOKWiOKWiOKWiOKWiArilojiloji
+lojilojiloTiloTilojilogg4paI4paE4paE4paA4paA4paE4paE4paE4paE4paI4paE4paI4paI
+4paA4paA4paA4paE4paI4paE4paA4paA4paA4paI4paEIOKWhOKWgCDilojilojilojilogK4paI
+4paI4paI4paI4paE4paE4paE4paE4paI4paI4paE4paI4paA4paEICAgIOKWhCDiloDilojilogg
+4paA4paI4paIICDiloTiloTiloQg4paE4paI4paA4paA4paI4paI4paI4paICuKWiOKWiOKWiOKW
+iCDiloTiloTiloTiloTiloQg4paI4paE4paI4paIIOKWhCDiloQg4paI4paE4paEIOKWiOKWhOKW
+hOKWgCDilojiloTilogg4paA4paE4paA4paE4paI4paI4paI4paICuKWiOKWiOKWiOKWiCDilogg
+ICDilogg4paI4paI4paA4paE4paA4paI4paI4paA4paEICDiloQg4paE4paE4paAICDiloTiloQg
+IOKWgOKWgOKWiOKWgOKWiOKWiOKWiOKWiArilojilojilojilogg4paI4paE4paE4paE4paIIOKW
+iOKWhOKWiOKWiCAg4paI4paE4paAIOKWhCDiloTiloDiloTiloDilojiloDiloTilojiloDiloQg
+4paI4paE4paE4paI4paI4paI4paICuKWiOKWiOKWiOKWiOKWhOKWhOKWhOKWhOKWhOKWhOKWhOKW
+iOKWhOKWhOKWiOKWiOKWiOKWiOKWhOKWiOKWiOKWiOKWiOKWhOKWiOKWiOKWiOKWiOKWhOKWiOKW
+iOKWiOKWhOKWhOKWiOKWiOKWhOKWiOKWiOKWiOKWiArilojilojilojilojilojilojilojiloji
+lojilojilojilojilojilojilojilojilojilojilojilojilojilojilojilojilojilojiloji
+lojilojilojilojilojilojilojilojilojilojilojilojilojilogK4paA4paA4paA4paA4paA
+4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA
+4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paA4paACg==`
 
 func RandStringBytes(n int) string {
 	const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -63,8 +67,7 @@ func RandStringBytes(n int) string {
 
 func Test_getQrString(t *testing.T) {
 	type args struct {
-		name   string
-		secret string
+		secret api.Secret
 	}
 	tests := []struct {
 		name string
@@ -74,23 +77,63 @@ func Test_getQrString(t *testing.T) {
 		{
 			name: "success",
 			args: args{
-				name:   "testname",
-				secret: "testsecret",
+				secret: api.Secret{Name: "testname", Value: "testsecret"},
 			},
-			want: "otpauth://totp/testname?secret=TESTSECRET&issuer=testname",
+			want: "otpauth://totp/testname?issuer=testname&secret=TESTSECRET",
 		},
 		{
 			name: "success",
 			args: args{
-				name:   "testname",
-				secret: "TESTSECRET",
+				secret: api.Secret{Name: "testname", Value: "TESTSECRET"},
+			},
+			want: "otpauth://totp/testname?issuer=testname&secret=TESTSECRET",
+		},
+		{
+			name: "default options",
+			args: args{
+				secret: api.Secret{Name: "testname", Value: "TESTSECRET", Algorithm: "SHA1", Digits: 6, Period: 30},
+			},
+			want: "otpauth://totp/testname?issuer=testname&secret=TESTSECRET",
+		},
+		{
+			name: "custom options",
+			args: args{
+				secret: api.Secret{Name: "testname", Value: "TESTSECRET", Algorithm: "sha256", Digits: 8, Period: 60},
+			},
+			want: "otpauth://totp/testname?algorithm=SHA256&digits=8&issuer=testname&period=60&secret=TESTSECRET",
+		},
+		{
+			name: "issuer in name",
+			args: args{
+				secret: api.Secret{Name: "Example:alice", Value: "TESTSECRET"},
+			},
+			want: "otpauth://totp/Example:alice?issuer=Example&secret=TESTSECRET",
+		},
+		{
+			name: "issuer and account",
+			args: args{
+				secret: api.Secret{Name: "testname", Value: "TESTSECRET", Issuer: "Example", Account: "bob"},
+			},
+			want: "otpauth://totp/Example:bob?issuer=Example&secret=TESTSECRET",
+		},
+		{
+			name: "escaped",
+			args: args{
+				secret: api.Secret{Name: "My Bank&Co", Value: "TESTSECRET", Account: "alice smith"},
+			},
+			want: "otpauth://totp/alice%20smith?issuer=My+Bank%26Co&secret=TESTSECRET",
+		},
+		{
+			name: "hotp",
+			args: args{
+				secret: api.Secret{Name: "testname", Value: "TESTSECRET", Type: "hotp", Counter: 5, Period: 60},
 			},
-			want: "otpauth://totp/testname?secret=TESTSECRET&issuer=testname",
+			want: "otpauth://hotp/testname?counter=5&issuer=testname&secret=TESTSECRET",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := getQrString(tt.args.name, tt.args.secret); got != tt.want {
+			if got := getQrString(tt.args.secret); got != tt.want {
 				t.Errorf("getQrString() = %v, want %v", got, tt.want)
 			}
 		})
@@ -105,6 +148,7 @@ func Test_qrCode(t *testing.T) {
 	type args struct {
 		name   string
 		secret string
+		opts   api.SecretOptions
 	}
 	tests := []struct {
 		name       string
@@ -173,12 +217,23 @@ func Test_qrCode(t *testing.T) {
 			wantWriter: "",
 			wantErr:    true,
 		},
+		{
+			name:     "invalid options",
+			filename: "testcollection.json",
+			args: args{
+				name:   "testname",
+				secret: "testsecret",
+				opts:   api.SecretOptions{Algorithm: "invalid"},
+			},
+			wantWriter: "",
+			wantErr:    true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			collectionFile.filename = tt.filename
 			writer := &bytes.Buffer{}
-			if err := qrCode(writer, tt.args.name, tt.args.secret); (err != nil) != tt.wantErr {
+			if err := qrCode(writer, tt.args.name, tt.args.secret, tt.args.opts); (err != nil) != tt.wantErr {
 				t.Errorf("qrCode() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -234,7 +289,7 @@ func Test_outputQrCode(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			writer := &bytes.Buffer{}
-			if err := outputQrCode(writer, tt.args.name, tt.args.secret); (err != nil) != tt.wantErr {
+			if err := outputQrCode(writer, api.Secret{Name: tt.args.name, Value: tt.args.secret}); (err != nil) != tt.wantErr {
 				t.Errorf("outputQrCode() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -244,3 +299,27 @@ func Test_outputQrCode(t *testing.T) {
 		})
 	}
 }
+
+func Test_decodeQrCode(t *testing.T) {
+	const want = "otpauth://totp/testname?secret=TESTSECRET&issuer=testname"
+	png, err := qrcode.Encode(want, qrcode.Medium, 256)
+	if err != nil {
+		t.Fatal("Error encoding QR code:", err)
+	}
+
+	got, err := decodeQrCode(bytes.NewReader(png))
+	if err != nil || got != want {
+		t.Errorf("decodeQrCode() = %v, %v, want %v", got, err, want)
+	}
+
+	blank := image.NewGray(image.Rect(0, 0, 64, 64))
+	buf := &bytes.Buffer{}
+	_ = imagepng.Encode(buf, blank)
+	if _, err := decodeQrCode(buf); err == nil {
+		t.Error("decodeQrCode() of image without QR code did not fail")
+	}
+
+	if _, err := decodeQrCode(bytes.NewReader([]byte("not an image"))); err == nil {
+		t.Error("decodeQrCode() of invalid image did not fail")
+	}
+}
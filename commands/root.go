@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -8,33 +9,82 @@ import (
 	"time"
 
 	api "github.com/arcanericky/totp"
-	"github.com/pquerna/otp/totp"
 	"github.com/spf13/cobra"
 )
 
 const (
-	optionBackward = "backward"
-	optionFile     = "file"
-	optionFollow   = "follow"
-	optionForward  = "forward"
-	optionQr       = "qrcode"
-	optionSecret   = "secret"
-	optionStdio    = "stdio"
-	optionTime     = "time"
-	optionYes      = "yes"
+	optionAccount     = "account"
+	optionAll         = "all"
+	optionAlgorithm   = "algorithm"
+	optionBackward    = "backward"
+	optionClear       = "clear"
+	optionCopy        = "copy"
+	optionDigits      = "digits"
+	optionFile        = "file"
+	optionFollow      = "follow"
+	optionForward     = "forward"
+	optionInteractive = "interactive"
+	optionIssuer      = "issuer"
+	optionNext        = "next"
+	optionNotes       = "notes"
+	optionNTP         = "ntp"
+	optionNTPServer   = "ntp-server"
+	optionOverwrite   = "overwrite"
+	optionPeriod      = "period"
+	optionPrevious    = "previous"
+	optionQr          = "qrcode"
+	optionRemaining   = "remaining"
+	optionSecret      = "secret"
+	optionStdio       = "stdio"
+	optionTag         = "tag"
+	optionTime        = "time"
+	optionType        = "type"
+	optionYes         = "yes"
 )
 
-type generateCodesAPI func(time.Duration, time.Duration, time.Duration, func(time.Duration), string, string)
+// exitError is returned by a command that reports its result through the
+// exit status and has already written any output
+type exitError struct {
+	status int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.status)
+}
+
+// codeFunc generates the code at a time
+type codeFunc func(time.Time) (codeDocument, error)
+
+// secretCodeFunc returns a codeFunc generating the codes of a TOTP secret
+func secretCodeFunc(secret api.Secret) codeFunc {
+	return func(t time.Time) (codeDocument, error) {
+		code, err := secret.GenerateCodeWithTime(t)
+		return newCodeDocument(secret, code, t), err
+	}
+}
+
+type generateCodesAPI func(clock, time.Duration, time.Duration, time.Duration, codeFunc)
 
 type runVars struct {
-	secret     string
-	backward   time.Duration
-	forward    time.Duration
-	timeString string
-	follow     bool
-	useStdio   bool
-	cfgFile    string
-	qr         bool
+	secret      string
+	opts        api.SecretOptions
+	backward    time.Duration
+	forward     time.Duration
+	timeString  string
+	follow      bool
+	useStdio    bool
+	cfgFile     string
+	qr          bool
+	all         bool
+	interactive bool
+	tags        []string
+	copy        bool
+	clearCopy   bool
+	format      string
+	details     codeDetails
+	validity    codeValidity
+	ntp         bool
+	ntpServer   string
 }
 
 var generateCodesService generateCodesAPI
@@ -48,7 +98,7 @@ func getSecretNamesForCompletion(toComplete string) []string {
 
 	c, err = collectionFile.loader()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading collection:", err)
+		printError("Error loading collection", err)
 	} else {
 		secrets := c.GetSecrets()
 		for _, s := range secrets {
@@ -61,88 +111,144 @@ func getSecretNamesForCompletion(toComplete string) []string {
 	return secretNames
 }
 
-func generateCode(writer io.Writer, name string, secret string, t time.Time) error {
-	const errGen = "Error generating code:"
-	if len(secret) != 0 {
-		code, err := totp.GenerateCode(secret, t)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, errGen, err)
-			return err
-		}
+// getSecret returns an ad-hoc secret built from the secret value and
+// options or, if no value is given, the named secret along with the
+// collection it was loaded from
+func getSecret(name, value string, opts api.SecretOptions) (api.Secret, *api.Collection, error) {
+	if len(value) != 0 {
+		return api.Secret{
+			Name:      name,
+			Value:     value,
+			Algorithm: opts.Algorithm,
+			Digits:    opts.Digits,
+			Period:    opts.Period,
+		}, nil, nil
+	}
 
-		fmt.Fprintln(writer, code)
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return api.Secret{}, nil, err
+	}
 
-		return nil
+	secret, err := c.GetSecret(name)
+	if err != nil {
+		printError("Error generating code", err)
+		return api.Secret{}, nil, err
 	}
 
-	c, err := collectionFile.loader()
+	return secret, c, nil
+}
+
+// generateHOTPCode generates the next code of a named HOTP secret and saves
+// the incremented counter. When the collection is saved to stdout, the code
+// is written to stderr so it is not mixed with the collection data. HOTP
+// codes do not expire, so a copied code is cleared after the default
+// period.
+func generateHOTPCode(writer io.Writer, c *api.Collection, name string, copier *codeCopier) error {
+	secret, err := c.GetSecret(name)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading collection:", err)
+		printError("Error generating code", err)
 		return err
 	}
 
-	code, err := c.GenerateCodeWithTime(name, t)
+	code, err := c.GenerateCode(name)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, errGen, err)
+		printError("Error generating code", err)
 		return err
 	}
 
-	fmt.Fprintln(writer, code)
+	if err := c.Save(); err != nil {
+		printError("Error saving settings", err)
+		return err
+	}
+
+	if collectionFile.useStdio {
+		writer = os.Stderr
+	}
+
+	if err := outputCode(writer, newCodeDocument(secret, code, time.Time{})); err != nil {
+		return err
+	}
+	copier.copy([]string{code}, api.DefaultPeriod*time.Second)
 
 	return nil
 }
 
-func durationToNextInterval(now time.Time) time.Duration {
-	var sleepSeconds int
+// outputCode writes a code, formatted with the --format template if
+// given, or its document in structured output
+func outputCode(writer io.Writer, document codeDocument) error {
+	if codeTemplate != nil {
+		if err := formatCode(writer, document); err != nil {
+			printError("Error formatting code", err)
+			return err
+		}
+		return nil
+	}
 
-	s := now.Second()
-	switch {
-	case s == 0, s < 30:
-		sleepSeconds = 30 - s
-	case s >= 30:
-		sleepSeconds = 60 - s
+	if structuredOutput() {
+		if err := outputDocument(writer, document); err != nil {
+			printError("Error writing code", err)
+			return err
+		}
+		return nil
 	}
 
-	return time.Duration(sleepSeconds)*time.Second -
-		time.Duration(now.Nanosecond())*time.Nanosecond
+	fmt.Fprintln(writer, document.text())
+
+	return nil
+}
+
+func generateCode(writer io.Writer, generate codeFunc, t time.Time) error {
+	document, err := generate(t)
+	if err != nil {
+		printError("Error generating code", err)
+		return err
+	}
 
+	return outputCode(writer, document)
 }
 
-func callOnInterval(runtime time.Duration, interval time.Duration, exec func() bool) {
-	stopper := make(chan bool)
+// durationToNextInterval returns the time from now until the next multiple
+// of interval, the end of the current period of a secret with that period
+func durationToNextInterval(now time.Time, interval time.Duration) time.Duration {
+	return interval - time.Duration(now.UnixNano()%int64(interval))
+}
 
+// callOnInterval calls exec now and then every interval by the clock until
+// exec returns true or, if runtime is not zero, runtime has passed.
+// Intervals missed while exec runs or the system is suspended are skipped.
+func callOnInterval(clock clock, runtime time.Duration, interval time.Duration, exec func() bool) {
+	var stop time.Time
 	if runtime > 0 {
-		go func() {
-			time.Sleep(runtime)
-			stopper <- true
-		}()
+		stop = clock.Now().Add(runtime)
 	}
 
 	if exec != nil && exec() {
 		return
 	}
 
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	next := clock.Now().Add(interval)
+	for stop.IsZero() || next.Before(stop) {
+		clock.Sleep(next.Sub(clock.Now()))
 
-	for {
-		select {
-		case <-stopper:
+		if exec != nil && exec() {
 			return
-		case <-ticker.C:
-			if exec != nil && exec() {
-				return
-			}
+		}
+
+		next = next.Add(interval)
+		if missed := clock.Now().Sub(next); missed >= 0 {
+			next = next.Add((missed/interval + 1) * interval)
 		}
 	}
 }
 
-func generateCodes(timeOffset time.Duration, durationToRun time.Duration, intervalTime time.Duration, sleep func(time.Duration), secretName, secret string) {
-	sleep(durationToNextInterval(time.Now().Add(timeOffset)) + 10*time.Millisecond)
+func generateCodes(clock clock, timeOffset time.Duration, durationToRun time.Duration, intervalTime time.Duration, generate codeFunc) {
+	clock.Sleep(durationToNextInterval(clock.Now().Add(timeOffset), intervalTime) + 10*time.Millisecond)
 
-	callOnInterval(durationToRun, intervalTime,
+	callOnInterval(clock, durationToRun, intervalTime,
 		func() bool {
-			if err := generateCode(os.Stdout, secretName, secret, time.Now().Add(timeOffset)); err != nil {
+			if err := generateCode(os.Stdout, generate, clock.Now().Add(timeOffset)); err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				return true
 			}
@@ -150,8 +256,97 @@ func generateCodes(timeOffset time.Duration, durationToRun time.Duration, interv
 		})
 }
 
-func run(cmd *cobra.Command, args []string, cfg runVars) {
-	// var err error
+// parseTimeOption returns the RFC3339 time of the time option or, if not
+// given, the current time
+func parseTimeOption(timeString string) (time.Time, error) {
+	if len(timeString) == 0 {
+		return commandClock.Now(), nil
+	}
+
+	return time.Parse(time.RFC3339, timeString)
+}
+
+// run outputs codes for the root command. An error is only returned for
+// an exit status other than 0, as the error text has been output.
+func run(cmd *cobra.Command, args []string, cfg runVars) error {
+	if cfg.clearCopy && !cfg.copy {
+		fmt.Fprintf(os.Stderr, "The clear option can only be used with the copy option.\n\n")
+		if err := cmd.Help(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		return nil
+	}
+
+	if len(cfg.format) != 0 && structuredOutput() {
+		fmt.Fprintf(os.Stderr, "The format option cannot be used with the json or yaml output formats.\n\n")
+		if err := cmd.Help(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		return nil
+	}
+
+	if err := setCodeTemplate(cfg.format); err != nil {
+		printError("Error parsing the format option", err)
+		return nil
+	}
+
+	if (cfg.details.any() || cfg.validity.min > 0) && (cfg.all || cfg.interactive) {
+		fmt.Fprintf(os.Stderr, "The remaining, next, previous, and min-validity options cannot be used with the all or interactive options.\n\n")
+		if err := cmd.Help(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		return nil
+	}
+
+	// Codes are generated with the time of the NTP server
+	if cfg.ntp {
+		result, err := querySNTP(cfg.ntpServer, defaultSNTPTimeout, commandClock)
+		if err != nil {
+			printError("Error checking the time", err)
+			return nil
+		}
+
+		defer func(saved clock) { commandClock = saved }(commandClock)
+		commandClock = offsetClock{clock: commandClock, offset: result.offset}
+	}
+
+	if cfg.all {
+		runAll(cmd, args, cfg)
+		return nil
+	}
+
+	if len(cfg.tags) != 0 && !cfg.interactive {
+		fmt.Fprintf(os.Stderr, "The tag option can only be used with the all or interactive options.\n\n")
+		if err := cmd.Help(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		return nil
+	}
+
+	if cfg.interactive {
+		if len(cfg.secret) != 0 || cfg.qr || cfg.follow {
+			fmt.Fprintf(os.Stderr, "The interactive option cannot be used with the secret, qrcode, or follow options.\n\n")
+			if err := cmd.Help(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+
+			return nil
+		}
+
+		codeTime, err := parseTimeOption(cfg.timeString)
+		if err != nil {
+			printError("Error parsing the time option", err)
+			return nil
+		}
+
+		// runInteractive will output error text
+		_ = runInteractive(args, cfg.tags, codeTime.Sub(commandClock.Now())-cfg.backward+cfg.forward)
+		return nil
+	}
 
 	secretLen := len(cfg.secret)
 	argsLen := len(args)
@@ -175,7 +370,7 @@ func run(cmd *cobra.Command, args []string, cfg runVars) {
 			fmt.Fprintln(os.Stderr, err)
 		}
 
-		return
+		return nil
 	}
 
 	if cfg.qr {
@@ -184,24 +379,15 @@ func run(cmd *cobra.Command, args []string, cfg runVars) {
 			secretName = args[0]
 		}
 
-		_ = qrCode(os.Stdout, secretName, cfg.secret)
-		return
+		_ = qrCode(os.Stdout, secretName, cfg.secret, cfg.opts)
+		return nil
 	}
 
 	// Override if time was given
-	var (
-		codeTime time.Time
-		err      error
-	)
-	if len(cfg.timeString) > 0 {
-		codeTime, err = time.Parse(time.RFC3339, cfg.timeString)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Error parsing the time option:", err)
-			return
-		}
-	} else {
-		codeTime = time.Now()
-		// codeOffset is 0
+	codeTime, err := parseTimeOption(cfg.timeString)
+	if err != nil {
+		printError("Error parsing the time option", err)
+		return nil
 	}
 
 	// Load the secret name
@@ -210,15 +396,87 @@ func run(cmd *cobra.Command, args []string, cfg runVars) {
 		secretName = args[0]
 	}
 
+	copier := newCodeCopier(cfg)
+	defer copier.finish()
+
+	// TOTP codes of stored secrets come from the agent when it is running
+	// and serves the collection
+	if socket := os.Getenv(envAgentSocket); len(socket) != 0 && len(cfg.secret) == 0 {
+		// generateAgentCode will output error text
+		if err := generateAgentCode(os.Stdout, socket, secretName, codeTime, cfg, copier); !errors.Is(err, errAgentUnavailable) {
+			return nil
+		}
+	}
+
 	// If here then a stored shared secret is wanted
-	if err := generateCode(os.Stdout, secretName, cfg.secret, codeTime.Add(cfg.forward-cfg.backward)); err != nil {
+	secret, c, err := getSecret(secretName, cfg.secret, cfg.opts)
+	if err != nil {
+		// getSecret will output error text
+		return nil
+	}
+
+	if secret.IsHOTP() {
+		if cfg.follow {
+			fmt.Fprintln(os.Stderr, "The follow option cannot be used with HOTP secrets.")
+			return nil
+		}
+
+		if cfg.details.any() {
+			fmt.Fprintln(os.Stderr, "The remaining, next, and previous options cannot be used with HOTP secrets.")
+			return nil
+		}
+
+		// The incremented counter is saved, so the collection is locked
+		// and loaded again in case another command changed it. TOTP codes
+		// do not change the collection and are generated without a lock.
+		unlock, err := lockCollection()
+		if err != nil {
+			// lockCollection will output error text
+			return exitError{status: 1}
+		}
+		defer unlock()
+
+		if !collectionFile.useStdio {
+			if c, err = collectionFile.loader(); err != nil {
+				printError("Error loading collection", err)
+				return nil
+			}
+		}
+
+		// generateHOTPCode will output error text
+		_ = generateHOTPCode(os.Stdout, c, secretName, copier)
+		return nil
+	}
+
+	// The follow offset is taken before waiting for a fresh code, so the
+	// wait does not move the followed codes back
+	timeOffset := codeTime.Sub(commandClock.Now()) - cfg.backward + cfg.forward
+
+	// Wait for the next code if the current one expires too soon
+	t, err := cfg.validity.codeTime(codeTime.Add(cfg.forward-cfg.backward), secret.Options().PeriodDuration(), commandClock.Sleep)
+	if err != nil {
+		printError("Error generating code", err)
+		return nil
+	}
+
+	generate := copier.wrap(cfg.details.wrap(secretCodeFunc(secret)))
+	if err := generateCode(os.Stdout, generate, t); err != nil {
 		// generateCode will output error text
-		return
+		return nil
 	}
 
 	if cfg.follow {
-		generateCodesService(time.Until(codeTime)-cfg.backward+cfg.forward, 0, 30*time.Second, time.Sleep, secretName, cfg.secret)
+		generateCodesService(commandClock, timeOffset, 0, secret.Options().PeriodDuration(), generate)
 	}
+
+	return nil
+}
+
+// addSecretOptionFlags adds the code generation option flags to a command
+func addSecretOptionFlags(cobraCmd *cobra.Command, opts *api.SecretOptions, usageSuffix string) {
+	cobraCmd.Flags().StringVarP(&opts.Algorithm, optionAlgorithm, "", "", "HMAC algorithm (SHA1, SHA256, SHA512, MD5)"+usageSuffix)
+	cobraCmd.Flags().IntVarP(&opts.Digits, optionDigits, "", 0, "number of code digits (6-8)"+usageSuffix)
+	cobraCmd.Flags().UintVarP(&opts.Period, optionPeriod, "", 0, "seconds a code is valid"+usageSuffix)
 }
 
 func validArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -241,16 +499,26 @@ func getRootCmd() *cobra.Command {
 				collectionFile.filename = cfg.cfgFile
 			}
 
+			// subcommands declare their own stdio flag, so check the flag of
+			// the command being run
 			if cmd.Flags().Lookup(optionStdio) != nil {
-				if cfg.useStdio {
+				if useStdio, _ := cmd.Flags().GetBool(optionStdio); useStdio {
 					collectionFile.loader = loadCollectionFromStdin
 					collectionFile.useStdio = true
+					collectionFile.stdin = nil
 				}
 			}
 		},
 		ValidArgsFunction: validArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			run(cmd, args, cfg)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args, cfg)
+			if err != nil {
+				// the error text has been output, so only the exit status
+				// is left to report
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+			}
+			return err
 		},
 	}
 
@@ -258,20 +526,42 @@ func getRootCmd() *cobra.Command {
 
 	generateCodesService = generateCodes
 
-	cobraCmd.PersistentFlags().StringVarP(&cfg.cfgFile, optionFile, "f", "", "secret collection file")
+	cobraCmd.PersistentFlags().VarP(outputFormatFlag{}, optionOutput, "o", "output format (text, json, yaml)")
+	_ = cobraCmd.RegisterFlagCompletionFunc(optionOutput, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return validOutputFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	cobraCmd.PersistentFlags().StringVarP(&cfg.cfgFile, optionFile, "f", "", "secret collection file or store URL (ex. \"dir:///path/to/secrets\")")
 
 	cobraCmd.Flags().StringVarP(&cfg.secret, optionSecret, "s", "", "TOTP secret value")
+	addSecretOptionFlags(cobraCmd, &cfg.opts, " for --secret")
 	cobraCmd.Flags().BoolVarP(&cfg.useStdio, optionStdio, "", false, "load with stdin")
 	cobraCmd.Flags().StringVarP(&cfg.timeString, optionTime, "", "", "RFC3339 time for TOTP (2019-06-23T20:00:00-05:00)")
 	cobraCmd.Flags().DurationVarP(&cfg.backward, optionBackward, "", duration, "move time backward (ex. \"30s\")")
 	cobraCmd.Flags().DurationVarP(&cfg.forward, optionForward, "", duration, "move time forward (ex. \"1m\")")
 	cobraCmd.Flags().BoolVarP(&cfg.follow, optionFollow, "", false, "continuous output")
 	cobraCmd.Flags().BoolVarP(&cfg.qr, optionQr, "", false, "output QR code")
-
-	cobraCmd.SetUsageTemplate(strings.Replace(cobraCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [secret name]", 1))
+	cobraCmd.Flags().BoolVarP(&cfg.interactive, optionInteractive, "i", false, "interactive display of all codes")
+	cobraCmd.Flags().BoolVarP(&cfg.all, optionAll, "a", false, "output codes for all secrets, or those matching the name prefixes or patterns given")
+	addTagFilterFlag(cobraCmd, &cfg.tags)
+	cobraCmd.Flags().BoolVarP(&cfg.copy, optionCopy, "c", false, "copy codes to the clipboard")
+	cobraCmd.Flags().StringVarP(&cfg.format, optionFormat, "", "", "Go template for each code (ex. \"{{.Name}} {{.Code}} {{.Remaining}}s\")")
+	cobraCmd.Flags().BoolVarP(&cfg.clearCopy, optionClear, "", false, "clear copied codes from the clipboard when they expire")
+	cobraCmd.Flags().BoolVarP(&cfg.details.remaining, optionRemaining, "", false, "output the seconds the code remains valid")
+	cobraCmd.Flags().BoolVarP(&cfg.details.next, optionNext, "", false, "output the code of the next period")
+	cobraCmd.Flags().BoolVarP(&cfg.details.previous, optionPrevious, "", false, "output the code of the previous period")
+	cobraCmd.Flags().DurationVarP(&cfg.validity.min, optionMinValidity, "", 0, "wait for the next code if the current one expires sooner (ex. \"10s\")")
+	cobraCmd.Flags().DurationVarP(&cfg.validity.maxWait, optionMaxWait, "", 0, "longest time to wait for the next code with --min-validity")
+	cobraCmd.Flags().BoolVarP(&cfg.ntp, optionNTP, "", false, "correct the time with the offset of the local clock from an NTP server")
+	cobraCmd.Flags().StringVarP(&cfg.ntpServer, optionNTPServer, "", "", "NTP server for --ntp (default $TOTP_NTP_SERVER or pool.ntp.org)")
+
+	cobraCmd.SetUsageTemplate(strings.Replace(cobraCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [secret name]\n  {{.CommandPath}} --all|--interactive [--tag tag]... [name prefix | pattern]...", 1))
 
 	cobraCmd.AddCommand(getVersionCmd())
 	cobraCmd.AddCommand(getConfigCmd(cobraCmd))
+	cobraCmd.AddCommand(getVerifyCmd(cobraCmd))
+	cobraCmd.AddCommand(getServeCmd())
+	cobraCmd.AddCommand(getAgentCmd())
+	cobraCmd.AddCommand(getTimeCmd())
 
 	return cobraCmd
 }
@@ -285,6 +575,11 @@ func Execute() int {
 	rootCmd := getRootCmd()
 
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr exitError
+		if errors.As(err, &exitErr) {
+			return exitErr.status
+		}
+
 		fmt.Println(err)
 		retVal = 1
 	}
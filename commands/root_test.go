@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	api "github.com/arcanericky/totp"
 	"github.com/spf13/cobra"
 )
 
@@ -18,19 +19,19 @@ func TestRoot(t *testing.T) {
 	rootCmd := getRootCmd()
 
 	// No parameters
-	rootCmd.Run(rootCmd, []string{})
+	_ = rootCmd.RunE(rootCmd, []string{})
 
 	// Valid entry and secret
-	rootCmd.Run(rootCmd, []string{secretList[0].name})
+	_ = rootCmd.RunE(rootCmd, []string{secretList[0].name})
 
 	// Non-existing entry
-	rootCmd.Run(rootCmd, []string{"invalidsecret"})
+	_ = rootCmd.RunE(rootCmd, []string{"invalidsecret"})
 
 	// Test follow condition
 	savedGenerateCodesService := generateCodesService
-	generateCodesService = func(time.Duration, time.Duration, time.Duration, func(time.Duration), string, string) {}
+	generateCodesService = func(clock, time.Duration, time.Duration, time.Duration, codeFunc) {}
 	_ = rootCmd.Flags().Lookup(optionFollow).Value.Set("true")
-	rootCmd.Run(rootCmd, []string{"name0"})
+	_ = rootCmd.RunE(rootCmd, []string{"name0"})
 	generateCodesService = savedGenerateCodesService
 	_ = rootCmd.Flags().Lookup(optionFollow).Value.Set("false")
 
@@ -42,21 +43,21 @@ func TestRoot(t *testing.T) {
 
 	// No collections file
 	os.Remove(collectionFile.filename)
-	rootCmd.Run(rootCmd, []string{secretList[0].name})
+	_ = rootCmd.RunE(rootCmd, []string{secretList[0].name})
 
 	// Completion without collections
 	rootCmd.ValidArgsFunction(rootCmd, []string{}, "na")
 
 	// Excessive args
-	rootCmd.Run(rootCmd, []string{"secretname", "extraarg"})
+	_ = rootCmd.RunE(rootCmd, []string{"secretname", "extraarg"})
 
 	// Provide secret option
 	_ = rootCmd.Flags().Set(optionSecret, "seed")
-	rootCmd.Run(rootCmd, []string{})
+	_ = rootCmd.RunE(rootCmd, []string{})
 
 	// Provide invalid secret option
 	_ = rootCmd.Flags().Set(optionSecret, "seed1")
-	rootCmd.Run(rootCmd, []string{})
+	_ = rootCmd.RunE(rootCmd, []string{})
 
 	// File option
 	_ = rootCmd.Flags().Set(optionFile, collectionFile.filename)
@@ -72,16 +73,16 @@ func TestRoot(t *testing.T) {
 
 	// Time option
 	_ = rootCmd.Flags().Set(optionTime, "2019-06-01T20:00:00-05:00")
-	rootCmd.Run(rootCmd, []string{})
+	_ = rootCmd.RunE(rootCmd, []string{})
 
 	// Give secret and secret name
 	_ = rootCmd.Flags().Set(optionSecret, "seed")
-	rootCmd.Run(rootCmd, []string{"secretname"})
+	_ = rootCmd.RunE(rootCmd, []string{"secretname"})
 	_ = rootCmd.Flags().Set(optionSecret, "")
 
 	// Invalid time option
 	_ = rootCmd.Flags().Set(optionTime, "invalidtime")
-	rootCmd.Run(rootCmd, []string{})
+	_ = rootCmd.RunE(rootCmd, []string{})
 	_ = rootCmd.Flags().Set(optionTime, "")
 	os.Remove(collectionFile.filename)
 }
@@ -184,40 +185,56 @@ func Test_generateCodes(t *testing.T) {
 		timeOffset    time.Duration
 		durationToRun time.Duration
 		intervalTime  time.Duration
-		sleep         func(time.Duration)
-		secretName    string
-		secret        string
+		secret        api.Secret
 	}
 	tests := []struct {
-		name string
-		args args
+		name  string
+		args  args
+		codes int
 	}{
 		{
 			name: "valid seed",
 			args: args{
 				timeOffset:    duration,
-				durationToRun: 2 * time.Millisecond,
-				intervalTime:  1 * time.Millisecond,
-				sleep:         func(d time.Duration) {},
-				secretName:    "",
-				secret:        "seed",
+				durationToRun: 2 * time.Minute,
+				intervalTime:  30 * time.Second,
+				secret:        api.Secret{Value: "seed"},
 			},
+			codes: 4,
 		},
 		{
 			name: "invalid seed",
 			args: args{
 				timeOffset:    duration,
-				durationToRun: 2 * time.Millisecond,
-				intervalTime:  1 * time.Millisecond,
-				sleep:         func(d time.Duration) {},
-				secretName:    "",
-				secret:        "invalidseed",
+				durationToRun: 2 * time.Minute,
+				intervalTime:  30 * time.Second,
+				secret:        api.Secret{Value: "invalidseed"},
 			},
+			codes: 1,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			generateCodes(tt.args.timeOffset, tt.args.durationToRun, tt.args.intervalTime, tt.args.sleep, tt.args.secretName, tt.args.secret)
+			clock := &testClock{now: time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)}
+			var times []time.Time
+			generate := func(at time.Time) (codeDocument, error) {
+				times = append(times, at)
+				return secretCodeFunc(tt.args.secret)(at)
+			}
+
+			captureStdout(t, func() {
+				generateCodes(clock, tt.args.timeOffset, tt.args.durationToRun, tt.args.intervalTime, generate)
+			})
+
+			// Codes are generated just after each interval starts
+			if len(times) != tt.codes {
+				t.Fatalf("generated %d codes, want %d", len(times), tt.codes)
+			}
+			for i, codeTime := range times {
+				if want := time.Date(2019, 6, 23, 20, 0, 30, int(10*time.Millisecond), time.UTC).Add(time.Duration(i) * tt.args.intervalTime); !codeTime.Equal(want) {
+					t.Errorf("code %d time = %v, want %v", i, codeTime, want)
+				}
+			}
 		})
 	}
 }
@@ -285,14 +302,15 @@ func Test_callOnInterval(t *testing.T) {
 	for _, tt := range tests {
 		execCount = tt.startExecCount
 		t.Run(tt.name, func(t *testing.T) {
-			callOnInterval(tt.args.runtime, tt.args.interval, tt.args.exec)
+			callOnInterval(systemClock{}, tt.args.runtime, tt.args.interval, tt.args.exec)
 		})
 	}
 }
 
 func Test_durationToNextInterval(t *testing.T) {
 	type args struct {
-		now string
+		now      string
+		interval time.Duration
 	}
 	tests := []struct {
 		name string
@@ -302,29 +320,48 @@ func Test_durationToNextInterval(t *testing.T) {
 		{
 			name: "29 seconds",
 			args: args{
-				now: "2019-06-23T20:00:01-05:00",
+				now:      "2019-06-23T20:00:01-05:00",
+				interval: 30 * time.Second,
 			},
 			want: time.Duration(29 * time.Second),
 		},
 		{
 			name: "29 seconds 2",
 			args: args{
-				now: "2019-06-23T20:00:31-05:00",
+				now:      "2019-06-23T20:00:31-05:00",
+				interval: 30 * time.Second,
 			},
 			want: time.Duration(29 * time.Second),
 		},
 		{
 			name: "28 seconds",
 			args: args{
-				now: "2019-06-23T20:00:31.001-05:00",
+				now:      "2019-06-23T20:00:31.001-05:00",
+				interval: 30 * time.Second,
 			},
 			want: time.Duration(28*time.Second + 999*time.Millisecond),
 		},
+		{
+			name: "59 seconds with 60 second interval",
+			args: args{
+				now:      "2019-06-23T20:00:01-05:00",
+				interval: 60 * time.Second,
+			},
+			want: time.Duration(59 * time.Second),
+		},
+		{
+			name: "on the interval",
+			args: args{
+				now:      "2019-06-23T20:00:00-05:00",
+				interval: 30 * time.Second,
+			},
+			want: time.Duration(30 * time.Second),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			now, _ := time.Parse(time.RFC3339, tt.args.now)
-			if got := durationToNextInterval(now); got != tt.want {
+			if got := durationToNextInterval(now, tt.args.interval); got != tt.want {
 				t.Errorf("durationToNextInterval() = %v, want %v", got, tt.want)
 			}
 		})
@@ -334,8 +371,7 @@ func Test_durationToNextInterval(t *testing.T) {
 func Test_generateCode(t *testing.T) {
 	now, _ := time.Parse(time.RFC3339, "2019-06-23T20:00:01-05:00")
 	type args struct {
-		name   string
-		secret string
+		secret api.Secret
 		t      time.Time
 	}
 	tests := []struct {
@@ -347,18 +383,34 @@ func Test_generateCode(t *testing.T) {
 		{
 			name: "valid secret",
 			args: args{
-				name:   "name",
-				secret: "seed",
+				secret: api.Secret{Name: "name", Value: "seed"},
 				t:      now,
 			},
 			wantWriter: "335072\n",
 			wantErr:    false,
 		},
+		{
+			name: "valid secret with options",
+			args: args{
+				secret: api.Secret{Name: "name", Value: "seed", Algorithm: "SHA256", Digits: 8, Period: 60},
+				t:      now,
+			},
+			wantWriter: "68372300\n",
+			wantErr:    false,
+		},
+		{
+			name: "invalid options",
+			args: args{
+				secret: api.Secret{Name: "name", Value: "seed", Digits: 12},
+				t:      now,
+			},
+			wantWriter: "",
+			wantErr:    true,
+		},
 		{
 			name: "invalid secret",
 			args: args{
-				name:   "name",
-				secret: "seed0",
+				secret: api.Secret{Name: "name", Value: "seed0"},
 				t:      now,
 			},
 			wantWriter: "",
@@ -368,7 +420,7 @@ func Test_generateCode(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			writer := &bytes.Buffer{}
-			if err := generateCode(writer, tt.args.name, tt.args.secret, tt.args.t); (err != nil) != tt.wantErr {
+			if err := generateCode(writer, secretCodeFunc(tt.args.secret), tt.args.t); (err != nil) != tt.wantErr {
 				t.Errorf("generateCode() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -419,3 +471,75 @@ func Test_getSecretNamesForCompletion(t *testing.T) {
 	}
 	os.Remove(collectionFile.filename)
 }
+
+func Test_runHOTP(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	_ = createTestData(t)
+
+	c, _ := api.NewCollectionWithFile(collectionFile.filename)
+	_, _ = c.UpdateSecretWithOptions("hotpname", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", api.SecretOptions{Type: api.TypeHOTP})
+	_ = c.Save()
+
+	// Follow is not allowed
+	run(&cobra.Command{}, []string{"hotpname"}, runVars{follow: true})
+
+	// Counter is incremented and saved
+	if err := run(&cobra.Command{}, []string{"hotpname"}, runVars{}); err != nil {
+		t.Error("run() error:", err)
+	}
+	c, _ = api.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("hotpname"); s.Counter != 1 {
+		t.Error("HOTP counter not saved", s)
+	}
+
+	// Only HOTP codes wait for a locked collection, and the exit status
+	// reports when the lock is not released
+	savedTimeout := collectionLockTimeout
+	collectionLockTimeout = 100 * time.Millisecond
+	defer func() { collectionLockTimeout = savedTimeout }()
+
+	lock, err := api.LockFile(collectionFile.filename, time.Second)
+	if err != nil {
+		t.Fatal("LockFile() error:", err)
+	}
+	if err := run(&cobra.Command{}, []string{"hotpname"}, runVars{}); err != (exitError{status: 1}) {
+		t.Errorf("run() of locked HOTP secret error = %v", err)
+	}
+	if stdout := captureStdout(t, func() { _ = run(&cobra.Command{}, []string{"name0"}, runVars{}) }); len(stdout) == 0 {
+		t.Error("No TOTP code for a locked collection")
+	}
+	_ = lock.Unlock()
+
+	writer := &bytes.Buffer{}
+	if err := generateHOTPCode(writer, c, "hotpname", nil); err != nil || writer.String() != "287082\n" {
+		t.Errorf("generateHOTPCode() = %v, %v", writer.String(), err)
+	}
+
+	// Save failure
+	c.SetFilename("")
+	if err := generateHOTPCode(writer, c, "hotpname", nil); err == nil {
+		t.Error("generateHOTPCode() save error not returned")
+	}
+
+	// Secret not found
+	if err := generateHOTPCode(writer, c, "invalidname", nil); err == nil {
+		t.Error("generateHOTPCode() invalid name error not returned")
+	}
+
+	os.Remove(collectionFile.filename)
+}
+
+func TestRootSubcommandStdio(t *testing.T) {
+	rootCmd := getRootCmd()
+	verifyCmd, _, _ := rootCmd.Find([]string{cmdVerify})
+
+	_ = verifyCmd.Flags().Set(optionStdio, "true")
+	rootCmd.PersistentPreRun(verifyCmd, []string{})
+	if !collectionFile.useStdio {
+		t.Error("Subcommand stdio option not used")
+	}
+
+	collectionFile.loader = loadCollectionFromDefaultFile
+	collectionFile.useStdio = false
+}
@@ -0,0 +1,476 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	api "github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultServeAddress = "127.0.0.1:7979"
+	envServeToken       = "TOTP_SERVE_TOKEN"
+	serveSecretsPath    = "/v1/secrets"
+)
+
+var errReadOnly = errors.New("the server is read-only, start it with --read-write to change HOTP counters")
+var errNotLoopback = errors.New("only loopback addresses can be listened on")
+
+type serveVars struct {
+	listen    string
+	socket    string
+	tokenFile string
+	readWrite bool
+}
+
+// serveSecret is a secret in the list response
+type serveSecret struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// serveRemaining is the response to a remaining time request. Code
+// requests are answered with the codeDocument of --output json.
+type serveRemaining struct {
+	Name      string `json:"name"`
+	Remaining int    `json:"remaining"`
+	Period    uint   `json:"period"`
+}
+
+// serveVerifyRequest is the body of a verify request
+type serveVerifyRequest struct {
+	Code string `json:"code"`
+	Skew *uint  `json:"skew,omitempty"`
+	Time string `json:"time,omitempty"`
+}
+
+// codeServer answers code requests for a collection loaded at start. HOTP
+// requests that advance a counter reload the collection, so changes made by
+// other commands are kept.
+type codeServer struct {
+	mu        sync.Mutex
+	c         *api.Collection
+	loader    func() (*api.Collection, error)
+	token     string
+	readWrite bool
+	now       func() time.Time
+}
+
+func writeServeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		printError("Error writing response", err)
+	}
+}
+
+func writeServeError(w http.ResponseWriter, status int, err error) {
+	writeServeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// authorized checks the bearer token, if one is required
+func (s *codeServer) authorized(r *http.Request) bool {
+	if len(s.token) == 0 {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// requestTime returns the time query parameter or the current time
+func (s *codeServer) requestTime(timeString string) (time.Time, error) {
+	if len(timeString) == 0 {
+		return s.now(), nil
+	}
+
+	return time.Parse(time.RFC3339, timeString)
+}
+
+// updateHOTP locks and reloads the collection, calls update to advance an
+// HOTP counter, and saves the collection
+func (s *codeServer) updateHOTP(update func(c *api.Collection) error) error {
+	unlock, err := lockCollection()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	c, err := s.loader()
+	if err != nil {
+		return err
+	}
+	s.c = c
+
+	if err := update(c); err != nil {
+		return err
+	}
+
+	return c.Save()
+}
+
+func (s *codeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeServeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	if path == serveSecretsPath {
+		s.handleList(w, r)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(path, serveSecretsPath+"/")
+	i := strings.LastIndex(rest, "/")
+	if !ok || i <= 0 {
+		writeServeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	name, err := url.PathUnescape(rest[:i])
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, err := s.c.GetSecret(name)
+	if err != nil {
+		writeServeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	switch action := rest[i+1:]; {
+	case action == "code" && r.Method == http.MethodGet:
+		s.handleCode(w, r, secret)
+	case action == "remaining" && r.Method == http.MethodGet:
+		s.handleRemaining(w, r, secret)
+	case action == "verify" && r.Method == http.MethodPost:
+		s.handleVerify(w, r, secret)
+	case action == "code" || action == "remaining" || action == "verify":
+		writeServeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	default:
+		writeServeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (s *codeServer) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeServeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	s.mu.Lock()
+	secrets := s.c.GetSecrets()
+	s.mu.Unlock()
+
+	list := make([]serveSecret, 0, len(secrets))
+	for _, secret := range secrets {
+		list = append(list, serveSecret{Name: secret.Name, Type: secret.Options().GetType()})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	writeServeJSON(w, http.StatusOK, list)
+}
+
+func (s *codeServer) handleCode(w http.ResponseWriter, r *http.Request, secret api.Secret) {
+	if secret.IsHOTP() {
+		if !s.readWrite {
+			writeServeError(w, http.StatusForbidden, errReadOnly)
+			return
+		}
+
+		var code string
+		err := s.updateHOTP(func(c *api.Collection) error {
+			var err error
+			if secret, err = c.GetSecret(secret.Name); err != nil {
+				return err
+			}
+			code, err = c.GenerateCode(secret.Name)
+			return err
+		})
+		if err != nil {
+			writeServeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		writeServeJSON(w, http.StatusOK, newCodeDocument(secret, code, time.Time{}))
+		return
+	}
+
+	t, err := s.requestTime(r.URL.Query().Get("time"))
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	code, err := secret.GenerateCodeWithTime(t)
+	if err != nil {
+		writeServeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeServeJSON(w, http.StatusOK, newCodeDocument(secret, code, t))
+}
+
+func (s *codeServer) handleRemaining(w http.ResponseWriter, r *http.Request, secret api.Secret) {
+	if secret.IsHOTP() {
+		writeServeError(w, http.StatusBadRequest, errors.New("HOTP codes do not expire"))
+		return
+	}
+
+	t, err := s.requestTime(r.URL.Query().Get("time"))
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeServeJSON(w, http.StatusOK, serveRemaining{
+		Name:      secret.Name,
+		Remaining: secondsRemaining(secret, t),
+		Period:    secret.Options().GetPeriod(),
+	})
+}
+
+func (s *codeServer) handleVerify(w http.ResponseWriter, r *http.Request, secret api.Secret) {
+	var req serveVerifyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeServeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	skew := uint(api.DefaultSkew)
+	if req.Skew != nil {
+		skew = *req.Skew
+	}
+
+	// the skew is checked before the HOTP lock is taken
+	if skew > api.MaxSkew {
+		writeServeError(w, http.StatusBadRequest, fmt.Errorf("%w: %d, the maximum is %d", api.ErrSkewTooLarge, skew, api.MaxSkew))
+		return
+	}
+
+	t, err := s.requestTime(req.Time)
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// a matched HOTP code advances the counter
+	if secret.IsHOTP() && !s.readWrite {
+		writeServeError(w, http.StatusForbidden, errReadOnly)
+		return
+	}
+
+	var step int
+	if secret.IsHOTP() {
+		// a mismatch is returned from the update so nothing is saved
+		err = s.updateHOTP(func(c *api.Collection) error {
+			var err error
+			step, err = c.VerifyCode(secret.Name, req.Code, t, skew)
+			return err
+		})
+	} else {
+		step, err = secret.VerifyCodeWithTime(req.Code, t, skew)
+	}
+
+	if err != nil && !errors.Is(err, api.ErrCodeMismatch) {
+		writeServeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeServeJSON(w, http.StatusOK, verifyResult{Name: secret.Name, Valid: err == nil, Step: step})
+}
+
+// readServeToken returns the token from the token file or the
+// TOTP_SERVE_TOKEN environment variable
+func readServeToken(tokenFile string) (string, error) {
+	if len(tokenFile) == 0 {
+		return os.Getenv(envServeToken), nil
+	}
+
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// newServeToken returns a random token
+func newServeToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// checkLoopback returns an error unless the TCP address is on the loopback
+// interface, so the codes are not served to other hosts
+func checkLoopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%w: %s", errNotLoopback, address)
+	}
+
+	return nil
+}
+
+// serveListen listens on the Unix socket, readable only by the user, or
+// the loopback TCP address
+func serveListen(cfg serveVars) (net.Listener, error) {
+	if len(cfg.socket) != 0 {
+		return listenUnix(cfg.socket)
+	}
+
+	if err := checkLoopback(cfg.listen); err != nil {
+		return nil, err
+	}
+
+	return net.Listen("tcp", cfg.listen)
+}
+
+// runServe serves the collection until interrupted
+func runServe(cfg serveVars) error {
+	token, err := readServeToken(cfg.tokenFile)
+	if err != nil {
+		printError("Error reading token", err)
+		return err
+	}
+
+	// TCP connections can come from any local user, so they always need a
+	// token
+	if len(cfg.socket) == 0 && len(token) == 0 {
+		if token, err = newServeToken(); err != nil {
+			printError("Error generating token", err)
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Token:", token)
+	}
+
+	// the passphrase is remembered for reloading the collection
+	var passphrase string
+	api.SetPassphraseFunc(func() (string, error) {
+		if len(passphrase) != 0 {
+			return passphrase, nil
+		}
+
+		var err error
+		passphrase, err = getPassphrase()
+		return passphrase, err
+	})
+	defer api.SetPassphraseFunc(getPassphrase)
+
+	c, err := collectionFile.loader()
+	if err != nil {
+		printError("Error loading collection", err)
+		return err
+	}
+
+	listener, err := serveListen(cfg)
+	if err != nil {
+		printError("Error listening", err)
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintln(os.Stderr, "Serving on", listener.Addr())
+	handler := &codeServer{c: c, loader: collectionFile.loader, token: token, readWrite: cfg.readWrite, now: commandClock.Now}
+	if err := serveUntilDone(ctx, listener, handler); err != nil {
+		printError("Error serving", err)
+		return err
+	}
+
+	return nil
+}
+
+// serveUntilDone serves requests on the listener until the context is done
+func serveUntilDone(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func getServeCmd() *cobra.Command {
+	var (
+		cfg      serveVars
+		cobraCmd = &cobra.Command{
+			Use:   cmdServe,
+			Short: "Serve codes over a local HTTP API",
+			Long: `Serve codes over a local HTTP API
+
+The collection is loaded once and served on --listen, which must be a
+loopback address, or on the Unix socket --socket which only the user can
+connect to. Requests to a TCP address need
+the bearer token read from --token-file or the ` + envServeToken + `
+environment variable, and one is generated and output if neither is set.
+
+  GET  /v1/secrets                   list secret names and types
+  GET  /v1/secrets/{name}/code       current code and seconds remaining
+  GET  /v1/secrets/{name}/remaining  seconds until the code changes
+  POST /v1/secrets/{name}/verify     verify {"code": "123456", "skew": 1}
+
+Code and remaining requests take an RFC3339 time query parameter, and
+verify requests a time member. The server is read-only unless --read-write
+is given, which allows HOTP code and verify requests to advance counters.`,
+			Args: cobra.NoArgs,
+			Run: func(_ *cobra.Command, _ []string) {
+				_ = runServe(cfg)
+			},
+		}
+	)
+
+	cobraCmd.Flags().StringVarP(&cfg.listen, "listen", "", defaultServeAddress, "loopback TCP address to listen on")
+	cobraCmd.Flags().StringVarP(&cfg.socket, "socket", "", "", "Unix socket to listen on instead of a TCP address")
+	cobraCmd.Flags().StringVarP(&cfg.tokenFile, "token-file", "", "", "file containing the bearer token")
+	cobraCmd.Flags().BoolVarP(&cfg.readWrite, "read-write", "", false, "allow requests that advance HOTP counters")
+
+	return cobraCmd
+}
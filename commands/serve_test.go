@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
+)
+
+func serveRequest(t *testing.T, s *codeServer, method, target, token, body string) (int, map[string]interface{}) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if len(token) != 0 {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	result := map[string]interface{}{}
+	if w.Body.Len() != 0 && !strings.HasPrefix(w.Body.String(), "[") {
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s %s response %q: %v", method, target, w.Body.String(), err)
+		}
+	}
+
+	return w.Code, result
+}
+
+func TestCodeServer(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+	c, _ := collectionFile.loader()
+	_, _ = c.UpdateSecretWithOptions("a/b", "SEED", totp.SecretOptions{Period: 60})
+	_ = c.Save()
+
+	codeTime := time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)
+	s := &codeServer{c: c, loader: collectionFile.loader, token: "token", now: func() time.Time { return codeTime }}
+
+	// Authorization
+	for _, token := range []string{"", "wrong"} {
+		if status, _ := serveRequest(t, s, http.MethodGet, "/v1/secrets", token, ""); status != http.StatusUnauthorized {
+			t.Errorf("token %q status = %d", token, status)
+		}
+	}
+
+	// List
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/secrets", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	s.ServeHTTP(w, r)
+	var list []serveSecret
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list) != 7 || list[0] != (serveSecret{Name: "a/b", Type: totp.TypeTOTP}) {
+		t.Errorf("list = %s, %v", w.Body.String(), err)
+	}
+
+	// Code, with the current and a given time
+	want, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime)
+	status, result := serveRequest(t, s, http.MethodGet, "/v1/secrets/name0/code", "token", "")
+	if status != http.StatusOK || result["code"] != want || result["remaining"] != float64(20) || result["period"] != float64(30) {
+		t.Errorf("code = %d, %v", status, result)
+	}
+
+	status, result = serveRequest(t, s, http.MethodGet, "/v1/secrets/a%2Fb/code?time=2019-06-23T20:00:50Z", "token", "")
+	if status != http.StatusOK || result["name"] != "a/b" || result["remaining"] != float64(10) {
+		t.Errorf("escaped name code = %d, %v", status, result)
+	}
+
+	// Remaining
+	status, result = serveRequest(t, s, http.MethodGet, "/v1/secrets/name0/remaining", "token", "")
+	if status != http.StatusOK || result["remaining"] != float64(20) || result["code"] != nil {
+		t.Errorf("remaining = %d, %v", status, result)
+	}
+
+	// Verify
+	status, result = serveRequest(t, s, http.MethodPost, "/v1/secrets/name0/verify", "token", `{"code":"`+want+`"}`)
+	if status != http.StatusOK || result["valid"] != true || result["step"] != float64(0) {
+		t.Errorf("verify = %d, %v", status, result)
+	}
+
+	status, result = serveRequest(t, s, http.MethodPost, "/v1/secrets/name0/verify", "token", `{"code":"`+want+`","skew":0,"time":"2019-06-23T20:00:40Z"}`)
+	if status != http.StatusOK || result["valid"] != false {
+		t.Errorf("verify mismatch = %d, %v", status, result)
+	}
+
+	// Errors
+	for _, tc := range []struct {
+		method, target, body string
+		status               int
+	}{
+		{http.MethodGet, "/v1/secrets/invalidname/code", "", http.StatusNotFound},
+		{http.MethodGet, "/v1/secrets/name0/invalid", "", http.StatusNotFound},
+		{http.MethodGet, "/v1/other", "", http.StatusNotFound},
+		{http.MethodPost, "/v1/secrets", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/v1/secrets/name0/code", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/v1/secrets/name0/verify", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/v1/secrets/name0/code?time=noon", "", http.StatusBadRequest},
+		{http.MethodPost, "/v1/secrets/name0/verify", "{", http.StatusBadRequest},
+		{http.MethodPost, "/v1/secrets/name0/verify", `{"code":"1","time":"noon"}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/secrets/name0/verify", `{"code":"1","skew":4000000000}`, http.StatusBadRequest},
+	} {
+		if status, result := serveRequest(t, s, tc.method, tc.target, "token", tc.body); status != tc.status || result["error"] == nil {
+			t.Errorf("%s %s = %d, %v, want %d", tc.method, tc.target, status, result, tc.status)
+		}
+	}
+}
+
+func TestCodeServerHOTP(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	c, _ := totp.NewCollectionWithFile(collectionFile.filename)
+	_, _ = c.UpdateSecretWithOptions("counter", "SEED", totp.SecretOptions{Type: totp.TypeHOTP})
+	_ = c.Save()
+
+	c, _ = collectionFile.loader()
+	s := &codeServer{c: c, loader: collectionFile.loader, now: time.Now}
+
+	// Read-only servers do not advance counters
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		action := map[string]string{http.MethodGet: "code", http.MethodPost: "verify"}[method]
+		if status, _ := serveRequest(t, s, method, "/v1/secrets/counter/"+action, "", `{"code":"1"}`); status != http.StatusForbidden {
+			t.Errorf("read-only %s status = %d", action, status)
+		}
+	}
+
+	if status, _ := serveRequest(t, s, http.MethodGet, "/v1/secrets/counter/remaining", "", ""); status != http.StatusBadRequest {
+		t.Errorf("HOTP remaining status = %d", status)
+	}
+
+	// Changes made by other commands are kept when a counter is saved
+	other, _ := collectionFile.loader()
+	_, _ = other.UpdateSecret("other", "SEED")
+	_ = other.Save()
+
+	s.readWrite = true
+	want, _ := totp.Secret{Value: "SEED", Type: totp.TypeHOTP}.GenerateCodeWithTime(time.Time{})
+	status, result := serveRequest(t, s, http.MethodGet, "/v1/secrets/counter/code", "", "")
+	if status != http.StatusOK || result["code"] != want || result["counter"] != float64(0) || result["type"] != totp.TypeHOTP {
+		t.Errorf("HOTP code = %d, %v", status, result)
+	}
+
+	next, _ := totp.Secret{Value: "SEED", Type: totp.TypeHOTP, Counter: 2}.GenerateCodeWithTime(time.Time{})
+	status, result = serveRequest(t, s, http.MethodPost, "/v1/secrets/counter/verify", "", `{"code":"`+next+`"}`)
+	if status != http.StatusOK || result["valid"] != true || result["step"] != float64(1) {
+		t.Errorf("HOTP verify = %d, %v", status, result)
+	}
+
+	saved, _ := collectionFile.loader()
+	if secret, _ := saved.GetSecret("counter"); secret.Counter != 3 {
+		t.Errorf("saved counter = %d, want 3", secret.Counter)
+	}
+	if _, err := saved.GetSecret("other"); err != nil {
+		t.Errorf("secret added by another command was lost: %v", err)
+	}
+
+	// Skews above the maximum are refused without searching the counters
+	if status, _ := serveRequest(t, s, http.MethodPost, "/v1/secrets/counter/verify", "", `{"code":"1","skew":4000000000}`); status != http.StatusBadRequest {
+		t.Errorf("HOTP verify with skew too large status = %d", status)
+	}
+
+	// A mismatch does not change the counter
+	status, result = serveRequest(t, s, http.MethodPost, "/v1/secrets/counter/verify", "", `{"code":"`+next+`"}`)
+	if status != http.StatusOK || result["valid"] != false {
+		t.Errorf("HOTP verify mismatch = %d, %v", status, result)
+	}
+}
+
+func TestRunServe(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+
+	// Token file
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	_ = os.WriteFile(tokenFile, []byte("filetoken\n"), 0600)
+	if token, err := readServeToken(tokenFile); err != nil || token != "filetoken" {
+		t.Errorf("readServeToken() = %q, %v", token, err)
+	}
+	t.Setenv(envServeToken, "envtoken")
+	if token, err := readServeToken(""); err != nil || token != "envtoken" {
+		t.Errorf("readServeToken() = %q, %v", token, err)
+	}
+	if err := runServe(serveVars{tokenFile: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("runServe() with a missing token file succeeded")
+	}
+
+	if token, err := newServeToken(); err != nil || len(token) != 64 {
+		t.Errorf("newServeToken() = %q, %v", token, err)
+	}
+
+	// Invalid address
+	if err := runServe(serveVars{listen: "invalid:address:0"}); err == nil {
+		t.Error("runServe() with an invalid address succeeded")
+	}
+
+	// Only loopback addresses are listened on
+	for _, address := range []string{"0.0.0.0:0", ":0", "192.0.2.1:0", "example.com:0"} {
+		if _, err := serveListen(serveVars{listen: address}); !errors.Is(err, errNotLoopback) {
+			t.Errorf("serveListen(%q) error = %v", address, err)
+		}
+	}
+	for _, address := range []string{"127.0.0.1:0", "[::1]:0", "localhost:0"} {
+		if err := checkLoopback(address); err != nil {
+			t.Errorf("checkLoopback(%q) error = %v", address, err)
+		}
+	}
+
+	// Unix sockets are only accessible by the user
+	socket := filepath.Join(t.TempDir(), "totp.sock")
+	listener, err := serveListen(serveVars{socket: socket})
+	if err != nil {
+		t.Skip("Unix sockets are not supported:", err)
+	}
+	defer listener.Close()
+
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v", info, err)
+	}
+
+	c, _ := collectionFile.loader()
+	go func() {
+		_ = http.Serve(listener, &codeServer{c: c, loader: collectionFile.loader, now: time.Now})
+	}()
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://totp/v1/secrets/name0/remaining")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("socket request = %v, %v", resp, err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	envNTPServer       = "TOTP_NTP_SERVER"
+	defaultNTPServer   = "pool.ntp.org"
+	ntpPort            = "123"
+	defaultSNTPTimeout = 5 * time.Second
+
+	// ntpEpochOffset is the number of seconds from the NTP epoch, the start
+	// of 1900, to the Unix epoch
+	ntpEpochOffset = 2208988800
+
+	sntpPacketSize = 48
+
+	// sntpClientRequest is the first byte of a request: no leap second
+	// warning, version 4, and client mode
+	sntpClientRequest = 4<<3 | sntpModeClient
+
+	sntpModeClient = 3
+	sntpModeServer = 4
+
+	// sntpUnsynchronized is the leap indicator of a server whose clock is
+	// not synchronized
+	sntpUnsynchronized = 3
+)
+
+var errSNTPResponse = errors.New("invalid SNTP response")
+
+// sntpResult is the result of an SNTP query. The offset is the time to add
+// to the local clock to get the server's time.
+type sntpResult struct {
+	server  string
+	stratum int
+	offset  time.Duration
+	delay   time.Duration
+}
+
+// ntpServerAddress returns the host and port of an NTP server. An empty
+// server selects the TOTP_NTP_SERVER environment variable or, if not set,
+// pool.ntp.org, and the NTP port is used when none is given.
+func ntpServerAddress(server string) string {
+	if len(server) == 0 {
+		server = os.Getenv(envNTPServer)
+	}
+	if len(server) == 0 {
+		server = defaultNTPServer
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), ntpPort)
+	}
+
+	return server
+}
+
+// toNTPTime returns t as an NTP timestamp, the seconds since 1900 in the
+// high 32 bits and the fraction of a second in the low 32 bits
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+
+	return seconds<<32 | fraction
+}
+
+// fromNTPTime returns the time of an NTP timestamp
+func fromNTPTime(timestamp uint64) time.Time {
+	seconds := int64(timestamp>>32) - ntpEpochOffset
+	nanoseconds := int64(timestamp&0xffffffff) * int64(time.Second) >> 32
+
+	return time.Unix(seconds, nanoseconds)
+}
+
+// querySNTP asks an NTP server for its time as RFC 4330 describes and
+// returns the offset of the local clock from it. The local times of the
+// request and response are read from the clock.
+func querySNTP(server string, timeout time.Duration, clock clock) (sntpResult, error) {
+	address := ntpServerAddress(server)
+
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return sntpResult{}, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return sntpResult{}, err
+	}
+
+	request := make([]byte, sntpPacketSize)
+	request[0] = sntpClientRequest
+
+	sent := clock.Now()
+	binary.BigEndian.PutUint64(request[40:], toNTPTime(sent))
+	if _, err := conn.Write(request); err != nil {
+		return sntpResult{}, err
+	}
+
+	// Responses that do not answer this request are ignored until the
+	// deadline, as they may be late answers to an earlier one
+	response := make([]byte, 2*sntpPacketSize)
+	for {
+		n, err := conn.Read(response)
+		if err != nil {
+			return sntpResult{}, err
+		}
+		received := clock.Now()
+
+		if n < sntpPacketSize {
+			return sntpResult{}, fmt.Errorf("the SNTP response is %d bytes, less than %d", n, sntpPacketSize)
+		}
+
+		if binary.BigEndian.Uint64(response[24:]) != binary.BigEndian.Uint64(request[40:]) {
+			continue
+		}
+
+		return sntpResponse(address, response, sent, received)
+	}
+}
+
+// sntpResponse checks the response of a server to a request sent and
+// received at the local times given, and returns the result
+func sntpResponse(address string, response []byte, sent, received time.Time) (sntpResult, error) {
+	if mode := response[0] & 0x7; mode != sntpModeServer {
+		return sntpResult{}, fmt.Errorf("%w: mode %d is not a server response", errSNTPResponse, mode)
+	}
+
+	stratum := int(response[1])
+	if stratum == 0 {
+		return sntpResult{}, fmt.Errorf("the NTP server refused the request with code %q", strings.TrimRight(string(response[12:16]), "\x00"))
+	}
+
+	if response[0]>>6 == sntpUnsynchronized {
+		return sntpResult{}, errors.New("the NTP server clock is not synchronized")
+	}
+
+	serverReceived := binary.BigEndian.Uint64(response[32:])
+	serverSent := binary.BigEndian.Uint64(response[40:])
+	if serverReceived == 0 || serverSent == 0 {
+		return sntpResult{}, fmt.Errorf("%w: the server times are missing", errSNTPResponse)
+	}
+
+	t2, t3 := fromNTPTime(serverReceived), fromNTPTime(serverSent)
+
+	return sntpResult{
+		server:  address,
+		stratum: stratum,
+		offset:  (t2.Sub(sent) + t3.Sub(received)) / 2,
+		delay:   received.Sub(sent) - t3.Sub(t2),
+	}, nil
+}
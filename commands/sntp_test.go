@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSNTPServer starts a stand-in NTP server on a local UDP port that
+// answers each request with respond, and returns its address
+func startSNTPServer(t *testing.T, respond func(request []byte) []byte) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("UDP is not available:", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if response := respond(buf[:n]); response != nil {
+				_, _ = conn.WriteTo(response, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// sntpServerResponse returns the response of a synchronized stratum 2
+// server whose clock is offset from the time the request was sent
+func sntpServerResponse(request []byte, offset time.Duration) []byte {
+	sent := binary.BigEndian.Uint64(request[40:])
+	serverTime := toNTPTime(fromNTPTime(sent).Add(offset))
+
+	response := make([]byte, sntpPacketSize)
+	response[0] = 4<<3 | sntpModeServer
+	response[1] = 2
+	binary.BigEndian.PutUint64(response[24:], sent)
+	binary.BigEndian.PutUint64(response[32:], serverTime)
+	binary.BigEndian.PutUint64(response[40:], serverTime)
+
+	return response
+}
+
+func TestNTPTime(t *testing.T) {
+	want := time.Date(2019, 6, 23, 20, 0, 10, 500000000, time.UTC)
+	if got := fromNTPTime(toNTPTime(want)); got.Sub(want).Abs() > time.Nanosecond {
+		t.Errorf("fromNTPTime(toNTPTime()) = %v, want %v", got, want)
+	}
+
+	// The NTP epoch is 1900
+	if got := toNTPTime(time.Unix(0, 0)); got != ntpEpochOffset<<32 {
+		t.Errorf("toNTPTime(Unix epoch) = %x", got)
+	}
+}
+
+func TestNTPServerAddress(t *testing.T) {
+	t.Setenv(envNTPServer, "")
+	if got := ntpServerAddress(""); got != "pool.ntp.org:123" {
+		t.Errorf("ntpServerAddress() = %s", got)
+	}
+
+	t.Setenv(envNTPServer, "time.example.com")
+	for server, want := range map[string]string{
+		"":                   "time.example.com:123",
+		"ntp.example.com":    "ntp.example.com:123",
+		"127.0.0.1:1123":     "127.0.0.1:1123",
+		"::1":                "[::1]:123",
+		"[::1]":              "[::1]:123",
+		"[::1]:1123":         "[::1]:1123",
+		"ntp.example.com:12": "ntp.example.com:12",
+	} {
+		if got := ntpServerAddress(server); got != want {
+			t.Errorf("ntpServerAddress(%q) = %s, want %s", server, got, want)
+		}
+	}
+}
+
+func TestQuerySNTP(t *testing.T) {
+	clock := &testClock{now: time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)}
+
+	// The local clock is behind the server
+	server := startSNTPServer(t, func(request []byte) []byte {
+		if request[0] != sntpClientRequest || len(request) != sntpPacketSize {
+			t.Errorf("request = %x", request)
+		}
+		return sntpServerResponse(request, 10*time.Second)
+	})
+
+	result, err := querySNTP(server, time.Second, clock)
+	if err != nil || result.offset != 10*time.Second || result.delay != 0 || result.stratum != 2 || result.server != server {
+		t.Errorf("querySNTP() = %+v, %v", result, err)
+	}
+
+	// Time spent by the server is not part of the delay
+	server = startSNTPServer(t, func(request []byte) []byte {
+		response := sntpServerResponse(request, -time.Second)
+		binary.BigEndian.PutUint64(response[40:], binary.BigEndian.Uint64(response[32:])+1<<31)
+		return response
+	})
+
+	if result, err := querySNTP(server, time.Second, clock); err != nil || result.offset != -750*time.Millisecond || result.delay != -500*time.Millisecond {
+		t.Errorf("querySNTP() = %+v, %v", result, err)
+	}
+}
+
+func TestQuerySNTPErrors(t *testing.T) {
+	clock := &testClock{now: time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)}
+
+	tests := []struct {
+		name    string
+		respond func(request []byte) []byte
+		want    string
+	}{
+		{"kiss of death", func(request []byte) []byte {
+			response := sntpServerResponse(request, 0)
+			response[1] = 0
+			copy(response[12:], "RATE")
+			return response
+		}, `refused the request with code "RATE"`},
+		{"unsynchronized", func(request []byte) []byte {
+			response := sntpServerResponse(request, 0)
+			response[0] |= sntpUnsynchronized << 6
+			return response
+		}, "not synchronized"},
+		{"client mode", func(request []byte) []byte {
+			response := sntpServerResponse(request, 0)
+			response[0] = 4<<3 | sntpModeClient
+			return response
+		}, "not a server response"},
+		{"missing times", func(request []byte) []byte {
+			response := sntpServerResponse(request, 0)
+			binary.BigEndian.PutUint64(response[40:], 0)
+			return response
+		}, "times are missing"},
+		{"short", func(request []byte) []byte {
+			return sntpServerResponse(request, 0)[:40]
+		}, "less than 48"},
+		{"other request", func(request []byte) []byte {
+			response := sntpServerResponse(request, 0)
+			binary.BigEndian.PutUint64(response[24:], 1)
+			return response
+		}, "timeout"},
+		{"no response", func(request []byte) []byte {
+			return nil
+		}, "timeout"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := startSNTPServer(t, tc.respond)
+			if _, err := querySNTP(server, 100*time.Millisecond, clock); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("querySNTP() error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+
+	if _, err := querySNTP("invalid:address:123", time.Second, clock); err == nil {
+		t.Error("querySNTP() with invalid address succeeded")
+	}
+
+	// Responses are checked before the times are used
+	_, err := sntpResponse("server", make([]byte, sntpPacketSize), clock.now, clock.now)
+	if !errors.Is(err, errSNTPResponse) {
+		t.Errorf("sntpResponse() error = %v", err)
+	}
+}
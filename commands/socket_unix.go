@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package commands
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix listens on a Unix socket created readable and writable only
+// by the user, so no other user can connect before its mode is set
+func listenUnix(socket string) (net.Listener, error) {
+	umask := syscall.Umask(0177)
+	defer syscall.Umask(umask)
+
+	return net.Listen("unix", socket)
+}
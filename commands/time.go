@@ -0,0 +1,17 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func getTimeCmd() *cobra.Command {
+	var cobraCmd = &cobra.Command{
+		Use:   cmdTime,
+		Short: "Check the clock codes are generated with",
+		Long:  `Check the clock codes are generated with`,
+	}
+
+	cobraCmd.AddCommand(getTimeCheckCmd())
+
+	return cobraCmd
+}
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	optionTimeout = "timeout"
+
+	// driftWarning is the clock offset from which codes may be rejected
+	// near the end of their periods
+	driftWarning = time.Second
+)
+
+// timeCheckDocument is the structured output of the time check command.
+// The offset and delay are in seconds.
+type timeCheckDocument struct {
+	Server  string  `json:"server" yaml:"server"`
+	Stratum int     `json:"stratum" yaml:"stratum"`
+	Offset  float64 `json:"offset" yaml:"offset"`
+	Delay   float64 `json:"delay" yaml:"delay"`
+}
+
+// checkTime writes the offset of the local clock from an NTP server
+func checkTime(writer io.Writer, server string, timeout time.Duration) error {
+	result, err := querySNTP(server, timeout, commandClock)
+	if err != nil {
+		printError("Error checking the time", err)
+		return err
+	}
+
+	if structuredOutput() {
+		return outputDocument(writer, timeCheckDocument{
+			Server:  result.server,
+			Stratum: result.stratum,
+			Offset:  result.offset.Seconds(),
+			Delay:   result.delay.Seconds(),
+		})
+	}
+
+	fmt.Fprintf(writer, "Server: %s (stratum %d)\n", result.server, result.stratum)
+
+	offset := result.offset.Round(time.Millisecond)
+	switch {
+	case offset > 0:
+		fmt.Fprintf(writer, "Offset: +%v, the local clock is behind\n", offset)
+	case offset < 0:
+		fmt.Fprintf(writer, "Offset: %v, the local clock is ahead\n", offset)
+	default:
+		fmt.Fprintln(writer, "Offset: 0s")
+	}
+
+	fmt.Fprintf(writer, "Round trip: %v\n", result.delay.Round(time.Millisecond))
+
+	if offset >= driftWarning || offset <= -driftWarning {
+		fmt.Fprintf(writer, "Codes may be rejected. Use the --%s option to correct them.\n", optionNTP)
+	}
+
+	return nil
+}
+
+func getTimeCheckCmd() *cobra.Command {
+	var (
+		timeout  time.Duration
+		cobraCmd = &cobra.Command{
+			Use:   "check [server]",
+			Short: "Check the local clock against an NTP server",
+			Long: `Check the local clock against an NTP server
+
+The server is queried with SNTP and the offset of the local clock from it
+is output, along with the round trip delay of the query. The server given,
+or else the one set with the TOTP_NTP_SERVER environment variable, is used,
+and pool.ntp.org otherwise.
+
+The exit status is 1 if the server could not be queried.`,
+			Args:          cobra.MaximumNArgs(1),
+			SilenceErrors: true,
+			SilenceUsage:  true,
+			RunE: func(_ *cobra.Command, args []string) error {
+				server := ""
+				if len(args) == 1 {
+					server = args[0]
+				}
+
+				if err := checkTime(os.Stdout, server, timeout); err != nil {
+					return exitError{status: 1}
+				}
+				return nil
+			},
+		}
+	)
+
+	cobraCmd.Flags().DurationVarP(&timeout, optionTimeout, "", defaultSNTPTimeout, "time to wait for the server")
+
+	return cobraCmd
+}
@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestCheckTime(t *testing.T) {
+	savedCommandClock := commandClock
+	commandClock = &testClock{now: time.Date(2019, 6, 23, 20, 0, 10, 0, time.UTC)}
+	defer func() { commandClock = savedCommandClock }()
+
+	offset := 1500 * time.Millisecond
+	server := startSNTPServer(t, func(request []byte) []byte {
+		return sntpServerResponse(request, offset)
+	})
+
+	writer := &bytes.Buffer{}
+	if err := checkTime(writer, server, time.Second); err != nil {
+		t.Fatal("checkTime() error:", err)
+	}
+	want := "Server: " + server + " (stratum 2)\nOffset: +1.5s, the local clock is behind\nRound trip: 0s\nCodes may be rejected. Use the --ntp option to correct them.\n"
+	if writer.String() != want {
+		t.Errorf("checkTime() = %q, want %q", writer.String(), want)
+	}
+
+	offset = -200 * time.Millisecond
+	writer.Reset()
+	if err := checkTime(writer, server, time.Second); err != nil || !strings.Contains(writer.String(), "Offset: -200ms, the local clock is ahead\nRound trip: 0s\n") || strings.Contains(writer.String(), "rejected") {
+		t.Errorf("checkTime() = %q, %v", writer.String(), err)
+	}
+
+	offset = 0
+	writer.Reset()
+	if err := checkTime(writer, server, time.Second); err != nil || !strings.Contains(writer.String(), "Offset: 0s\n") {
+		t.Errorf("checkTime() = %q, %v", writer.String(), err)
+	}
+
+	// Structured output
+	defer func() { outputFormat = outputText }()
+	_ = setOutputFormat(outputJSON)
+	offset = 1500 * time.Millisecond
+	writer.Reset()
+	var document timeCheckDocument
+	if err := checkTime(writer, server, time.Second); err != nil {
+		t.Fatal("checkTime() error:", err)
+	}
+	if err := json.Unmarshal(writer.Bytes(), &document); err != nil || document != (timeCheckDocument{Server: server, Stratum: 2, Offset: 1.5}) {
+		t.Errorf("checkTime() JSON = %q, %v", writer.String(), err)
+	}
+	_ = setOutputFormat(outputText)
+
+	// The command uses the server set in the environment, and exits with
+	// status 1 when the server cannot be queried
+	t.Setenv(envNTPServer, server)
+	timeCmd := getTimeCmd()
+	timeCmd.SetArgs([]string{"check"})
+	if stdout := captureStdout(t, func() { _ = timeCmd.Execute() }); !strings.HasPrefix(stdout, "Server: "+server) {
+		t.Errorf("time check output = %q", stdout)
+	}
+
+	silent := startSNTPServer(t, func([]byte) []byte { return nil })
+	timeCmd.SetArgs([]string{"check", "--timeout", "10ms", silent})
+	if err := timeCmd.Execute(); err != (exitError{status: 1}) {
+		t.Errorf("time check error = %v", err)
+	}
+}
+
+func TestNTPOption(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+
+	codeTime := time.Date(2019, 6, 23, 20, 0, 25, 0, time.UTC)
+	savedCommandClock := commandClock
+	commandClock = &testClock{now: codeTime}
+	defer func() { commandClock = savedCommandClock }()
+
+	server := startSNTPServer(t, func(request []byte) []byte {
+		return sntpServerResponse(request, 10*time.Second)
+	})
+	t.Setenv(envNTPServer, "127.0.0.1:1")
+
+	// Codes are generated for the server's time
+	want, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime.Add(10 * time.Second))
+	rootCmd := getRootCmd()
+	_ = rootCmd.Flags().Set(optionNTP, "true")
+	_ = rootCmd.Flags().Set(optionNTPServer, server)
+	if stdout := captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) }); stdout != want+"\n" {
+		t.Errorf("root command output = %q, want %q", stdout, want)
+	}
+	if commandClock.Now() != codeTime {
+		t.Error("the NTP offset was not removed from the clock")
+	}
+
+	// Following uses the corrected clock
+	var followed time.Time
+	rootCmd = getRootCmd()
+	savedGenerateCodesService := generateCodesService
+	generateCodesService = func(clock clock, timeOffset, _, _ time.Duration, _ codeFunc) {
+		followed = clock.Now().Add(timeOffset)
+	}
+	defer func() { generateCodesService = savedGenerateCodesService }()
+
+	_ = rootCmd.Flags().Set(optionNTP, "true")
+	_ = rootCmd.Flags().Set(optionNTPServer, server)
+	_ = rootCmd.Flags().Set(optionFollow, "true")
+	captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) })
+	if !followed.Equal(codeTime.Add(10 * time.Second)) {
+		t.Errorf("followed from %v", followed)
+	}
+
+	// No code is output when the server refuses the query
+	refusing := startSNTPServer(t, func(request []byte) []byte {
+		response := sntpServerResponse(request, 0)
+		response[1] = 0
+		return response
+	})
+	rootCmd = getRootCmd()
+	_ = rootCmd.Flags().Set(optionNTP, "true")
+	_ = rootCmd.Flags().Set(optionNTPServer, refusing)
+	if stdout := captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) }); len(stdout) != 0 {
+		t.Errorf("root command output = %q", stdout)
+	}
+}
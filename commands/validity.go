@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	optionMaxWait     = "max-wait"
+	optionMinValidity = "min-validity"
+)
+
+// codeValidity is how long a generated code must remain valid, and the
+// longest time to wait for the next code when the current one does not
+type codeValidity struct {
+	min     time.Duration
+	maxWait time.Duration
+}
+
+// codeTime returns t or, if the code of the period at t remains valid for
+// less than the minimum validity, waits with sleep until the next period
+// and returns its start. With no maximum wait, it waits until the current
+// code expires, which is less than the minimum validity.
+func (v codeValidity) codeTime(t time.Time, period time.Duration, sleep func(time.Duration)) (time.Time, error) {
+	if v.min <= 0 {
+		return t, nil
+	}
+
+	if v.min > period {
+		return t, fmt.Errorf("the minimum validity %v is longer than the %v period", v.min, period)
+	}
+
+	remaining := durationToNextInterval(t, period)
+	if remaining >= v.min {
+		return t, nil
+	}
+
+	if v.maxWait > 0 && remaining > v.maxWait {
+		return t, fmt.Errorf("the code expires in %v, and waiting for the next code is longer than the maximum wait of %v", remaining.Round(time.Millisecond), v.maxWait)
+	}
+
+	sleep(remaining)
+
+	return t.Add(remaining), nil
+}
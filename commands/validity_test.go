@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestCodeValidity(t *testing.T) {
+	codeTime := time.Date(2019, 6, 23, 20, 0, 25, 0, time.UTC)
+	next := time.Date(2019, 6, 23, 20, 0, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		validity codeValidity
+		period   time.Duration
+		want     time.Time
+		waited   time.Duration
+		wantErr  bool
+	}{
+		{"disabled", codeValidity{}, 30 * time.Second, codeTime, 0, false},
+		{"valid long enough", codeValidity{min: 5 * time.Second}, 30 * time.Second, codeTime, 0, false},
+		{"expires too soon", codeValidity{min: 10 * time.Second}, 30 * time.Second, next, 5 * time.Second, false},
+		{"secret period", codeValidity{min: 10 * time.Second}, 60 * time.Second, codeTime, 0, false},
+		{"within maximum wait", codeValidity{min: 10 * time.Second, maxWait: 5 * time.Second}, 30 * time.Second, next, 5 * time.Second, false},
+		{"beyond maximum wait", codeValidity{min: 10 * time.Second, maxWait: 4 * time.Second}, 30 * time.Second, codeTime, 0, true},
+		{"longer than period", codeValidity{min: 31 * time.Second}, 30 * time.Second, codeTime, 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var waited time.Duration
+			got, err := tc.validity.codeTime(codeTime, tc.period, func(d time.Duration) { waited += d })
+			if (err != nil) != tc.wantErr || !got.Equal(tc.want) || waited != tc.waited {
+				t.Errorf("codeTime() = %v, %v, waited %v, want %v, waited %v", got, err, waited, tc.want, tc.waited)
+			}
+		})
+	}
+}
+
+func TestMinValidity(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+
+	codeTime := time.Date(2019, 6, 23, 20, 0, 28, 0, time.UTC)
+	codeClock := &testClock{now: codeTime}
+	savedCommandClock := commandClock
+	commandClock = codeClock
+	defer func() { commandClock = savedCommandClock }()
+
+	current, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime)
+	next, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime.Add(2 * time.Second))
+
+	run := func(validity string) string {
+		rootCmd := getRootCmd()
+		codeClock.now = codeTime
+		_ = rootCmd.Flags().Set(optionMinValidity, validity)
+		return captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) })
+	}
+
+	if stdout := run("1s"); stdout != current+"\n" || codeClock.slept != 0 {
+		t.Errorf("root command output = %q, waited %v", stdout, codeClock.slept)
+	}
+	if stdout := run("10s"); stdout != next+"\n" || codeClock.slept != 2*time.Second {
+		t.Errorf("root command output = %q, waited %v", stdout, codeClock.slept)
+	}
+
+	// Codes are not output when they cannot be valid long enough
+	codeClock.slept = 0
+	if stdout := run("1m"); len(stdout) != 0 || codeClock.slept != 0 {
+		t.Errorf("root command output = %q, waited %v", stdout, codeClock.slept)
+	}
+
+	// Following starts from the time given, not moved back by the wait
+	var followed time.Time
+	savedGenerateCodesService := generateCodesService
+	defer func() { generateCodesService = savedGenerateCodesService }()
+
+	for _, timeString := range []string{"", codeTime.Add(-time.Minute).Format(time.RFC3339)} {
+		rootCmd := getRootCmd()
+		generateCodesService = func(clock clock, timeOffset, _, _ time.Duration, _ codeFunc) {
+			followed = clock.Now().Add(timeOffset)
+		}
+		codeClock.now = codeTime
+		_ = rootCmd.Flags().Set(optionMinValidity, "10s")
+		_ = rootCmd.Flags().Set(optionFollow, "true")
+		_ = rootCmd.Flags().Set(optionTime, timeString)
+		captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, []string{"name0"}) })
+
+		want := codeTime.Add(2 * time.Second)
+		if len(timeString) != 0 {
+			want = want.Add(-time.Minute)
+		}
+		if !followed.Equal(want) {
+			t.Errorf("time %q followed from %v, want %v", timeString, followed, want)
+		}
+	}
+
+	// The option cannot be used with all codes
+	rootCmd := getRootCmd()
+	_ = rootCmd.Flags().Set(optionMinValidity, "10s")
+	_ = rootCmd.Flags().Set(optionAll, "true")
+	if stdout := captureStdout(t, func() { _ = rootCmd.RunE(rootCmd, nil) }); !strings.HasPrefix(stdout, "TOTP Generator") {
+		t.Errorf("root command output = %q, want help", stdout)
+	}
+}
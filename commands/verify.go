@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/arcanericky/totp"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// verifyExitMismatch is the exit status when the code does not match
+	verifyExitMismatch = 1
+
+	// verifyExitError is the exit status when the code could not be checked
+	verifyExitError = 2
+)
+
+type verifyVars struct {
+	secret     string
+	opts       api.SecretOptions
+	timeString string
+	backward   time.Duration
+	forward    time.Duration
+	skew       uint
+	json       bool
+}
+
+// verifyResult is the JSON output of the verify command
+type verifyResult struct {
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+	Valid bool   `json:"valid" yaml:"valid"`
+	Step  int    `json:"step" yaml:"step"`
+}
+
+func outputVerifyResult(writer io.Writer, result verifyResult, asJSON bool) {
+	if asJSON {
+		if err := json.NewEncoder(writer).Encode(result); err != nil {
+			printError("Error writing result", err)
+		}
+		return
+	}
+
+	if structuredOutput() {
+		if err := outputDocument(writer, result); err != nil {
+			printError("Error writing result", err)
+		}
+		return
+	}
+
+	if result.Valid {
+		fmt.Fprintf(writer, "Code matched at step %d\n", result.Step)
+		return
+	}
+
+	fmt.Fprintln(writer, "Code did not match")
+}
+
+// verifyCode checks a code against a stored or given secret, writes the
+// result, and returns the exit status
+func verifyCode(writer io.Writer, args []string, cfg verifyVars) int {
+	name, code := "", ""
+	switch {
+	case len(cfg.secret) != 0 && len(args) == 1:
+		code = args[0]
+	case len(cfg.secret) == 0 && len(args) == 2:
+		name, code = args[0], args[1]
+	default:
+		printError("Error verifying code", errors.New("a secret name and code, or --secret and a code, are required"))
+		return verifyExitError
+	}
+
+	codeTime, err := parseTimeOption(cfg.timeString)
+	if err != nil {
+		printError("Error parsing the time option", err)
+		return verifyExitError
+	}
+	codeTime = codeTime.Add(cfg.forward - cfg.backward)
+
+	// the collection is locked in case a matched HOTP code advances the
+	// counter
+	if len(cfg.secret) == 0 {
+		unlock, err := lockCollection()
+		if err != nil {
+			// lockCollection will output error text
+			return verifyExitError
+		}
+		defer unlock()
+	}
+
+	secret, c, err := getSecret(name, cfg.secret, cfg.opts)
+	if err != nil {
+		// getSecret will output error text
+		return verifyExitError
+	}
+
+	var step int
+	if c != nil {
+		step, err = c.VerifyCode(name, code, codeTime, cfg.skew)
+	} else {
+		step, err = secret.VerifyCodeWithTime(code, codeTime, cfg.skew)
+	}
+
+	result := verifyResult{Name: name, Valid: err == nil, Step: step}
+	if err != nil && !errors.Is(err, api.ErrCodeMismatch) {
+		printError("Error verifying code", err)
+		return verifyExitError
+	}
+
+	// a matched HOTP code advances the counter
+	if result.Valid && secret.IsHOTP() {
+		if err := c.Save(); err != nil {
+			printError("Error saving settings", err)
+			return verifyExitError
+		}
+
+		if collectionFile.useStdio {
+			writer = os.Stderr
+		}
+	}
+
+	outputVerifyResult(writer, result, cfg.json)
+
+	if !result.Valid {
+		return verifyExitMismatch
+	}
+
+	return 0
+}
+
+func getVerifyCmd(rootCmd *cobra.Command) *cobra.Command {
+	var (
+		cfg      verifyVars
+		cobraCmd = &cobra.Command{
+			Use:   cmdVerify,
+			Short: "Verify a code against a secret",
+			Long: `Verify a code against a secret
+
+The code is checked against the periods up to --skew steps before and after
+the current time, nearest first. The matching step is output, where 0 is
+the current period, -1 the previous period, and 1 the next. An HOTP code is
+checked against the counter and the --skew counters after it, and a match
+advances the counter past it. The skew can be at most ` + strconv.Itoa(api.MaxSkew) + `.
+
+The exit status is 0 if the code matched, 1 if it did not, and 2 if it
+could not be checked.`,
+			ValidArgsFunction: validArgs,
+			SilenceErrors:     true,
+			SilenceUsage:      true,
+			RunE: func(_ *cobra.Command, args []string) error {
+				if status := verifyCode(os.Stdout, args, cfg); status != 0 {
+					return exitError{status: status}
+				}
+				return nil
+			},
+		}
+	)
+
+	var duration time.Duration
+
+	cobraCmd.Flags().StringVarP(&cfg.secret, optionSecret, "s", "", "TOTP secret value")
+	addSecretOptionFlags(cobraCmd, &cfg.opts, " for --secret")
+	cobraCmd.Flags().BoolP(optionStdio, "", false, "load with stdin, save HOTP counters with stdout")
+	cobraCmd.Flags().StringVarP(&cfg.timeString, optionTime, "", "", "RFC3339 time for TOTP (2019-06-23T20:00:00-05:00)")
+	cobraCmd.Flags().DurationVarP(&cfg.backward, optionBackward, "", duration, "move time backward (ex. \"30s\")")
+	cobraCmd.Flags().DurationVarP(&cfg.forward, optionForward, "", duration, "move time forward (ex. \"1m\")")
+	cobraCmd.Flags().UintVarP(&cfg.skew, "skew", "", api.DefaultSkew, "number of steps either side of the current step to accept")
+	cobraCmd.Flags().BoolVarP(&cfg.json, "json", "", false, "output the result as JSON")
+	cobraCmd.SetUsageTemplate(strings.Replace(rootCmd.UsageTemplate(), "{{.UseLine}}", "{{.UseLine}}\n  {{.CommandPath}} [secret name] [code]\n  {{.CommandPath}} --secret [secret] [code]", 1))
+
+	return cobraCmd
+}
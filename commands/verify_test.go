@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcanericky/totp"
+)
+
+func TestVerify(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	createTestData(t)
+
+	codeTime := time.Date(2019, 6, 23, 20, 0, 0, 0, time.UTC)
+	code, _ := totp.Secret{Value: "SEED"}.GenerateCodeWithTime(codeTime.Add(-30 * time.Second))
+	cfg := verifyVars{timeString: codeTime.Format(time.RFC3339), skew: 1}
+
+	writer := &bytes.Buffer{}
+	if status := verifyCode(writer, []string{"name0", code}, cfg); status != 0 || writer.String() != "Code matched at step -1\n" {
+		t.Errorf("verifyCode() = %d, %s", status, writer.String())
+	}
+
+	// Time machine options move the window
+	writer.Reset()
+	cfg.backward = 30 * time.Second
+	cfg.json = true
+	if status := verifyCode(writer, []string{"name0", code}, cfg); status != 0 || writer.String() != `{"name":"name0","valid":true,"step":0}`+"\n" {
+		t.Errorf("verifyCode() = %d, %s", status, writer.String())
+	}
+
+	cfg.forward = 90 * time.Second
+	writer.Reset()
+	if status := verifyCode(writer, []string{"name0", code}, cfg); status != verifyExitMismatch || !strings.Contains(writer.String(), `"valid":false`) {
+		t.Errorf("verifyCode() = %d, %s", status, writer.String())
+	}
+
+	// Secret option
+	cfg = verifyVars{secret: "SEED", timeString: codeTime.Format(time.RFC3339)}
+	writer.Reset()
+	if status := verifyCode(writer, []string{code}, cfg); status != verifyExitMismatch || writer.String() != "Code did not match\n" {
+		t.Errorf("verifyCode() = %d, %s", status, writer.String())
+	}
+
+	// Argument, time, secret, and option errors
+	for _, args := range [][]string{{"name0"}, {"name0", code, "extra"}} {
+		if status := verifyCode(writer, args, verifyVars{}); status != verifyExitError {
+			t.Errorf("verifyCode(%v) = %d, want %d", args, status, verifyExitError)
+		}
+	}
+	if status := verifyCode(writer, []string{"name0", code}, verifyVars{timeString: "noon"}); status != verifyExitError {
+		t.Errorf("verifyCode() with invalid time = %d", status)
+	}
+	if status := verifyCode(writer, []string{"invalidname", code}, verifyVars{}); status != verifyExitError {
+		t.Errorf("verifyCode() with invalid name = %d", status)
+	}
+	if status := verifyCode(writer, []string{code}, verifyVars{secret: "SEED", opts: totp.SecretOptions{Digits: 9}}); status != verifyExitError {
+		t.Errorf("verifyCode() with invalid digits = %d", status)
+	}
+	if status := verifyCode(writer, []string{"name0", code}, verifyVars{skew: 4000000000}); status != verifyExitError {
+		t.Errorf("verifyCode() with skew too large = %d", status)
+	}
+
+	// Command exit status
+	verifyCmd := getVerifyCmd(getRootCmd())
+	var exitErr exitError
+	if err := verifyCmd.RunE(nil, []string{"name0", "000000"}); !errors.As(err, &exitErr) || exitErr.status != verifyExitMismatch {
+		t.Errorf("RunE() error = %v", err)
+	}
+}
+
+func TestVerifyHOTP(t *testing.T) {
+	collectionFile.filename = "testcollection.json"
+	collectionFile.loader = loadCollectionFromDefaultFile
+	defer os.Remove(collectionFile.filename)
+
+	c, _ := totp.NewCollectionWithFile(collectionFile.filename)
+	_, _ = c.UpdateSecretWithOptions("hotpname", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", totp.SecretOptions{Type: totp.TypeHOTP})
+	_ = c.Save()
+
+	// 287082 is the code for counter 1
+	writer := &bytes.Buffer{}
+	if status := verifyCode(writer, []string{"hotpname", "287082"}, verifyVars{skew: 1}); status != 0 || writer.String() != "Code matched at step 1\n" {
+		t.Errorf("verifyCode() = %d, %s", status, writer.String())
+	}
+
+	c, _ = totp.NewCollectionWithFile(collectionFile.filename)
+	if s, _ := c.GetSecret("hotpname"); s.Counter != 2 {
+		t.Errorf("Counter = %d, want 2", s.Counter)
+	}
+
+	// The code cannot be reused
+	if status := verifyCode(writer, []string{"hotpname", "287082"}, verifyVars{skew: 1}); status != verifyExitMismatch {
+		t.Errorf("verifyCode() = %d, want %d", status, verifyExitMismatch)
+	}
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 
 	"github.com/spf13/cobra"
@@ -15,6 +16,13 @@ func getVersionCmd() *cobra.Command {
 		Short: "Show totp version",
 		Long:  "Show totp version",
 		Run: func(_ *cobra.Command, _ []string) {
+			if structuredOutput() {
+				if err := outputDocument(os.Stdout, newVersionDocument()); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+				return
+			}
+
 			fmt.Printf("totp version %s %s/%s\n", versionText, runtime.GOOS, runtime.GOARCH)
 		},
 	}
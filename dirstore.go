@@ -0,0 +1,354 @@
+package totp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	dirStoreExt = ".json"
+
+	// dirStoreVersionFile holds the schema version of the store. It is
+	// hidden so it is not taken for a secret file.
+	dirStoreVersionFile = ".version.json"
+)
+
+// ErrNameCollision is returned when a directory store is saved with secret
+// names that differ only in case
+var ErrNameCollision = errors.New("secret names differ only in case")
+
+// DirStore keeps a collection in a directory with a file for each secret,
+// which suits version control and file synchronization tools. When the
+// collection is encrypted, each file is encrypted.
+type DirStore struct {
+	// Dir is the directory holding the secret files
+	Dir string
+
+	// key encrypts the secret files. It is derived once, so every file
+	// shares its salt and loading needs one key derivation.
+	key *dirStoreKey
+
+	// saved holds the serialized secret of each file as last loaded or
+	// saved with the store key, so only changed secrets are written
+	saved map[string][]byte
+
+	// versioned is set when the version file is at SchemaVersion
+	versioned bool
+
+	// version is the schema version the secret files were saved at, which
+	// they are upgraded from when loaded
+	version int
+}
+
+// dirStoreVersion is the content of the version file
+type dirStoreVersion struct {
+	Version int
+}
+
+// dirStoreKey is a key derived from the collection passphrase and the
+// encryption parameters it was derived with
+type dirStoreKey struct {
+	header     encryptionHeader
+	key        []byte
+	passphrase string
+}
+
+// sameParams reports whether the encryption parameters of a file are
+// those the key was derived with
+func (k *dirStoreKey) sameParams(h encryptionHeader) bool {
+	return h.KDF == k.header.KDF && bytes.Equal(h.Salt, k.header.Salt) &&
+		h.N == k.header.N && h.R == k.header.R && h.P == k.header.P && h.Cipher == k.header.Cipher
+}
+
+// dirStoreFilename returns the file name of a secret. Characters other
+// than letters, digits, and a few safe punctuation marks are escaped so
+// any secret name makes a valid and unique file name.
+func dirStoreFilename(name string) string {
+	var builder strings.Builder
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '@', b == '+', b == '.' && i != 0:
+			builder.WriteByte(b)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+
+	return builder.String() + dirStoreExt
+}
+
+// dirStoreFilenames returns the file name of each secret. Names that
+// differ only in case are refused, as their files would be the same file
+// on case-insensitive file systems such as those of macOS and Windows.
+func dirStoreFilenames(secrets map[string]Secret) (map[string]string, error) {
+	filenames := map[string]string{}
+	folded := map[string]string{}
+	for name := range secrets {
+		filename := dirStoreFilename(name)
+		if other, ok := folded[strings.ToLower(filename)]; ok {
+			if other > name {
+				other, name = name, other
+			}
+			return nil, fmt.Errorf("%w: %q and %q", ErrNameCollision, other, name)
+		}
+
+		folded[strings.ToLower(filename)] = name
+		filenames[name] = filename
+	}
+
+	return filenames, nil
+}
+
+// isDirStoreFile reports whether a directory entry holds a secret. Hidden
+// files, such as the lock and temporary files, are skipped.
+func isDirStoreFile(entry os.DirEntry) bool {
+	return !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && strings.HasSuffix(entry.Name(), dirStoreExt)
+}
+
+// open returns the serialized secret of a file, decrypting it if needed.
+// The key of the first encrypted file becomes the store key. Files
+// encrypted with other parameters are decrypted with their own key and
+// reported as not current, so they are encrypted with the store key when
+// saved.
+func (s *DirStore) open(c *Collection, data []byte) ([]byte, bool, error) {
+	if !isEncrypted(data) {
+		return data, true, nil
+	}
+
+	var container encryptedCollection
+	if err := json.Unmarshal(data, &container); err != nil {
+		return nil, false, err
+	}
+
+	h := *container.Encryption
+	h.Nonce = nil
+	if s.key != nil && s.key.sameParams(h) {
+		plaintext, err := openWithKey(container, s.key.key)
+		return plaintext, true, err
+	}
+
+	passphrase, err := c.getPassphrase()
+	if err != nil {
+		return nil, false, err
+	}
+
+	key, err := deriveKey(passphrase, &h)
+	if err != nil {
+		return nil, false, err
+	}
+
+	plaintext, err := openWithKey(container, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.passphrase = passphrase
+	if s.key == nil {
+		s.key = &dirStoreKey{header: h, key: key, passphrase: passphrase}
+		return plaintext, true, nil
+	}
+
+	return plaintext, false, nil
+}
+
+// loadVersion checks the schema version of the store. A store without a
+// version file is version 0, and stores saved by a newer version are
+// refused with ErrNewerSchema.
+func (s *DirStore) loadVersion(c *Collection) error {
+	data, err := os.ReadFile(filepath.Join(s.Dir, dirStoreVersionFile))
+	if errors.Is(err, os.ErrNotExist) {
+		data = []byte("{}")
+	} else if err != nil {
+		return err
+	}
+
+	if _, c.schemaUpgrades, err = upgradeSchema(data); err != nil {
+		return fmt.Errorf("%s: %w", dirStoreVersionFile, err)
+	}
+	s.versioned = len(c.schemaUpgrades) == 0
+
+	// the version was checked by the upgrade, and null is version 0
+	var version dirStoreVersion
+	_ = json.Unmarshal(data, &version)
+	s.version = version.Version
+
+	return nil
+}
+
+// Load reads the secret files in the directory
+func (s *DirStore) Load(c *Collection) error {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	if err := s.loadVersion(c); err != nil {
+		return err
+	}
+
+	s.key = nil
+	s.saved = map[string][]byte{}
+	for _, entry := range entries {
+		if !isDirStoreFile(entry) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		data, current, err := s.open(c, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		// secret files are upgraded as the secrets of a collection file
+		// are, and written again when saved
+		if !s.versioned {
+			if data, err = upgradeSecretSchema(data, s.version); err != nil {
+				return fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+			current = false
+		}
+
+		var secret Secret
+		if err := json.Unmarshal(data, &secret); err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		c.Secrets[secret.Name] = secret
+		if current {
+			s.saved[entry.Name()], _ = json.MarshalIndent(secret, "", "  ")
+		}
+	}
+
+	return nil
+}
+
+// setKey makes the store key match the collection passphrase. A new key,
+// with a new salt, is derived when the passphrase changed, and every file
+// is written again.
+func (s *DirStore) setKey(c *Collection) error {
+	if !c.Encrypted() {
+		if s.key != nil {
+			s.key, s.saved = nil, nil
+		}
+		return nil
+	}
+
+	if s.key != nil && s.key.passphrase == c.passphrase {
+		return nil
+	}
+
+	h, err := newEncryptionHeader()
+	if err != nil {
+		return err
+	}
+
+	key, err := deriveKey(c.passphrase, h)
+	if err != nil {
+		return err
+	}
+
+	s.key = &dirStoreKey{header: *h, key: key, passphrase: c.passphrase}
+	s.saved = nil
+
+	return nil
+}
+
+// Save writes the files of secrets that changed since the collection was
+// loaded or saved and the version file, and removes the files of secrets
+// no longer in the collection
+func (s *DirStore) Save(c *Collection) error {
+	filenames, err := dirStoreFilenames(c.Secrets)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+
+	if err := s.setKey(c); err != nil {
+		return err
+	}
+
+	if s.saved == nil {
+		s.saved = map[string][]byte{}
+	}
+
+	keep := map[string]bool{}
+	for name, secret := range c.Secrets {
+		filename := filenames[name]
+		keep[filename] = true
+
+		data, err := json.MarshalIndent(secret, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if saved, ok := s.saved[filename]; ok && bytes.Equal(saved, data) {
+			continue
+		}
+
+		sealed := data
+		if s.key != nil {
+			if sealed, err = sealWithKey(data, s.key.key, s.key.header); err != nil {
+				return err
+			}
+		}
+
+		if err := writeFileAtomic(filepath.Join(s.Dir, filename), sealed, 0600); err != nil {
+			return err
+		}
+		s.saved[filename] = data
+	}
+
+	// the version is written once every secret file is in its format, so
+	// a failed save is upgraded again
+	if !s.versioned {
+		data, err := json.MarshalIndent(dirStoreVersion{Version: SchemaVersion}, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if err := writeFileAtomic(filepath.Join(s.Dir, dirStoreVersionFile), data, 0600); err != nil {
+			return err
+		}
+		s.versioned, s.version = true, SchemaVersion
+	}
+
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if isDirStoreFile(entry) && !keep[entry.Name()] {
+			if err := os.Remove(filepath.Join(s.Dir, entry.Name())); err != nil {
+				return err
+			}
+			delete(s.saved, entry.Name())
+		}
+	}
+
+	return nil
+}
+
+// Lock locks the directory with a lock file inside it
+func (s *DirStore) Lock(timeout time.Duration) (Unlocker, error) {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return LockFile(filepath.Join(s.Dir, ".totp"), timeout)
+}
@@ -0,0 +1,267 @@
+package totp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_dirStoreFilename(t *testing.T) {
+	tests := map[string]string{
+		"name":               "name.json",
+		"Example:alice":      "Example%3Aalice.json",
+		"bob@example.com":    "bob@example.com.json",
+		"../escape":          "%2E.%2Fescape.json",
+		".hidden":            "%2Ehidden.json",
+		"with space/slash\\": "with%20space%2Fslash%5C.json",
+	}
+	for name, want := range tests {
+		if got := dirStoreFilename(name); got != want {
+			t.Errorf("dirStoreFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "secrets")
+	store := &DirStore{Dir: dir}
+
+	c, err := NewCollectionWithStore(store)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() of missing directory error = %v", err)
+	}
+
+	_, _ = c.UpdateSecret("Example:alice", "SEED")
+	_, _ = c.UpdateSecret("bob", "SEEDSEED")
+	if err := c.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+
+	// a file per secret, and deleted secrets are removed
+	_, _ = c.DeleteSecret("bob")
+	_, _ = c.UpdateSecret("carol", "SEED")
+	if err := c.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 3 || names[0] != dirStoreVersionFile || names[1] != "Example%3Aalice.json" || names[2] != "carol.json" {
+		t.Errorf("directory = %v", names)
+	}
+
+	c2, err := NewCollectionWithStore(store)
+	if err != nil || len(c2.Secrets) != 2 || c2.Secrets["Example:alice"].Value != "SEED" {
+		t.Errorf("Load() = %v, %v", c2.Secrets, err)
+	}
+
+	lock, err := store.Lock(time.Second)
+	if err != nil {
+		t.Fatal("Lock() error:", err)
+	}
+	_ = lock.Unlock()
+
+	// the lock file is not a secret
+	if c2, err := NewCollectionWithStore(store); err != nil || len(c2.Secrets) != 2 {
+		t.Errorf("Load() after Lock() = %v, %v", c2.Secrets, err)
+	}
+
+	// only changed secrets are written
+	compact := []byte(`{"Name":"carol","Value":"SEED","DateAdded":"2019-06-23T20:00:00Z","DateModified":"2019-06-23T20:00:00Z"}`)
+	_ = os.WriteFile(filepath.Join(dir, "carol.json"), compact, 0600)
+	c2, _ = NewCollectionWithStore(store)
+	_, _ = c2.UpdateSecret("Example:alice", "SEEDSEED")
+	if err := c2.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "carol.json")); string(data) != string(compact) {
+		t.Errorf("unchanged secret file written: %s", data)
+	}
+
+	// names differing only in case would share a file on some systems
+	_, _ = c2.UpdateSecret("CAROL", "SEED")
+	if err := c2.Save(); !errors.Is(err, ErrNameCollision) {
+		t.Errorf("Save() of names differing in case error = %v, want %v", err, ErrNameCollision)
+	}
+
+	// stores are versioned as collection files are
+	if len(c2.SchemaUpgrades()) != 0 {
+		t.Errorf("SchemaUpgrades() = %v", c2.SchemaUpgrades())
+	}
+
+	_ = os.Remove(filepath.Join(dir, dirStoreVersionFile))
+	if c2, err := NewCollectionWithStore(store); err != nil || len(c2.SchemaUpgrades()) != 1 {
+		t.Errorf("Load() of unversioned store = %v, %v", c2.SchemaUpgrades(), err)
+	} else if err := c2.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, dirStoreVersionFile)); !strings.Contains(string(data), `"Version": 1`) {
+		t.Errorf("version file = %s", data)
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, dirStoreVersionFile), []byte("null"), 0600)
+	if c2, err := NewCollectionWithStore(store); err != nil || len(c2.SchemaUpgrades()) != 1 {
+		t.Errorf("Load() of null version file = %v, %v", c2, err)
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, dirStoreVersionFile), []byte(`{"Version": 99}`), 0600)
+	if _, err := NewCollectionWithStore(store); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("Load() of newer store error = %v, want %v", err, ErrNewerSchema)
+	}
+	_ = os.Remove(filepath.Join(dir, dirStoreVersionFile))
+
+	// invalid secret file
+	_ = os.WriteFile(filepath.Join(dir, "invalid.json"), []byte("{"), 0600)
+	if _, err := NewCollectionWithStore(store); err == nil {
+		t.Error("Load() of invalid file did not fail")
+	}
+}
+
+func TestDirStoreSchemaUpgrade(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "secrets")
+	store := &DirStore{Dir: dir}
+
+	c, _ := NewCollectionWithStore(store)
+	_, _ = c.UpdateSecret("alice", "SEED")
+	if err := c.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+	_ = os.Remove(filepath.Join(dir, dirStoreVersionFile))
+
+	// an upgrade of the secret fields is applied to each secret file
+	savedUpgrades := schemaUpgrades
+	defer func() { schemaUpgrades = savedUpgrades }()
+	schemaUpgrades = []schemaUpgrade{{
+		description: "add notes",
+		upgrade: func(fields map[string]json.RawMessage) error {
+			// the version file has no secrets
+			raw, ok := fields["Secrets"]
+			if !ok {
+				return nil
+			}
+
+			var secrets map[string]map[string]interface{}
+			if err := json.Unmarshal(raw, &secrets); err != nil {
+				return err
+			}
+			for _, secret := range secrets {
+				secret["Notes"] = "upgraded"
+			}
+
+			data, err := json.Marshal(secrets)
+			fields["Secrets"] = data
+			return err
+		},
+	}}
+
+	c, err := NewCollectionWithStore(store)
+	if err != nil || len(c.SchemaUpgrades()) != 1 {
+		t.Fatalf("Load() of unversioned store = %v, %v", c, err)
+	}
+	if secret, _ := c.GetSecret("alice"); secret.Notes != "upgraded" {
+		t.Errorf("upgraded secret = %+v", secret)
+	}
+
+	// saving writes the upgraded secret files before the version
+	if err := c.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "alice.json")); !strings.Contains(string(data), `"upgraded"`) {
+		t.Errorf("secret file not upgraded: %s", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, dirStoreVersionFile)); !strings.Contains(string(data), `"Version": 1`) {
+		t.Errorf("version file = %s", data)
+	}
+
+	// current stores are not upgraded again
+	_ = os.WriteFile(filepath.Join(dir, "bob.json"), []byte(`{"Name": "bob", "Value": "SEED"}`), 0600)
+	if c, err := NewCollectionWithStore(store); err != nil || len(c.SchemaUpgrades()) != 0 || c.Secrets["bob"].Notes != "" {
+		t.Errorf("Load() of current store = %v, %v", c, err)
+	}
+}
+
+// fileEncryption returns the encryption parameters of an encrypted file
+func fileEncryption(t *testing.T, filename string) encryptionHeader {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var container encryptedCollection
+	if err := json.Unmarshal(data, &container); err != nil || container.Encryption == nil {
+		t.Fatalf("%s is not encrypted: %v", filename, err)
+	}
+
+	return *container.Encryption
+}
+
+func TestDirStoreEncrypted(t *testing.T) {
+	SetPassphraseFunc(func() (string, error) { return "passphrase", nil })
+	defer SetPassphraseFunc(nil)
+
+	dir := t.TempDir()
+	store := &DirStore{Dir: dir}
+
+	c := NewCollection()
+	c.SetStore(store)
+	c.SetPassphrase("passphrase")
+	_, _ = c.UpdateSecret("name", "SEED")
+	if err := c.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "name.json")); !isEncrypted(data) {
+		t.Error("secret file not encrypted")
+	}
+
+	// the files share the key parameters, so the key is derived once
+	_, _ = c.UpdateSecret("other", "SEED")
+	_ = c.Save()
+	if first, second := fileEncryption(t, filepath.Join(dir, "name.json")), fileEncryption(t, filepath.Join(dir, "other.json")); !bytes.Equal(first.Salt, second.Salt) || bytes.Equal(first.Nonce, second.Nonce) {
+		t.Errorf("secret file encryption = %+v and %+v", first, second)
+	}
+
+	// a new passphrase encrypts every file with a new salt
+	salt := fileEncryption(t, filepath.Join(dir, "name.json")).Salt
+	c.SetPassphrase("new passphrase")
+	_ = c.Save()
+	if h := fileEncryption(t, filepath.Join(dir, "name.json")); bytes.Equal(h.Salt, salt) || !bytes.Equal(h.Salt, fileEncryption(t, filepath.Join(dir, "other.json")).Salt) {
+		t.Errorf("secret file encryption after passphrase change = %+v", h)
+	}
+	c.SetPassphrase("passphrase")
+	_ = c.Save()
+
+	// a file encrypted with its own salt is loaded and moved to the store
+	// key when saved
+	data, _ := json.Marshal(Secret{Name: "own", Value: "SEED"})
+	data, _ = encrypt(data, "passphrase")
+	_ = os.WriteFile(filepath.Join(dir, "own.json"), data, 0600)
+	c, err := NewCollectionWithStore(store)
+	if err != nil || c.Secrets["own"].Value != "SEED" {
+		t.Fatalf("Load() = %v, %v", c.Secrets, err)
+	}
+	_ = c.Save()
+	if h := fileEncryption(t, filepath.Join(dir, "own.json")); !bytes.Equal(h.Salt, fileEncryption(t, filepath.Join(dir, "name.json")).Salt) {
+		t.Error("secret file not moved to the store key")
+	}
+
+	c2, err := NewCollectionWithStore(store)
+	if err != nil || !c2.Encrypted() || c2.Secrets["name"].Value != "SEED" {
+		t.Errorf("Load() = %v, %v", c2.Secrets, err)
+	}
+
+	SetPassphraseFunc(nil)
+	if _, err := NewCollectionWithStore(store); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Load() without passphrase error = %v", err)
+	}
+}
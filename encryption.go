@@ -0,0 +1,237 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+var ErrPassphraseRequired = errors.New("passphrase required")
+var ErrDecryptionFailed = errors.New("decryption failed, wrong passphrase or corrupted data")
+var ErrUnsupportedEncryption = errors.New("unsupported encryption parameters")
+
+const (
+	kdfScrypt    = "scrypt"
+	cipherAESGCM = "AES-256-GCM"
+
+	scryptN      = 32768
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltLen      = 16
+
+	// The largest scrypt parameters accepted from a collection header or
+	// an imported vault, so a crafted file cannot make deriving the key take unbounded memory
+	// or time
+	maxScryptN = 1 << 20
+	maxScryptR = 32
+	maxScryptP = 16
+)
+
+// PassphraseFunc supplies the passphrase for an encrypted collection
+type PassphraseFunc func() (string, error)
+
+var passphraseFunc PassphraseFunc
+
+// SetPassphraseFunc sets the function called when encrypted collection
+// data is loaded and the collection has no passphrase
+func SetPassphraseFunc(f PassphraseFunc) {
+	passphraseFunc = f
+}
+
+// encryptionHeader holds the parameters needed to derive the key and
+// decrypt the collection data
+type encryptionHeader struct {
+	KDF    string
+	Salt   []byte
+	N      int
+	R      int
+	P      int
+	Cipher string
+	Nonce  []byte
+}
+
+// encryptedCollection is the container written in place of the plaintext
+// collection when a passphrase is set
+type encryptedCollection struct {
+	Encryption *encryptionHeader
+	Data       []byte
+}
+
+// checkScryptParams refuses scrypt parameters read from a file that scrypt
+// cannot use or that are above the maximums. N must be a power of two
+// greater than 1, and r and p at least 1.
+func checkScryptParams(n, r, p int) error {
+	if n <= 1 || n&(n-1) != 0 || r < 1 || p < 1 {
+		return fmt.Errorf("%w: invalid scrypt parameters N=%d, r=%d, p=%d", ErrUnsupportedEncryption, n, r, p)
+	}
+
+	if n > maxScryptN || r > maxScryptR || p > maxScryptP {
+		return fmt.Errorf("%w: scrypt parameters N=%d, r=%d, p=%d exceed the maximum", ErrUnsupportedEncryption, n, r, p)
+	}
+
+	return nil
+}
+
+func deriveKey(passphrase string, h *encryptionHeader) ([]byte, error) {
+	if h.KDF != kdfScrypt || h.Cipher != cipherAESGCM {
+		return nil, ErrUnsupportedEncryption
+	}
+
+	if err := checkScryptParams(h.N, h.R, h.P); err != nil {
+		return nil, err
+	}
+
+	return scrypt.Key([]byte(passphrase), h.Salt, h.N, h.R, h.P, scryptKeyLen)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// isEncrypted reports whether data is an encrypted collection container
+func isEncrypted(data []byte) bool {
+	var container encryptedCollection
+	if err := json.Unmarshal(data, &container); err != nil {
+		return false
+	}
+
+	return container.Encryption != nil
+}
+
+// newEncryptionHeader returns the parameters of a new key, with a random
+// salt
+func newEncryptionHeader() (*encryptionHeader, error) {
+	h := &encryptionHeader{
+		KDF:    kdfScrypt,
+		Salt:   make([]byte, saltLen),
+		N:      scryptN,
+		R:      scryptR,
+		P:      scryptP,
+		Cipher: cipherAESGCM,
+	}
+
+	if _, err := rand.Read(h.Salt); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// sealWithKey seals plaintext into an encrypted container with a key
+// derived with the header parameters and a new nonce
+func sealWithKey(plaintext, key []byte, h encryptionHeader) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(h.Nonce); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(encryptedCollection{
+		Encryption: &h,
+		Data:       gcm.Seal(nil, h.Nonce, plaintext, nil),
+	}, "", "  ")
+}
+
+// openWithKey opens an encrypted container with the key
+func openWithKey(container encryptedCollection, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(container.Encryption.Nonce) != gcm.NonceSize() {
+		return nil, ErrUnsupportedEncryption
+	}
+
+	plaintext, err := gcm.Open(nil, container.Encryption.Nonce, container.Data, nil)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
+
+// encrypt seals plaintext into an encrypted collection container using a
+// key derived from the passphrase
+func encrypt(plaintext []byte, passphrase string) ([]byte, error) {
+	h, err := newEncryptionHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := deriveKey(passphrase, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return sealWithKey(plaintext, key, *h)
+}
+
+// decrypt opens an encrypted collection container with a key derived from
+// the passphrase
+func decrypt(data []byte, passphrase string) ([]byte, error) {
+	var container encryptedCollection
+	if err := json.Unmarshal(data, &container); err != nil {
+		return nil, err
+	}
+
+	if container.Encryption == nil {
+		return nil, ErrUnsupportedEncryption
+	}
+
+	key, err := deriveKey(passphrase, container.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	return openWithKey(container, key)
+}
+
+// getPassphrase returns the collection passphrase, asking the passphrase
+// function for one if it is not yet known
+func (c *Collection) getPassphrase() (string, error) {
+	if len(c.passphrase) != 0 {
+		return c.passphrase, nil
+	}
+
+	if passphraseFunc == nil {
+		return "", ErrPassphraseRequired
+	}
+
+	passphrase, err := passphraseFunc()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrPassphraseRequired, err)
+	}
+
+	if len(passphrase) == 0 {
+		return "", ErrPassphraseRequired
+	}
+
+	return passphrase, nil
+}
+
+// SetPassphrase sets the passphrase used to encrypt the collection when it
+// is saved. An empty passphrase saves the collection as plaintext.
+func (c *Collection) SetPassphrase(passphrase string) {
+	c.passphrase = passphrase
+}
+
+// Encrypted reports whether the collection will be encrypted when saved
+func (c *Collection) Encrypted() bool {
+	return len(c.passphrase) != 0
+}
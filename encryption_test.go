@@ -0,0 +1,164 @@
+package totp
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestCollection_Encryption(t *testing.T) {
+	const filename = "testencrypted.json"
+	defer os.Remove(filename)
+	defer SetPassphraseFunc(nil)
+
+	c := NewCollection()
+	c.SetFilename(filename)
+	if _, err := c.UpdateSecret("testname", "seedseed"); err != nil {
+		t.Fatal("Error adding secret:", err)
+	}
+
+	c.SetPassphrase("testpassphrase")
+	if !c.Encrypted() {
+		t.Error("Collection with passphrase not reported as encrypted")
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatal("Error saving encrypted collection:", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal("Error reading encrypted collection:", err)
+	}
+
+	if !isEncrypted(data) {
+		t.Error("Saved collection is not encrypted")
+	}
+
+	// No passphrase function
+	SetPassphraseFunc(nil)
+	if _, err := NewCollectionWithFile(filename); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("NewCollectionWithFile() error = %v, want %v", err, ErrPassphraseRequired)
+	}
+
+	// Passphrase function fails
+	SetPassphraseFunc(func() (string, error) { return "", errors.New("no terminal") })
+	if _, err := NewCollectionWithFile(filename); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("NewCollectionWithFile() error = %v, want %v", err, ErrPassphraseRequired)
+	}
+
+	// Empty passphrase
+	SetPassphraseFunc(func() (string, error) { return "", nil })
+	if _, err := NewCollectionWithFile(filename); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("NewCollectionWithFile() error = %v, want %v", err, ErrPassphraseRequired)
+	}
+
+	// Wrong passphrase
+	SetPassphraseFunc(func() (string, error) { return "wrongpassphrase", nil })
+	if _, err := NewCollectionWithFile(filename); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("NewCollectionWithFile() error = %v, want %v", err, ErrDecryptionFailed)
+	}
+
+	// Correct passphrase
+	SetPassphraseFunc(func() (string, error) { return "testpassphrase", nil })
+	c, err = NewCollectionWithFile(filename)
+	if err != nil {
+		t.Fatal("Error loading encrypted collection:", err)
+	}
+
+	if !c.Encrypted() {
+		t.Error("Loaded collection does not remember its passphrase")
+	}
+
+	if s, err := c.GetSecret("testname"); err != nil || s.Value != "SEEDSEED" {
+		t.Errorf("GetSecret() = %v, %v", s, err)
+	}
+
+	// Remove encryption
+	c.SetPassphrase("")
+	if err := c.Save(); err != nil {
+		t.Fatal("Error saving decrypted collection:", err)
+	}
+
+	data, err = os.ReadFile(filename)
+	if err != nil {
+		t.Fatal("Error reading decrypted collection:", err)
+	}
+
+	if isEncrypted(data) {
+		t.Error("Saved collection is still encrypted")
+	}
+}
+
+func Test_decrypt(t *testing.T) {
+	data, err := encrypt([]byte("plaintext"), "passphrase")
+	if err != nil {
+		t.Fatal("encrypt() error:", err)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "decrypt success",
+			data:       data,
+			passphrase: "passphrase",
+			want:       "plaintext",
+		},
+		{
+			name:       "wrong passphrase",
+			data:       data,
+			passphrase: "wrong",
+			wantErr:    true,
+		},
+		{
+			name:    "invalid data",
+			data:    []byte(`{`),
+			wantErr: true,
+		},
+		{
+			name:    "not encrypted",
+			data:    []byte(`{ "Secrets": {} }`),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported kdf",
+			data:    []byte(`{ "Encryption": { "KDF": "none", "Cipher": "AES-256-GCM" } }`),
+			wantErr: true,
+		},
+		{
+			name:    "excessive scrypt parameters",
+			data:    []byte(`{ "Encryption": { "KDF": "scrypt", "N": 1073741824, "R": 8, "P": 1, "Cipher": "AES-256-GCM" } }`),
+			wantErr: true,
+		},
+		{
+			name:    "invalid nonce",
+			data:    []byte(`{ "Encryption": { "KDF": "scrypt", "N": 1024, "R": 8, "P": 1, "Cipher": "AES-256-GCM" } }`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decrypt(tt.data, tt.passphrase)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decrypt() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("decrypt() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Parameters scrypt cannot use are refused rather than reaching it
+	for _, params := range []string{`"N": 16, "R": 0, "P": 1`, `"N": 16, "R": 1, "P": 0`, `"N": 1, "R": 8, "P": 1`, `"N": 1000, "R": 8, "P": 1`, `"N": -16, "R": 8, "P": 1`} {
+		data := []byte(`{ "Encryption": { "KDF": "scrypt", ` + params + `, "Cipher": "AES-256-GCM" }, "Data": "" }`)
+		if _, err := decrypt(data, "passphrase"); !errors.Is(err, ErrUnsupportedEncryption) {
+			t.Errorf("decrypt() with %s error = %v", params, err)
+		}
+	}
+}
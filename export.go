@@ -0,0 +1,256 @@
+package totp
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidExportFormat = errors.New("invalid export format")
+
+const (
+	// ExportURI exports one otpauth:// URI per line
+	ExportURI = "uri"
+
+	// ExportCSV exports comma separated values with a header row
+	ExportCSV = "csv"
+
+	// ExportAegis exports an unencrypted Aegis authenticator vault
+	ExportAegis = "aegis"
+
+	// ExportAndOTP exports an unencrypted andOTP backup
+	ExportAndOTP = "andotp"
+
+	// ExportJSON exports the stable plain JSON format
+	ExportJSON = "json"
+
+	// exportJSONVersion is the version of the plain JSON export format. It
+	// only changes if existing fields change meaning.
+	exportJSONVersion = 1
+)
+
+var exporters = map[string]func(io.Writer, []Secret) error{
+	ExportURI:    exportURI,
+	ExportCSV:    exportCSV,
+	ExportAegis:  exportAegis,
+	ExportAndOTP: exportAndOTP,
+	ExportJSON:   exportJSON,
+}
+
+// ExportFormats returns the names of the export formats, sorted
+func ExportFormats() []string {
+	formats := make([]string, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	return formats
+}
+
+// Export writes the secrets to writer in the given format. Every format
+// contains the secret values in plaintext.
+func Export(writer io.Writer, format string, secrets []Secret) error {
+	exporter, ok := exporters[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("%w: %s, must be one of %s", ErrInvalidExportFormat, format, strings.Join(ExportFormats(), ", "))
+	}
+
+	return exporter(writer, secrets)
+}
+
+func writeJSON(writer io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+func exportURI(writer io.Writer, secrets []Secret) error {
+	for _, s := range secrets {
+		if _, err := fmt.Fprintln(writer, s.URI()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func exportCSV(writer io.Writer, secrets []Secret) error {
+	w := csv.NewWriter(writer)
+	_ = w.Write([]string{"name", "secret", "type", "algorithm", "digits", "period", "counter", "date_added", "date_modified", "issuer", "account", "notes", "tags"})
+
+	for _, s := range secrets {
+		opts := s.Options()
+		_ = w.Write([]string{
+			s.Name,
+			strings.ToUpper(s.Value),
+			opts.GetType(),
+			strings.ToUpper(opts.GetAlgorithm()),
+			strconv.Itoa(opts.GetDigits()),
+			strconv.FormatUint(uint64(opts.GetPeriod()), 10),
+			strconv.FormatUint(s.Counter, 10),
+			s.DateAdded.Format(time.RFC3339),
+			s.DateModified.Format(time.RFC3339),
+			s.GetIssuer(),
+			s.GetAccount(),
+			s.Notes,
+			strings.Join(s.Tags, ";"),
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func exportAegis(writer io.Writer, secrets []Secret) error {
+	db := aegisDB{Version: 2, Entries: []aegisEntry{}}
+
+	for _, s := range secrets {
+		uuid, err := newUUID()
+		if err != nil {
+			return err
+		}
+
+		opts := s.Options()
+		entry := aegisEntry{
+			Type:   opts.GetType(),
+			UUID:   uuid,
+			Name:   s.GetAccount(),
+			Issuer: s.GetIssuer(),
+			Note:   s.Notes,
+			Info: aegisInfo{
+				Secret: strings.ToUpper(s.Value),
+				Algo:   strings.ToUpper(opts.GetAlgorithm()),
+				Digits: opts.GetDigits(),
+			},
+		}
+
+		if s.IsHOTP() {
+			entry.Info.Counter = s.Counter
+		} else {
+			entry.Info.Period = opts.GetPeriod()
+		}
+
+		db.Entries = append(db.Entries, entry)
+	}
+
+	data, err := json.Marshal(db)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(writer, aegisVault{Version: 1, DB: data})
+}
+
+// andOTPEntry is an entry of an unencrypted andOTP backup
+type andOTPEntry struct {
+	Secret    string   `json:"secret"`
+	Issuer    string   `json:"issuer"`
+	Label     string   `json:"label"`
+	Digits    int      `json:"digits"`
+	Type      string   `json:"type"`
+	Algorithm string   `json:"algorithm"`
+	Thumbnail string   `json:"thumbnail"`
+	Period    uint     `json:"period,omitempty"`
+	Counter   *uint64  `json:"counter,omitempty"`
+	Tags      []string `json:"tags"`
+}
+
+func exportAndOTP(writer io.Writer, secrets []Secret) error {
+	entries := []andOTPEntry{}
+
+	for _, s := range secrets {
+		opts := s.Options()
+		entry := andOTPEntry{
+			Secret:    strings.ToUpper(s.Value),
+			Issuer:    s.GetIssuer(),
+			Label:     s.GetAccount(),
+			Digits:    opts.GetDigits(),
+			Type:      strings.ToUpper(opts.GetType()),
+			Algorithm: strings.ToUpper(opts.GetAlgorithm()),
+			Thumbnail: "Default",
+			Tags:      append([]string{}, s.Tags...),
+		}
+
+		if s.IsHOTP() {
+			counter := s.Counter
+			entry.Counter = &counter
+		} else {
+			entry.Period = opts.GetPeriod()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return writeJSON(writer, entries)
+}
+
+// exportSecret is a secret in the plain JSON export format. Options are
+// always written with their effective values so the output does not
+// depend on the defaults of the version that wrote it.
+type exportSecret struct {
+	Name         string    `json:"name"`
+	Secret       string    `json:"secret"`
+	Type         string    `json:"type"`
+	Algorithm    string    `json:"algorithm"`
+	Digits       int       `json:"digits"`
+	Period       uint      `json:"period"`
+	Counter      uint64    `json:"counter"`
+	DateAdded    time.Time `json:"date_added"`
+	DateModified time.Time `json:"date_modified"`
+	Issuer       string    `json:"issuer"`
+	Account      string    `json:"account"`
+	Notes        string    `json:"notes"`
+	Tags         []string  `json:"tags"`
+}
+
+type exportDocument struct {
+	Version int            `json:"version"`
+	Secrets []exportSecret `json:"secrets"`
+}
+
+func exportJSON(writer io.Writer, secrets []Secret) error {
+	doc := exportDocument{Version: exportJSONVersion, Secrets: []exportSecret{}}
+
+	for _, s := range secrets {
+		opts := s.Options()
+		doc.Secrets = append(doc.Secrets, exportSecret{
+			Name:         s.Name,
+			Secret:       strings.ToUpper(s.Value),
+			Type:         opts.GetType(),
+			Algorithm:    strings.ToUpper(opts.GetAlgorithm()),
+			Digits:       opts.GetDigits(),
+			Period:       opts.GetPeriod(),
+			Counter:      s.Counter,
+			DateAdded:    s.DateAdded,
+			DateModified: s.DateModified,
+			Issuer:       s.GetIssuer(),
+			Account:      s.GetAccount(),
+			Notes:        s.Notes,
+			Tags:         append([]string{}, s.Tags...),
+		})
+	}
+
+	return writeJSON(writer, doc)
+}
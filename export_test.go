@@ -0,0 +1,145 @@
+package totp
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testExportSecrets = []Secret{
+	{Name: "Example:alice@example.com", Value: "jbswy3dpehpk3pxp", Algorithm: "SHA256", Digits: 8, Period: 60},
+	{Name: "counter", Value: "JBSWY3DPEHPK3PXP", Type: TypeHOTP, Counter: 5},
+	{Name: "ACME Co:john doe", Value: "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ"},
+}
+
+// wantImported is testExportSecrets as they are read back by the importers
+var wantImported = []Secret{
+	{Name: "Example:alice@example.com", Value: "JBSWY3DPEHPK3PXP", Type: TypeTOTP, Algorithm: "SHA256", Digits: 8, Period: 60, Issuer: "Example", Account: "alice@example.com"},
+	{Name: "counter", Value: "JBSWY3DPEHPK3PXP", Type: TypeHOTP, Counter: 5, Account: "counter"},
+	{Name: "ACME Co:john doe", Value: "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ", Type: TypeTOTP, Issuer: "ACME Co", Account: "john doe"},
+}
+
+func TestSecretURI(t *testing.T) {
+	for i, s := range testExportSecrets {
+		got, err := ParseURI(s.URI())
+		if err != nil {
+			t.Fatalf("ParseURI(%s) error = %v", s.URI(), err)
+		}
+
+		want := wantImported[i]
+		want.Algorithm = s.Algorithm
+		want.Digits = s.Digits
+		want.Period = s.Period
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseURI(%s) = %v, want %v", s.URI(), got, want)
+		}
+	}
+
+	if uri := testExportSecrets[1].URI(); uri != "otpauth://hotp/counter?counter=5&secret=JBSWY3DPEHPK3PXP" {
+		t.Errorf("URI() = %s", uri)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var buf bytes.Buffer
+
+	// Aegis round trip
+	if err := Export(&buf, ExportAegis, testExportSecrets); err != nil {
+		t.Fatal("Export() aegis error:", err)
+	}
+	secrets, skipped, err := ParseAegis(buf.Bytes(), nil)
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("ParseAegis() = %v, %v", skipped, err)
+	}
+	for i := range secrets {
+		if secrets[i].Name != wantImported[i].Name || secrets[i].Value != wantImported[i].Value ||
+			secrets[i].Options().GetPeriod() != wantImported[i].Options().GetPeriod() || secrets[i].Counter != wantImported[i].Counter {
+			t.Errorf("Aegis export = %v, want %v", secrets[i], wantImported[i])
+		}
+	}
+
+	// URI list
+	buf.Reset()
+	if err := Export(&buf, "URI", testExportSecrets); err != nil {
+		t.Fatal("Export() uri error:", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != len(testExportSecrets) {
+		t.Errorf("Export() uri = %v", lines)
+	}
+
+	// CSV
+	buf.Reset()
+	if err := Export(&buf, ExportCSV, testExportSecrets); err != nil {
+		t.Fatal("Export() csv error:", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(records) != len(testExportSecrets)+1 {
+		t.Fatalf("Export() csv = %v, %v", records, err)
+	}
+	if want := []string{"counter", "JBSWY3DPEHPK3PXP", "hotp", "SHA1", "6", "30", "5"}; !reflect.DeepEqual(records[2][:7], want) {
+		t.Errorf("Export() csv record = %v, want %v", records[2], want)
+	}
+
+	// andOTP
+	buf.Reset()
+	if err := Export(&buf, ExportAndOTP, testExportSecrets); err != nil {
+		t.Fatal("Export() andotp error:", err)
+	}
+	var entries []andOTPEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil || len(entries) != len(testExportSecrets) {
+		t.Fatalf("Export() andotp = %v, %v", entries, err)
+	}
+	if e := entries[0]; e.Issuer != "Example" || e.Label != "alice@example.com" || e.Type != "TOTP" || e.Period != 60 {
+		t.Errorf("Export() andotp entry = %v", e)
+	}
+	if e := entries[1]; e.Type != "HOTP" || e.Counter == nil || *e.Counter != 5 {
+		t.Errorf("Export() andotp entry = %v", e)
+	}
+
+	// Plain JSON
+	buf.Reset()
+	added := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := Export(&buf, ExportJSON, []Secret{{Name: "name", Value: "SEED", DateAdded: added, DateModified: added}}); err != nil {
+		t.Fatal("Export() json error:", err)
+	}
+	const wantJSON = `{
+  "version": 1,
+  "secrets": [
+    {
+      "name": "name",
+      "secret": "SEED",
+      "type": "totp",
+      "algorithm": "SHA1",
+      "digits": 6,
+      "period": 30,
+      "counter": 0,
+      "date_added": "2020-01-02T03:04:05Z",
+      "date_modified": "2020-01-02T03:04:05Z",
+      "issuer": "",
+      "account": "name",
+      "notes": "",
+      "tags": []
+    }
+  ]
+}
+`
+	if buf.String() != wantJSON {
+		t.Errorf("Export() json = %s, want %s", buf.String(), wantJSON)
+	}
+
+	// Empty collection
+	buf.Reset()
+	if err := Export(&buf, ExportJSON, nil); err != nil || !strings.Contains(buf.String(), `"secrets": []`) {
+		t.Errorf("Export() empty json = %s, %v", buf.String(), err)
+	}
+
+	// Invalid format
+	if err := Export(&buf, "xml", testExportSecrets); !errors.Is(err, ErrInvalidExportFormat) {
+		t.Errorf("Export() error = %v, want %v", err, ErrInvalidExportFormat)
+	}
+}
@@ -0,0 +1,170 @@
+package totp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLockTimeout is returned when a collection file lock cannot be acquired
+// within the timeout
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+// ErrLockUnavailable is returned when a collection file lock cannot be
+// created because the directory is read-only
+var ErrLockUnavailable = errors.New("lock file cannot be created")
+
+// lockRetryInterval is how often a held lock is retried
+const lockRetryInterval = 50 * time.Millisecond
+
+// resolveFilename returns the file that filename links to, so a symlinked
+// collection is replaced at its target instead of the link being replaced
+// with a regular file. Names that cannot be resolved, such as files not
+// created yet, are returned unchanged.
+func resolveFilename(filename string) string {
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		return target
+	}
+
+	return filename
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as
+// filename, syncs it, and renames it over filename so readers and crashes
+// never see a partially written file. A symlink is written through to its
+// target.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) (err error) {
+	filename = resolveFilename(filename)
+	dir := filepath.Dir(filename)
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+
+	if err = f.Sync(); err != nil {
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(f.Name(), filename); err != nil {
+		return err
+	}
+
+	syncDir(dir)
+
+	return nil
+}
+
+// syncDir syncs a directory so a rename into it is durable. Not every
+// platform supports this, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	_ = d.Sync()
+	d.Close()
+}
+
+// FileLock is an advisory lock on a collection file held across loading,
+// modifying, and saving it
+type FileLock struct {
+	f    *os.File
+	name string
+}
+
+// LockFile acquires an exclusive advisory lock for the collection file
+// filename, waiting up to timeout for another process to release it. The
+// lock is held on a separate "filename.lock" file because saving replaces
+// the collection file, and the lock file is removed when unlocked.
+func LockFile(filename string, timeout time.Duration) (*FileLock, error) {
+	lockName := resolveFilename(filename) + ".lock"
+
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(lockName, os.O_RDWR|os.O_CREATE, 0600)
+		if errors.Is(err, fs.ErrPermission) || isReadOnlyFS(err) {
+			return nil, fmt.Errorf("%w: %s", ErrLockUnavailable, err)
+		} else if err != nil {
+			return nil, err
+		}
+
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		// the lock file may have been removed by the process that held
+		// it, in which case the lock is on a file no one else can see and
+		// a new lock file is opened
+		if locked && isOpenFile(f, lockName) {
+			return &FileLock{f: f, name: lockName}, nil
+		}
+
+		if locked {
+			_ = unlock(f)
+		}
+		f.Close()
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s: %w after %s", lockName, ErrLockTimeout, timeout)
+		}
+
+		if !locked {
+			time.Sleep(lockRetryInterval)
+		}
+	}
+}
+
+// isOpenFile reports whether the open file f is still the file name
+func isOpenFile(f *os.File, name string) bool {
+	openInfo, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	nameInfo, err := os.Stat(name)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(openInfo, nameInfo)
+}
+
+// Unlock removes the lock file and releases the lock. The file is removed
+// while the lock is held so another process cannot lock it in between.
+// Platforms that cannot remove open files leave it in place.
+func (l *FileLock) Unlock() error {
+	_ = os.Remove(l.name)
+
+	if err := unlock(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+
+	return l.f.Close()
+}
@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package totp
+
+import "os"
+
+// tryLock always succeeds on platforms without file locking
+func tryLock(*os.File) (bool, error) {
+	return true, nil
+}
+
+func unlock(*os.File) error {
+	return nil
+}
+
+// isReadOnlyFS is always false on platforms without file locking
+func isReadOnlyFS(error) bool {
+	return false
+}
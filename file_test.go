@@ -0,0 +1,119 @@
+package totp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func Test_writeFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "collection.json")
+
+	if err := os.WriteFile(filename, []byte("old data that is longer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(filename, []byte("new"), 0600); err != nil {
+		t.Fatal("writeFileAtomic() error:", err)
+	}
+
+	if data, _ := os.ReadFile(filename); string(data) != "new" {
+		t.Errorf("writeFileAtomic() wrote %q", data)
+	}
+
+	if info, _ := os.Stat(filename); runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("writeFileAtomic() mode = %v", info.Mode())
+	}
+
+	// no temporary files are left behind
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("writeFileAtomic() left %d files", len(entries))
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "nosuchdir", "collection.json"), []byte("new"), 0600); err == nil {
+		t.Error("writeFileAtomic() to missing directory did not fail")
+	}
+
+	// a symlink is written through to its target and is kept
+	link := filepath.Join(dir, "link.json")
+	if err := os.Symlink(filename, link); err != nil {
+		t.Skip("symlinks are not available:", err)
+	}
+
+	if err := writeFileAtomic(link, []byte("linked"), 0600); err != nil {
+		t.Fatal("writeFileAtomic() error:", err)
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("writeFileAtomic() replaced the symlink: %v, %v", info, err)
+	}
+
+	if data, _ := os.ReadFile(filename); string(data) != "linked" {
+		t.Errorf("writeFileAtomic() wrote %q to the symlink target", data)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "collection.json")
+
+	lock, err := LockFile(filename, time.Second)
+	if err != nil {
+		t.Fatal("LockFile() error:", err)
+	}
+
+	if runtime.GOOS != "plan9" && runtime.GOOS != "js" {
+		if _, err := LockFile(filename, 100*time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+			t.Errorf("LockFile() of held lock error = %v, want %v", err, ErrLockTimeout)
+		}
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Error("Unlock() error:", err)
+	}
+
+	if _, err := os.Stat(filename + ".lock"); runtime.GOOS != "windows" && !errors.Is(err, os.ErrNotExist) {
+		t.Error("Unlock() left the lock file:", err)
+	}
+
+	lock, err = LockFile(filename, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal("LockFile() after unlock error:", err)
+	}
+	_ = lock.Unlock()
+
+	if _, err := LockFile(filepath.Join(filename, "nosuchdir", "collection.json"), time.Second); err == nil {
+		t.Error("LockFile() in missing directory did not fail")
+	}
+
+	// the permissions of a read-only directory do not apply to root
+	if runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		dir := filepath.Join(t.TempDir(), "readonly")
+		_ = os.Mkdir(dir, 0500)
+		if _, err := LockFile(filepath.Join(dir, "collection.json"), time.Second); !errors.Is(err, ErrLockUnavailable) {
+			t.Errorf("LockFile() in read-only directory error = %v, want %v", err, ErrLockUnavailable)
+		}
+	}
+}
+
+func TestCollection_SaveAtomic(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "collection.json")
+
+	c := NewCollection()
+	c.SetFilename(filename)
+	_, _ = c.UpdateSecret("name", "SEED")
+	if err := c.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+
+	c2, err := NewCollectionWithFile(filename)
+	if err != nil {
+		t.Fatal("NewCollectionWithFile() error:", err)
+	}
+	if _, err := c2.GetSecret("name"); err != nil {
+		t.Error("GetSecret() after Save() error:", err)
+	}
+}
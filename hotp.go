@@ -0,0 +1,108 @@
+package totp
+
+import (
+	"errors"
+	"math"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+var ErrNotHOTP = errors.New("secret is not an HOTP secret")
+var ErrResyncFailed = errors.New("codes not found within the resync window")
+var ErrResyncNoCodes = errors.New("at least one code is required to resync")
+
+// DefaultResyncWindow is the number of counter values searched ahead of the
+// current counter when resynchronizing
+const DefaultResyncWindow = 100
+
+// generateCodeWithCounter creates an HOTP code for the given counter using
+// the secret's value and options
+func (s Secret) generateCodeWithCounter(counter uint64) (string, error) {
+	opts, err := s.Options().normalize()
+	if err != nil {
+		return "", err
+	}
+
+	return hotp.GenerateCodeCustom(s.Value, counter, hotp.ValidateOpts{
+		Digits:    otp.Digits(opts.GetDigits()),
+		Algorithm: algorithms[opts.GetAlgorithm()],
+	})
+}
+
+// getHOTPSecret returns the named secret if it is an HOTP secret
+func (c *Collection) getHOTPSecret(name string) (Secret, error) {
+	secret, err := c.GetSecret(name)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	if !secret.IsHOTP() {
+		return Secret{}, ErrNotHOTP
+	}
+
+	return secret, nil
+}
+
+// SetCounter sets the counter of the named HOTP secret
+func (c *Collection) SetCounter(name string, counter uint64) (Secret, error) {
+	secret, err := c.getHOTPSecret(name)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	secret.Counter = counter
+	secret.DateModified = c.now()
+	c.Secrets[name] = secret
+
+	return secret, nil
+}
+
+// ResyncCounter searches up to window counter values ahead of the named
+// HOTP secret's counter for the consecutive codes given. When found, the
+// counter is set to follow the last code.
+func (c *Collection) ResyncCounter(name string, codes []string, window uint64) (Secret, error) {
+	if len(codes) == 0 {
+		return Secret{}, ErrResyncNoCodes
+	}
+
+	secret, err := c.getHOTPSecret(name)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	// the window is clamped so counters near the maximum do not wrap
+	// around, and ends before the counter following the codes would
+	end := secret.Counter + window
+	if end < secret.Counter {
+		end = math.MaxUint64
+	}
+	if last := math.MaxUint64 - uint64(len(codes)); end > last {
+		end = last
+	}
+
+	for counter := secret.Counter; counter <= end; counter++ {
+		match := true
+		for i, code := range codes {
+			generated, err := secret.generateCodeWithCounter(counter + uint64(i))
+			if err != nil {
+				return Secret{}, err
+			}
+
+			if generated != code {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return c.SetCounter(name, counter+uint64(len(codes)))
+		}
+
+		if counter == end {
+			break
+		}
+	}
+
+	return Secret{}, ErrResyncFailed
+}
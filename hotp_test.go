@@ -0,0 +1,151 @@
+package totp
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// RFC 4226 test secret "12345678901234567890"
+const rfc4226Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func newHOTPTestCollection(t *testing.T) *Collection {
+	t.Helper()
+
+	c := NewCollection()
+	if _, err := c.UpdateSecretWithOptions("hotpname", rfc4226Secret, SecretOptions{Type: "HOTP"}); err != nil {
+		t.Fatal("Error adding HOTP secret:", err)
+	}
+
+	if _, err := c.UpdateSecret("totpname", "seed"); err != nil {
+		t.Fatal("Error adding TOTP secret:", err)
+	}
+
+	return c
+}
+
+func TestCollection_GenerateCodeHOTP(t *testing.T) {
+	c := newHOTPTestCollection(t)
+
+	for _, want := range []string{"755224", "287082", "359152", "969429"} {
+		got, err := c.GenerateCode("hotpname")
+		if err != nil || got != want {
+			t.Errorf("Collection.GenerateCode() = %v, %v, want %v", got, err, want)
+		}
+	}
+
+	if s, _ := c.GetSecret("hotpname"); s.Counter != 4 {
+		t.Errorf("Counter = %d, want 4", s.Counter)
+	}
+
+	// The counter is not incremented for TOTP secrets
+	if _, err := c.GenerateCodeWithTime("totpname", time.Now()); err != nil {
+		t.Error("Collection.GenerateCodeWithTime() error:", err)
+	}
+
+	if s, _ := c.GetSecret("totpname"); s.Counter != 0 {
+		t.Errorf("TOTP counter = %d, want 0", s.Counter)
+	}
+}
+
+func TestCollection_SetCounter(t *testing.T) {
+	c := newHOTPTestCollection(t)
+
+	s, err := c.SetCounter("hotpname", 3)
+	if err != nil || s.Counter != 3 {
+		t.Errorf("Collection.SetCounter() = %v, %v", s, err)
+	}
+
+	if code, _ := c.GenerateCode("hotpname"); code != "969429" {
+		t.Errorf("Collection.GenerateCode() after SetCounter() = %v", code)
+	}
+
+	if _, err := c.SetCounter("totpname", 3); !errors.Is(err, ErrNotHOTP) {
+		t.Errorf("Collection.SetCounter() error = %v, want %v", err, ErrNotHOTP)
+	}
+
+	if _, err := c.SetCounter("invalidname", 3); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Collection.SetCounter() error = %v, want %v", err, ErrSecretNotFound)
+	}
+}
+
+func TestCollection_ResyncCounter(t *testing.T) {
+	tests := []struct {
+		name        string
+		secretName  string
+		codes       []string
+		window      uint64
+		wantCounter uint64
+		wantErr     error
+	}{
+		{
+			name:        "resync with consecutive codes",
+			secretName:  "hotpname",
+			codes:       []string{"359152", "969429"},
+			window:      DefaultResyncWindow,
+			wantCounter: 4,
+		},
+		{
+			name:        "resync with one code",
+			secretName:  "hotpname",
+			codes:       []string{"287082"},
+			window:      DefaultResyncWindow,
+			wantCounter: 2,
+		},
+		{
+			name:       "codes outside window",
+			secretName: "hotpname",
+			codes:      []string{"969429"},
+			window:     2,
+			wantErr:    ErrResyncFailed,
+		},
+		{
+			name:       "codes not consecutive",
+			secretName: "hotpname",
+			codes:      []string{"287082", "969429"},
+			window:     DefaultResyncWindow,
+			wantErr:    ErrResyncFailed,
+		},
+		{
+			name:       "no codes",
+			secretName: "hotpname",
+			window:     DefaultResyncWindow,
+			wantErr:    ErrResyncNoCodes,
+		},
+		{
+			name:       "not HOTP",
+			secretName: "totpname",
+			codes:      []string{"287082"},
+			window:     DefaultResyncWindow,
+			wantErr:    ErrNotHOTP,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newHOTPTestCollection(t)
+			got, err := c.ResyncCounter(tt.secretName, tt.codes, tt.window)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Collection.ResyncCounter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.Counter != tt.wantCounter {
+				t.Errorf("Collection.ResyncCounter() counter = %d, want %d", got.Counter, tt.wantCounter)
+			}
+		})
+	}
+
+	// Windows past the maximum counter are clamped instead of wrapping
+	c := newHOTPTestCollection(t)
+	secret, _ := c.SetCounter("hotpname", math.MaxUint64-3)
+	code, _ := secret.generateCodeWithCounter(math.MaxUint64 - 2)
+	if got, err := c.ResyncCounter("hotpname", []string{code}, math.MaxUint64); err != nil || got.Counter != math.MaxUint64-1 {
+		t.Errorf("Collection.ResyncCounter() near the maximum = %d, %v", got.Counter, err)
+	}
+
+	// The last counter cannot be resynced to, as no counter follows it
+	last, _ := secret.generateCodeWithCounter(math.MaxUint64)
+	if _, err := c.ResyncCounter("hotpname", []string{last}, math.MaxUint64); !errors.Is(err, ErrResyncFailed) {
+		t.Errorf("Collection.ResyncCounter() with the last counter error = %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package totp
+
+import (
+	"sort"
+	"strings"
+)
+
+// SecretMetadata holds the descriptive fields of a secret. They do not
+// affect generated codes.
+type SecretMetadata struct {
+	// Issuer is the provider or service the secret belongs to
+	Issuer string
+
+	// Account is the user name or email address of the account
+	Account string
+
+	// Notes is free-form text about the secret
+	Notes string
+
+	// Tags are the names of the groups the secret belongs to
+	Tags []string
+}
+
+// Metadata returns the descriptive fields of the secret
+func (s Secret) Metadata() SecretMetadata {
+	return SecretMetadata{
+		Issuer:  s.Issuer,
+		Account: s.Account,
+		Notes:   s.Notes,
+		Tags:    s.Tags,
+	}
+}
+
+// GetIssuer returns the issuer or, if none is set, the issuer part of an
+// "issuer:account" name
+func (s Secret) GetIssuer() string {
+	if len(s.Issuer) != 0 {
+		return s.Issuer
+	}
+
+	issuer, _ := splitName(s.Name)
+	return issuer
+}
+
+// GetAccount returns the account or, if none is set, the account part of
+// an "issuer:account" name
+func (s Secret) GetAccount() string {
+	if len(s.Account) != 0 {
+		return s.Account
+	}
+
+	_, account := splitName(s.Name)
+	return account
+}
+
+// label returns the "issuer:account" label used by authenticator apps
+func (s Secret) label() string {
+	if issuer := s.GetIssuer(); len(issuer) != 0 {
+		return issuer + ":" + s.GetAccount()
+	}
+
+	return s.GetAccount()
+}
+
+// HasTag reports whether the secret has the tag
+func (s Secret) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasTags reports whether the secret has all of the tags
+func (s Secret) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !s.HasTag(tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetTags returns the tags used by the secrets in the collection, sorted
+func (c *Collection) GetTags() []string {
+	var tags []string
+	for _, secret := range c.Secrets {
+		tags = append(tags, secret.Tags...)
+	}
+
+	return normalizeTags(tags)
+}
+
+// normalizeTags returns the tags trimmed, without empty or duplicate
+// tags, and sorted
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if len(tag) == 0 || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	sort.Strings(normalized)
+
+	return normalized
+}
+
+// setMetadata sets the descriptive fields of the secret
+func (s *Secret) setMetadata(meta SecretMetadata) {
+	s.Issuer = strings.TrimSpace(meta.Issuer)
+	s.Account = strings.TrimSpace(meta.Account)
+	s.Notes = meta.Notes
+	s.Tags = normalizeTags(meta.Tags)
+}
+
+// SetMetadata sets the descriptive fields of the named secret
+func (c *Collection) SetMetadata(name string, meta SecretMetadata) (Secret, error) {
+	secret, err := c.GetSecret(name)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	secret.setMetadata(meta)
+	secret.DateModified = c.now()
+	c.Secrets[name] = secret
+
+	return secret, nil
+}
@@ -0,0 +1,103 @@
+package totp
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSecret_GetIssuerAccount(t *testing.T) {
+	tests := []struct {
+		secret      Secret
+		wantIssuer  string
+		wantAccount string
+		wantLabel   string
+	}{
+		{Secret{Name: "name"}, "", "name", "name"},
+		{Secret{Name: "Example:alice"}, "Example", "alice", "Example:alice"},
+		{Secret{Name: "work", Issuer: "Example", Account: "bob"}, "Example", "bob", "Example:bob"},
+		{Secret{Name: "Example:alice", Account: "carol"}, "Example", "carol", "Example:carol"},
+	}
+	for _, tt := range tests {
+		if got := tt.secret.GetIssuer(); got != tt.wantIssuer {
+			t.Errorf("GetIssuer() = %v, want %v", got, tt.wantIssuer)
+		}
+		if got := tt.secret.GetAccount(); got != tt.wantAccount {
+			t.Errorf("GetAccount() = %v, want %v", got, tt.wantAccount)
+		}
+		if got := tt.secret.label(); got != tt.wantLabel {
+			t.Errorf("label() = %v, want %v", got, tt.wantLabel)
+		}
+	}
+}
+
+func Test_normalizeTags(t *testing.T) {
+	got := normalizeTags([]string{" prod", "acme", "", "prod", "  "})
+	if want := []string{"acme", "prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeTags() = %v, want %v", got, want)
+	}
+
+	if got := normalizeTags(nil); got != nil {
+		t.Errorf("normalizeTags() = %v, want nil", got)
+	}
+}
+
+func TestCollection_SetMetadata(t *testing.T) {
+	c := NewCollection()
+	_, _ = c.UpdateSecret("name", "SEED")
+
+	secret, err := c.SetMetadata("name", SecretMetadata{Issuer: " Example ", Account: "alice", Notes: "recovery codes in the safe", Tags: []string{"prod", "acme"}})
+	if err != nil {
+		t.Fatal("SetMetadata() error:", err)
+	}
+
+	want := SecretMetadata{Issuer: "Example", Account: "alice", Notes: "recovery codes in the safe", Tags: []string{"acme", "prod"}}
+	if !reflect.DeepEqual(secret.Metadata(), want) {
+		t.Errorf("SetMetadata() = %v, want %v", secret.Metadata(), want)
+	}
+	if !secret.HasTag("acme") || secret.HasTag("dev") {
+		t.Errorf("HasTag() tags = %v", secret.Tags)
+	}
+	if !secret.HasTags([]string{"prod", "acme"}) || secret.HasTags([]string{"acme", "dev"}) || !secret.HasTags(nil) {
+		t.Errorf("HasTags() tags = %v", secret.Tags)
+	}
+
+	_, _ = c.UpdateSecret("other", "SEED")
+	_, _ = c.SetMetadata("other", SecretMetadata{Tags: []string{"dev", "acme"}})
+	if got := c.GetTags(); !reflect.DeepEqual(got, []string{"acme", "dev", "prod"}) {
+		t.Errorf("GetTags() = %v", got)
+	}
+
+	// Metadata is kept when the value is updated and survives a round trip
+	_, _ = c.UpdateSecret("name", "SEEDSEED")
+	data, _ := c.Serialize()
+	c2 := NewCollection()
+	if err := json.Unmarshal(data, c2); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c2.GetSecret("name"); !reflect.DeepEqual(got.Metadata(), want) {
+		t.Errorf("round trip = %v, want %v", got.Metadata(), want)
+	}
+
+	if _, err := c.SetMetadata("nosuchname", want); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("SetMetadata() error = %v, want %v", err, ErrSecretNotFound)
+	}
+
+	// Older files without metadata still load
+	if err := json.Unmarshal([]byte(`{"Secrets": {"old": {"Name": "old", "Value": "SEED"}}}`), c2); err != nil {
+		t.Error("Unmarshal() of older file error:", err)
+	}
+}
+
+func TestCollection_ImportSecretMetadata(t *testing.T) {
+	c := NewCollection()
+	secret, err := c.ImportSecret(Secret{Name: "name", Value: "SEED", Issuer: "Example", Tags: []string{"b", "a", "b"}}, false)
+	if err != nil {
+		t.Fatal("ImportSecret() error:", err)
+	}
+
+	if secret.Issuer != "Example" || !reflect.DeepEqual(secret.Tags, []string{"a", "b"}) {
+		t.Errorf("ImportSecret() = %v", secret)
+	}
+}
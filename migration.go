@@ -0,0 +1,372 @@
+package totp
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var ErrInvalidMigration = errors.New("invalid otpauth-migration URI")
+var ErrMigrationUnsupported = errors.New("secret cannot be exported for migration")
+var ErrMigrationIncomplete = errors.New("migration batch incomplete")
+
+const (
+	migrationScheme  = "otpauth-migration"
+	migrationVersion = 1
+
+	// DefaultMigrationBatchSize is the number of secrets in each
+	// otpauth-migration URI, which keeps the QR codes scannable
+	DefaultMigrationBatchSize = 10
+)
+
+// Protocol buffer field numbers of the Google Authenticator
+// MigrationPayload message and its OtpParameters message
+const (
+	payloadOtpParameters protowire.Number = 1
+	payloadVersion       protowire.Number = 2
+	payloadBatchSize     protowire.Number = 3
+	payloadBatchIndex    protowire.Number = 4
+	payloadBatchID       protowire.Number = 5
+
+	otpSecret    protowire.Number = 1
+	otpName      protowire.Number = 2
+	otpIssuer    protowire.Number = 3
+	otpAlgorithm protowire.Number = 4
+	otpDigits    protowire.Number = 5
+	otpType      protowire.Number = 6
+	otpCounter   protowire.Number = 7
+)
+
+var migrationAlgorithms = []string{"", "SHA1", "SHA256", "SHA512", "MD5"}
+var migrationDigits = []int{0, 6, 8}
+var migrationTypes = []string{"", TypeHOTP, TypeTOTP}
+
+// MigrationPayload is the content of one otpauth-migration URI. Large
+// exports are split into a batch of payloads sharing a BatchID.
+type MigrationPayload struct {
+	// Secrets are the secrets in this payload
+	Secrets []Secret
+
+	// Version is the payload format version
+	Version int
+
+	// BatchSize is the number of payloads in the batch
+	BatchSize int
+
+	// BatchIndex is the position of this payload in the batch
+	BatchIndex int
+
+	// BatchID identifies the batch this payload belongs to
+	BatchID int
+}
+
+func migrationIndex(values []string, value string) uint64 {
+	for i, v := range values {
+		if v == value {
+			return uint64(i)
+		}
+	}
+
+	return 0
+}
+
+func migrationDigitsIndex(digits int) uint64 {
+	for i, d := range migrationDigits {
+		if d == digits {
+			return uint64(i)
+		}
+	}
+
+	return 0
+}
+
+func decodeSecretBytes(value string) ([]byte, error) {
+	value = strings.TrimRight(strings.ToUpper(value), "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(value)
+}
+
+// consumeFields calls field for each field in b, which is responsible for
+// consuming the field value and returning its length
+func consumeFields(b []byte, field func(protowire.Number, protowire.Type, []byte) int) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n = field(num, typ, b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+
+	return nil
+}
+
+func consumeVarint(b []byte, v *uint64) int {
+	var n int
+	*v, n = protowire.ConsumeVarint(b)
+	return n
+}
+
+func consumeBytes(b []byte, v *[]byte) int {
+	var n int
+	*v, n = protowire.ConsumeBytes(b)
+	return n
+}
+
+func decodeOtpParameters(b []byte) (Secret, error) {
+	var (
+		secretBytes, name, issuer           []byte
+		algorithm, digits, otpKind, counter uint64
+	)
+
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch {
+		case num == otpSecret && typ == protowire.BytesType:
+			return consumeBytes(b, &secretBytes)
+		case num == otpName && typ == protowire.BytesType:
+			return consumeBytes(b, &name)
+		case num == otpIssuer && typ == protowire.BytesType:
+			return consumeBytes(b, &issuer)
+		case num == otpAlgorithm && typ == protowire.VarintType:
+			return consumeVarint(b, &algorithm)
+		case num == otpDigits && typ == protowire.VarintType:
+			return consumeVarint(b, &digits)
+		case num == otpType && typ == protowire.VarintType:
+			return consumeVarint(b, &otpKind)
+		case num == otpCounter && typ == protowire.VarintType:
+			return consumeVarint(b, &counter)
+		}
+		return protowire.ConsumeFieldValue(num, typ, b)
+	})
+	if err != nil {
+		return Secret{}, err
+	}
+
+	if algorithm >= uint64(len(migrationAlgorithms)) || digits >= uint64(len(migrationDigits)) || otpKind >= uint64(len(migrationTypes)) {
+		return Secret{}, fmt.Errorf("unsupported parameters for %s", name)
+	}
+
+	secret := Secret{
+		Name:      string(name),
+		Value:     base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secretBytes),
+		Type:      migrationTypes[otpKind],
+		Algorithm: migrationAlgorithms[algorithm],
+		Digits:    migrationDigits[digits],
+	}
+
+	if secret.Type == TypeHOTP {
+		secret.Counter = counter
+	}
+
+	secret.Issuer, secret.Account = splitName(secret.Name)
+	if len(issuer) != 0 {
+		if !strings.Contains(secret.Name, ":") {
+			secret.Name = string(issuer) + ":" + secret.Name
+		}
+		secret.Issuer = string(issuer)
+	}
+
+	return secret, nil
+}
+
+// ParseMigrationURI parses a Google Authenticator otpauth-migration://
+// export URI
+func ParseMigrationURI(uri string) (MigrationPayload, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return MigrationPayload{}, fmt.Errorf("%w: %s", ErrInvalidMigration, err)
+	}
+
+	if u.Scheme != migrationScheme {
+		return MigrationPayload{}, fmt.Errorf("%w: scheme must be %s", ErrInvalidMigration, migrationScheme)
+	}
+
+	// an unescaped + in the base64 data is decoded as a space
+	data := strings.ReplaceAll(u.Query().Get("data"), " ", "+")
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		if b, err = base64.RawStdEncoding.DecodeString(data); err != nil {
+			return MigrationPayload{}, fmt.Errorf("%w: %s", ErrInvalidMigration, err)
+		}
+	}
+
+	var (
+		payload                                 MigrationPayload
+		parameters                              [][]byte
+		version, batchSize, batchIndex, batchID uint64
+	)
+
+	err = consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch {
+		case num == payloadOtpParameters && typ == protowire.BytesType:
+			var p []byte
+			n := consumeBytes(b, &p)
+			parameters = append(parameters, p)
+			return n
+		case num == payloadVersion && typ == protowire.VarintType:
+			return consumeVarint(b, &version)
+		case num == payloadBatchSize && typ == protowire.VarintType:
+			return consumeVarint(b, &batchSize)
+		case num == payloadBatchIndex && typ == protowire.VarintType:
+			return consumeVarint(b, &batchIndex)
+		case num == payloadBatchID && typ == protowire.VarintType:
+			return consumeVarint(b, &batchID)
+		}
+		return protowire.ConsumeFieldValue(num, typ, b)
+	})
+	if err != nil {
+		return MigrationPayload{}, fmt.Errorf("%w: %s", ErrInvalidMigration, err)
+	}
+
+	for _, p := range parameters {
+		secret, err := decodeOtpParameters(p)
+		if err != nil {
+			return MigrationPayload{}, fmt.Errorf("%w: %s", ErrInvalidMigration, err)
+		}
+		payload.Secrets = append(payload.Secrets, secret)
+	}
+
+	payload.Version = int(version)
+	payload.BatchSize = int(batchSize)
+	payload.BatchIndex = int(batchIndex)
+	payload.BatchID = int(int32(batchID))
+
+	return payload, nil
+}
+
+// CheckMigrationBatches verifies every payload of each batch is present
+func CheckMigrationBatches(payloads []MigrationPayload) error {
+	type batch struct {
+		size    int
+		indexes map[int]bool
+	}
+
+	batches := map[int]*batch{}
+	for _, p := range payloads {
+		b, ok := batches[p.BatchID]
+		if !ok {
+			b = &batch{size: p.BatchSize, indexes: map[int]bool{}}
+			batches[p.BatchID] = b
+		}
+		b.indexes[p.BatchIndex] = true
+	}
+
+	for id, b := range batches {
+		if len(b.indexes) < b.size {
+			return fmt.Errorf("%w: batch %d has %d of %d parts", ErrMigrationIncomplete, id, len(b.indexes), b.size)
+		}
+	}
+
+	return nil
+}
+
+// MigrationSupported reports why a secret cannot be represented in an
+// otpauth-migration payload, or nil if it can
+func (s Secret) MigrationSupported() error {
+	opts, err := s.Options().normalize()
+	if err != nil {
+		return err
+	}
+
+	if opts.GetDigits() != 6 && opts.GetDigits() != 8 {
+		return fmt.Errorf("%w: %d digits", ErrMigrationUnsupported, opts.GetDigits())
+	}
+
+	if !s.IsHOTP() && opts.GetPeriod() != DefaultPeriod {
+		return fmt.Errorf("%w: %d second period", ErrMigrationUnsupported, opts.GetPeriod())
+	}
+
+	if _, err := decodeSecretBytes(s.Value); err != nil {
+		return fmt.Errorf("%w: %s", ErrMigrationUnsupported, err)
+	}
+
+	return nil
+}
+
+func encodeOtpParameters(s Secret) ([]byte, error) {
+	if err := s.MigrationSupported(); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Name, err)
+	}
+
+	secretBytes, _ := decodeSecretBytes(s.Value)
+	opts := s.Options()
+
+	var b []byte
+	b = protowire.AppendTag(b, otpSecret, protowire.BytesType)
+	b = protowire.AppendBytes(b, secretBytes)
+	b = protowire.AppendTag(b, otpName, protowire.BytesType)
+	b = protowire.AppendString(b, s.label())
+	if issuer := s.GetIssuer(); len(issuer) != 0 {
+		b = protowire.AppendTag(b, otpIssuer, protowire.BytesType)
+		b = protowire.AppendString(b, issuer)
+	}
+	b = protowire.AppendTag(b, otpAlgorithm, protowire.VarintType)
+	b = protowire.AppendVarint(b, migrationIndex(migrationAlgorithms, strings.ToUpper(opts.GetAlgorithm())))
+	b = protowire.AppendTag(b, otpDigits, protowire.VarintType)
+	b = protowire.AppendVarint(b, migrationDigitsIndex(opts.GetDigits()))
+	b = protowire.AppendTag(b, otpType, protowire.VarintType)
+	b = protowire.AppendVarint(b, migrationIndex(migrationTypes, opts.GetType()))
+	if s.IsHOTP() {
+		b = protowire.AppendTag(b, otpCounter, protowire.VarintType)
+		b = protowire.AppendVarint(b, s.Counter)
+	}
+
+	return b, nil
+}
+
+// NewMigrationURIs encodes the secrets into a batch of otpauth-migration
+// URIs, each holding at most batchSize secrets. An error is returned if a
+// secret cannot be represented.
+func NewMigrationURIs(secrets []Secret, batchSize int) ([]string, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultMigrationBatchSize
+	}
+
+	var parameters [][]byte
+	for _, s := range secrets {
+		p, err := encodeOtpParameters(s)
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, p)
+	}
+
+	count := (len(parameters) + batchSize - 1) / batchSize
+	batchID := uint64(uint32(rand.Int31()))
+
+	var uris []string
+	for i := 0; i < count; i++ {
+		end := (i + 1) * batchSize
+		if end > len(parameters) {
+			end = len(parameters)
+		}
+
+		var b []byte
+		for _, p := range parameters[i*batchSize : end] {
+			b = protowire.AppendTag(b, payloadOtpParameters, protowire.BytesType)
+			b = protowire.AppendBytes(b, p)
+		}
+		b = protowire.AppendTag(b, payloadVersion, protowire.VarintType)
+		b = protowire.AppendVarint(b, migrationVersion)
+		b = protowire.AppendTag(b, payloadBatchSize, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(count))
+		b = protowire.AppendTag(b, payloadBatchIndex, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(i))
+		b = protowire.AppendTag(b, payloadBatchID, protowire.VarintType)
+		b = protowire.AppendVarint(b, batchID)
+
+		uris = append(uris, migrationScheme+"://offline?data="+url.QueryEscape(base64.StdEncoding.EncodeToString(b)))
+	}
+
+	return uris, nil
+}
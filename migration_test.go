@@ -0,0 +1,149 @@
+package totp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMigrationURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    []Secret
+		wantErr bool
+	}{
+		{
+			name: "google authenticator export",
+			uri:  "otpauth-migration://offline?data=CjEKCkhlbGxvId6tvu8SGEV4YW1wbGU6YWxpY2VAZ29vZ2xlLmNvbRoHRXhhbXBsZTAC",
+			want: []Secret{{Name: "Example:alice@google.com", Value: "JBSWY3DPEHPK3PXP", Type: TypeTOTP, Issuer: "Example", Account: "alice@google.com"}},
+		},
+		{
+			name:    "invalid scheme",
+			uri:     "otpauth://offline?data=CjEKCkhlbGxvId6tvu8SGEV4YW1wbGU6YWxpY2VAZ29vZ2xlLmNvbRoHRXhhbXBsZTAC",
+			wantErr: true,
+		},
+		{
+			name:    "invalid base64",
+			uri:     "otpauth-migration://offline?data=!!!",
+			wantErr: true,
+		},
+		{
+			name:    "invalid protobuf",
+			uri:     "otpauth-migration://offline?data=CjE",
+			wantErr: true,
+		},
+		{
+			name:    "unparsable",
+			uri:     "otpauth-migration://offline/%zz",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMigrationURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseMigrationURI() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got.Secrets) != len(tt.want) {
+				t.Fatalf("ParseMigrationURI() = %v, want %v", got.Secrets, tt.want)
+			}
+			for i := range tt.want {
+				if !reflect.DeepEqual(got.Secrets[i], tt.want[i]) {
+					t.Errorf("ParseMigrationURI() = %v, want %v", got.Secrets[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewMigrationURIs(t *testing.T) {
+	secrets := []Secret{
+		{Name: "Example:alice@google.com", Value: "JBSWY3DPEHPK3PXP"},
+		{Name: "sha256", Value: "SEEDSEED", Algorithm: "SHA256", Digits: 8},
+		{Name: "hotp", Value: "SEEDSEED", Type: TypeHOTP, Counter: 42},
+	}
+
+	uris, err := NewMigrationURIs(secrets, 2)
+	if err != nil {
+		t.Fatal("NewMigrationURIs() error:", err)
+	}
+
+	if len(uris) != 2 {
+		t.Fatalf("NewMigrationURIs() returned %d URIs, want 2", len(uris))
+	}
+
+	var (
+		payloads []MigrationPayload
+		got      []Secret
+	)
+	for i, uri := range uris {
+		p, err := ParseMigrationURI(uri)
+		if err != nil {
+			t.Fatal("ParseMigrationURI() error:", err)
+		}
+
+		if p.BatchIndex != i || p.BatchSize != 2 || p.Version != migrationVersion {
+			t.Errorf("ParseMigrationURI() batch = %v", p)
+		}
+
+		payloads = append(payloads, p)
+		got = append(got, p.Secrets...)
+	}
+
+	if payloads[0].BatchID != payloads[1].BatchID {
+		t.Error("Batch IDs differ")
+	}
+
+	want := []Secret{
+		{Name: "Example:alice@google.com", Value: "JBSWY3DPEHPK3PXP", Type: TypeTOTP, Algorithm: "SHA1", Digits: 6, Issuer: "Example", Account: "alice@google.com"},
+		{Name: "sha256", Value: "SEEDSEED", Type: TypeTOTP, Algorithm: "SHA256", Digits: 8, Account: "sha256"},
+		{Name: "hotp", Value: "SEEDSEED", Type: TypeHOTP, Algorithm: "SHA1", Digits: 6, Counter: 42, Account: "hotp"},
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("round trip = %v, want %v", got[i], want[i])
+		}
+	}
+
+	if err := CheckMigrationBatches(payloads); err != nil {
+		t.Error("CheckMigrationBatches() error:", err)
+	}
+
+	if err := CheckMigrationBatches(payloads[1:]); !errors.Is(err, ErrMigrationIncomplete) {
+		t.Errorf("CheckMigrationBatches() error = %v, want %v", err, ErrMigrationIncomplete)
+	}
+
+	// Default batch size
+	if uris, _ := NewMigrationURIs(secrets, 0); len(uris) != 1 || !strings.HasPrefix(uris[0], "otpauth-migration://offline?data=") {
+		t.Errorf("NewMigrationURIs() = %v", uris)
+	}
+}
+
+func TestSecret_MigrationSupported(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  Secret
+		wantErr bool
+	}{
+		{name: "defaults", secret: Secret{Value: "SEED"}},
+		{name: "hotp with period", secret: Secret{Value: "SEED", Type: TypeHOTP, Period: 60}},
+		{name: "seven digits", secret: Secret{Value: "SEED", Digits: 7}, wantErr: true},
+		{name: "period", secret: Secret{Value: "SEED", Period: 60}, wantErr: true},
+		{name: "invalid value", secret: Secret{Value: "SEED0"}, wantErr: true},
+		{name: "invalid options", secret: Secret{Value: "SEED", Algorithm: "invalid"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.secret.MigrationSupported(); (err != nil) != tt.wantErr {
+				t.Errorf("Secret.MigrationSupported() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewMigrationURIs([]Secret{{Value: "SEED", Period: 60}}, 1); !errors.Is(err, ErrMigrationUnsupported) {
+		t.Errorf("NewMigrationURIs() error = %v, want %v", err, ErrMigrationUnsupported)
+	}
+}
@@ -0,0 +1,158 @@
+package totp
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+var ErrInvalidAlgorithm = errors.New("invalid algorithm, must be one of SHA1, SHA256, SHA512, or MD5")
+var ErrInvalidDigits = errors.New("invalid digits, must be between 6 and 8")
+var ErrInvalidType = errors.New("invalid type, must be totp or hotp")
+
+const (
+	// TypeTOTP is the type of a time-based secret
+	TypeTOTP = "totp"
+
+	// TypeHOTP is the type of a counter-based secret
+	TypeHOTP = "hotp"
+
+	// DefaultAlgorithm is the HMAC algorithm used when a secret has none
+	DefaultAlgorithm = "SHA1"
+
+	// DefaultDigits is the code length used when a secret has none
+	DefaultDigits = 6
+
+	// DefaultPeriod is the number of seconds a code is valid when a secret
+	// has no period
+	DefaultPeriod = 30
+
+	minDigits = 6
+	maxDigits = 8
+)
+
+var algorithms = map[string]otp.Algorithm{
+	"SHA1":   otp.AlgorithmSHA1,
+	"SHA256": otp.AlgorithmSHA256,
+	"SHA512": otp.AlgorithmSHA512,
+	"MD5":    otp.AlgorithmMD5,
+}
+
+// SecretOptions holds the code generation parameters of a secret. Zero
+// values select the defaults.
+type SecretOptions struct {
+	// Type is the secret type (totp or hotp)
+	Type string
+
+	// Algorithm is the HMAC algorithm (SHA1, SHA256, SHA512, or MD5)
+	Algorithm string
+
+	// Digits is the number of digits in a generated code
+	Digits int
+
+	// Period is the number of seconds a generated code is valid
+	Period uint
+}
+
+// normalize cases the type and algorithm and validates the options
+func (o SecretOptions) normalize() (SecretOptions, error) {
+	o.Type = strings.ToLower(o.Type)
+	if o.Type != "" && o.Type != TypeTOTP && o.Type != TypeHOTP {
+		return o, ErrInvalidType
+	}
+
+	o.Algorithm = strings.ToUpper(o.Algorithm)
+	if _, ok := algorithms[o.Algorithm]; len(o.Algorithm) != 0 && !ok {
+		return o, ErrInvalidAlgorithm
+	}
+
+	if o.Digits != 0 && (o.Digits < minDigits || o.Digits > maxDigits) {
+		return o, ErrInvalidDigits
+	}
+
+	return o, nil
+}
+
+// validateOpts converts the options for use by the otp package
+func (o SecretOptions) validateOpts() totp.ValidateOpts {
+	return totp.ValidateOpts{
+		Period:    o.GetPeriod(),
+		Digits:    otp.Digits(o.GetDigits()),
+		Algorithm: algorithms[o.GetAlgorithm()],
+	}
+}
+
+// GetType returns the type or TypeTOTP if none is set
+func (o SecretOptions) GetType() string {
+	if len(o.Type) == 0 {
+		return TypeTOTP
+	}
+
+	return strings.ToLower(o.Type)
+}
+
+// GetAlgorithm returns the algorithm or the default if none is set
+func (o SecretOptions) GetAlgorithm() string {
+	if len(o.Algorithm) == 0 {
+		return DefaultAlgorithm
+	}
+
+	return o.Algorithm
+}
+
+// GetDigits returns the number of digits or the default if none is set
+func (o SecretOptions) GetDigits() int {
+	if o.Digits == 0 {
+		return DefaultDigits
+	}
+
+	return o.Digits
+}
+
+// GetPeriod returns the period in seconds or the default if none is set
+func (o SecretOptions) GetPeriod() uint {
+	if o.Period == 0 {
+		return DefaultPeriod
+	}
+
+	return o.Period
+}
+
+// PeriodDuration returns the period as a time.Duration
+func (o SecretOptions) PeriodDuration() time.Duration {
+	return time.Duration(o.GetPeriod()) * time.Second
+}
+
+// Options returns the code generation options of the secret
+func (s Secret) Options() SecretOptions {
+	return SecretOptions{
+		Type:      s.Type,
+		Algorithm: s.Algorithm,
+		Digits:    s.Digits,
+		Period:    s.Period,
+	}
+}
+
+// IsHOTP reports whether the secret is a counter-based secret
+func (s Secret) IsHOTP() bool {
+	return s.Options().GetType() == TypeHOTP
+}
+
+// GenerateCodeWithTime creates a code for the given time using the
+// secret's value and options. An HOTP secret ignores the time and uses
+// its counter.
+func (s Secret) GenerateCodeWithTime(t time.Time) (string, error) {
+	if s.IsHOTP() {
+		return s.generateCodeWithCounter(s.Counter)
+	}
+
+	opts, err := s.Options().normalize()
+	if err != nil {
+		return "", err
+	}
+
+	return totp.GenerateCodeCustom(s.Value, t, opts.validateOpts())
+}
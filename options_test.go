@@ -0,0 +1,132 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSecretOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          SecretOptions
+		wantAlgorithm string
+		wantDigits    int
+		wantPeriod    uint
+		wantErr       bool
+	}{
+		{
+			name:          "defaults",
+			opts:          SecretOptions{},
+			wantAlgorithm: DefaultAlgorithm,
+			wantDigits:    DefaultDigits,
+			wantPeriod:    DefaultPeriod,
+		},
+		{
+			name:          "custom options",
+			opts:          SecretOptions{Algorithm: "sha512", Digits: 8, Period: 60},
+			wantAlgorithm: "SHA512",
+			wantDigits:    8,
+			wantPeriod:    60,
+		},
+		{
+			name:    "invalid algorithm",
+			opts:    SecretOptions{Algorithm: "SHA3"},
+			wantErr: true,
+		},
+		{
+			name:    "too few digits",
+			opts:    SecretOptions{Digits: 5},
+			wantErr: true,
+		},
+		{
+			name:    "too many digits",
+			opts:    SecretOptions{Digits: 9},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.normalize()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SecretOptions.normalize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got.GetAlgorithm() != tt.wantAlgorithm || got.GetDigits() != tt.wantDigits || got.GetPeriod() != tt.wantPeriod {
+				t.Errorf("SecretOptions = %v, want %v %v %v", got, tt.wantAlgorithm, tt.wantDigits, tt.wantPeriod)
+			}
+			if d := got.PeriodDuration(); d != time.Duration(tt.wantPeriod)*time.Second {
+				t.Errorf("SecretOptions.PeriodDuration() = %v", d)
+			}
+		})
+	}
+}
+
+func TestSecret_GenerateCodeWithTime(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2019-06-23T20:00:01-05:00")
+
+	tests := []struct {
+		name    string
+		secret  Secret
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "default options",
+			secret: Secret{Value: "SEED"},
+			want:   "335072",
+		},
+		{
+			name:   "custom options",
+			secret: Secret{Value: "SEED", Algorithm: "SHA256", Digits: 8, Period: 60},
+			want:   "68372300",
+		},
+		{
+			name:    "invalid options",
+			secret:  Secret{Value: "SEED", Algorithm: "invalid"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			secret:  Secret{Value: "SEED0"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.secret.GenerateCodeWithTime(now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Secret.GenerateCodeWithTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Secret.GenerateCodeWithTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollection_UpdateSecretWithOptions(t *testing.T) {
+	c := NewCollection()
+
+	s, err := c.UpdateSecretWithOptions("testname", "seed", SecretOptions{Algorithm: "sha256", Digits: 8, Period: 60})
+	if err != nil {
+		t.Fatal("Collection.UpdateSecretWithOptions() error:", err)
+	}
+
+	if s.Algorithm != "SHA256" || s.Digits != 8 || s.Period != 60 {
+		t.Errorf("Collection.UpdateSecretWithOptions() = %v", s)
+	}
+
+	// Options are kept by UpdateSecret
+	s, err = c.UpdateSecret("testname", "seedseed")
+	if err != nil || s.Algorithm != "SHA256" || s.Digits != 8 || s.Period != 60 {
+		t.Errorf("Collection.UpdateSecret() = %v, %v", s, err)
+	}
+
+	if _, err := c.UpdateSecretWithOptions("testname", "seed", SecretOptions{Digits: 10}); err == nil {
+		t.Error("Collection.UpdateSecretWithOptions() with invalid options did not fail")
+	}
+}
@@ -0,0 +1,123 @@
+package totp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the version of the serialized collection format written
+// by this package. Files without a version are version 0.
+const SchemaVersion = 1
+
+// ErrNewerSchema is returned when collection data was written by a newer
+// version of the package than this one
+var ErrNewerSchema = errors.New("collection was saved by a newer version of totp")
+
+// schemaUpgrade upgrades the top level fields of serialized collection data
+// by one version
+type schemaUpgrade struct {
+	description string
+	upgrade     func(fields map[string]json.RawMessage) error
+}
+
+// schemaUpgrades upgrade version i to version i + 1. Add an upgrade and
+// increment SchemaVersion when the collection format changes in a way
+// older versions cannot read. Directory stores run each secret file through
+// the upgrades as a collection of one secret, and their version file as a
+// collection without secrets.
+var schemaUpgrades = []schemaUpgrade{
+	{
+		// the secret fields added before versioning are optional, so only
+		// the version is added
+		description: "add the schema version",
+		upgrade:     func(map[string]json.RawMessage) error { return nil },
+	},
+}
+
+// upgradeSchema upgrades serialized collection data to SchemaVersion,
+// returning the upgraded data and a description of each upgrade applied.
+// Data at the current version is returned unchanged.
+func upgradeSchema(data []byte) ([]byte, []string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, nil, err
+	}
+
+	// null unmarshals to a nil map, and is loaded as an empty collection
+	if fields == nil {
+		fields = map[string]json.RawMessage{}
+	}
+
+	version := 0
+	if raw, ok := fields["Version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, nil, fmt.Errorf("invalid schema version: %w", err)
+		}
+	}
+
+	if version > SchemaVersion {
+		return nil, nil, fmt.Errorf("%w: schema version %d, supported up to %d", ErrNewerSchema, version, SchemaVersion)
+	}
+
+	if version == SchemaVersion {
+		return data, nil, nil
+	}
+
+	var changes []string
+	for ; version < SchemaVersion; version++ {
+		u := schemaUpgrades[version]
+		if err := u.upgrade(fields); err != nil {
+			return nil, nil, fmt.Errorf("upgrading schema version %d: %w", version, err)
+		}
+		changes = append(changes, fmt.Sprintf("version %d to %d: %s", version, version+1, u.description))
+	}
+
+	fields["Version"] = json.RawMessage(fmt.Sprint(SchemaVersion))
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, changes, nil
+}
+
+// upgradeSecretSchema upgrades the serialized data of one secret saved at a
+// schema version, such as a secret file of a directory store, by running
+// it through the upgrades as part of a collection
+func upgradeSecretSchema(data []byte, version int) ([]byte, error) {
+	collection, err := json.Marshal(struct {
+		Version int
+		Secrets map[string]json.RawMessage
+	}{version, map[string]json.RawMessage{"": data}})
+	if err != nil {
+		return nil, err
+	}
+
+	upgraded, _, err := upgradeSchema(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields struct {
+		Secrets map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(upgraded, &fields); err != nil {
+		return nil, err
+	}
+
+	secret, ok := fields.Secrets[""]
+	if !ok {
+		return nil, errors.New("the schema upgrade removed the secret")
+	}
+
+	return secret, nil
+}
+
+// SchemaUpgrades returns a description of each schema upgrade applied when
+// the collection was loaded. Saving the collection writes the upgraded
+// format.
+func (c *Collection) SchemaUpgrades() []string {
+	return c.schemaUpgrades
+}
@@ -0,0 +1,79 @@
+package totp
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_upgradeSchema(t *testing.T) {
+	// unversioned data is upgraded
+	data, changes, err := upgradeSchema([]byte(`{"Secrets": {"name": {"Name": "name", "Value": "SEED"}}}`))
+	if err != nil {
+		t.Fatal("upgradeSchema() error:", err)
+	}
+	if want := []string{"version 0 to 1: add the schema version"}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("upgradeSchema() changes = %v, want %v", changes, want)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || string(fields["Version"]) != "1" || !strings.Contains(string(fields["Secrets"]), "SEED") {
+		t.Errorf("upgradeSchema() = %s, %v", data, err)
+	}
+
+	// current data is unchanged
+	current := []byte(`{"Version": 1, "Secrets": {}}`)
+	if data, changes, err := upgradeSchema(current); err != nil || changes != nil || string(data) != string(current) {
+		t.Errorf("upgradeSchema() of current data = %s, %v, %v", data, changes, err)
+	}
+
+	// null is an empty collection
+	if data, changes, err := upgradeSchema([]byte("null")); err != nil || len(changes) != 1 || string(data) != `{"Version":1}` {
+		t.Errorf("upgradeSchema() of null = %s, %v, %v", data, changes, err)
+	}
+
+	// newer data is refused
+	if _, _, err := upgradeSchema([]byte(`{"Version": 2, "Secrets": {}}`)); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("upgradeSchema() error = %v, want %v", err, ErrNewerSchema)
+	}
+
+	// invalid data
+	if _, _, err := upgradeSchema([]byte(`{"Version": "one"}`)); err == nil {
+		t.Error("upgradeSchema() with invalid version did not fail")
+	}
+	if _, _, err := upgradeSchema([]byte(`[]`)); err == nil {
+		t.Error("upgradeSchema() with invalid data did not fail")
+	}
+
+	// every version has an upgrade
+	if len(schemaUpgrades) != SchemaVersion {
+		t.Errorf("%d schema upgrades for version %d", len(schemaUpgrades), SchemaVersion)
+	}
+}
+
+func TestCollection_SchemaVersion(t *testing.T) {
+	c := NewCollection()
+	_, _ = c.UpdateSecret("name", "SEED")
+
+	data, err := c.Serialize()
+	if err != nil || !strings.Contains(string(data), `"Version": 1`) {
+		t.Errorf("Serialize() = %s, %v", data, err)
+	}
+
+	c2, err := NewCollectionWithData(data)
+	if err != nil || c2.SchemaUpgrades() != nil || c2.Version != SchemaVersion {
+		t.Errorf("NewCollectionWithData() = %v, %v", c2, err)
+	}
+
+	// an old collection is upgraded when loaded
+	c3, err := NewCollectionWithData([]byte(`{"Secrets": {}}`))
+	if err != nil || len(c3.SchemaUpgrades()) != 1 || c3.Version != SchemaVersion {
+		t.Errorf("NewCollectionWithData() of old data = %v, %v", c3, err)
+	}
+
+	if _, err := NewCollectionWithData([]byte(`{"Version": 99, "Secrets": {}}`)); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("NewCollectionWithData() error = %v, want %v", err, ErrNewerSchema)
+	}
+}
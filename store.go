@@ -0,0 +1,206 @@
+package totp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownStore is returned when a store URL has an unregistered scheme
+var ErrUnknownStore = errors.New("unknown store scheme")
+
+// Store loads, saves, and locks collections. The built-in stores keep a
+// collection in a file, a stream, or a directory with a file per secret.
+// Other backends can be added with RegisterStore.
+type Store interface {
+	// Load reads the stored secrets into the collection. An error
+	// wrapping os.ErrNotExist is returned when nothing is stored yet.
+	Load(c *Collection) error
+
+	// Save writes the collection
+	Save(c *Collection) error
+
+	// Lock acquires an exclusive lock held across loading, modifying,
+	// and saving the collection, waiting up to timeout
+	Lock(timeout time.Duration) (Unlocker, error)
+}
+
+// Unlocker releases a lock acquired with Store.Lock
+type Unlocker interface {
+	Unlock() error
+}
+
+// StoreOpener opens the store at a URL
+type StoreOpener func(u *url.URL) (Store, error)
+
+var (
+	storeOpenersMu sync.RWMutex
+	storeOpeners   = map[string]StoreOpener{
+		"file": func(u *url.URL) (Store, error) {
+			return &FileStore{Filename: storePath(u)}, nil
+		},
+		"dir": func(u *url.URL) (Store, error) {
+			return &DirStore{Dir: storePath(u)}, nil
+		},
+	}
+)
+
+// RegisterStore makes a store available to OpenStore for URLs with the
+// scheme, replacing any store registered for it
+func RegisterStore(scheme string, opener StoreOpener) {
+	storeOpenersMu.Lock()
+	defer storeOpenersMu.Unlock()
+
+	storeOpeners[scheme] = opener
+}
+
+// OpenStore opens the store at location, which is either a URL such as
+// "dir:///path/to/secrets" or the name of a collection file
+func OpenStore(location string) (Store, error) {
+	i := strings.Index(location, "://")
+	if i <= 0 {
+		return &FileStore{Filename: location}, nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	storeOpenersMu.RLock()
+	opener, ok := storeOpeners[u.Scheme]
+	storeOpenersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStore, u.Scheme)
+	}
+
+	return opener(u)
+}
+
+// storePath returns the file system path of a store URL. A host is taken
+// as the start of a relative path.
+func storePath(u *url.URL) string {
+	p := u.Host + u.Path
+
+	// "dir:///C:/path" has the path "/C:/path" on Windows
+	if runtime.GOOS == "windows" && len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+
+	return filepath.FromSlash(p)
+}
+
+// noLock is the lock of stores that cannot be locked
+type noLock struct{}
+
+func (noLock) Unlock() error {
+	return nil
+}
+
+// FileStore keeps a collection in a file
+type FileStore struct {
+	// Filename is the name of the collection file
+	Filename string
+
+	// Backups is the number of backups of the file kept when saving
+	Backups int
+}
+
+// Load reads the collection file
+func (s *FileStore) Load(c *Collection) error {
+	data, err := os.ReadFile(s.Filename)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Deserialize(data); err != nil {
+		return err
+	}
+
+	c.snapshotLoaded()
+	return nil
+}
+
+// Save backs up and atomically replaces the collection file. The file is
+// not backed up when only HOTP counters changed, so generating codes and
+// saves without changes do not push older backups out.
+func (s *FileStore) Save(c *Collection) error {
+	data, err := c.marshal()
+	if err != nil {
+		return err
+	}
+
+	if s.Backups > 0 && !c.onlyCountersChanged() {
+		if _, err := BackupFile(s.Filename, s.Backups); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFileAtomic(s.Filename, data, 0600); err != nil {
+		return err
+	}
+
+	c.saved = c.configSnapshot()
+	return nil
+}
+
+// Lock locks the collection file
+func (s *FileStore) Lock(timeout time.Duration) (Unlocker, error) {
+	return LockFile(s.Filename, timeout)
+}
+
+// StdioStore reads a collection from a reader and writes it to a writer,
+// such as stdin and stdout in a pipeline
+type StdioStore struct {
+	Reader io.Reader
+	Writer io.Writer
+}
+
+// Load reads the collection from the reader
+func (s *StdioStore) Load(c *Collection) error {
+	data, err := io.ReadAll(s.Reader)
+	if err != nil {
+		return err
+	}
+
+	return c.Deserialize(data)
+}
+
+// Save writes the collection to the writer
+func (s *StdioStore) Save(c *Collection) error {
+	data, err := c.marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Writer.Write(data)
+	return err
+}
+
+// Lock does nothing because streams are not shared
+func (s *StdioStore) Lock(time.Duration) (Unlocker, error) {
+	return noLock{}, nil
+}
+
+// NewCollectionWithStore creates a new Collection instance with the secrets
+// loaded from the store, which the Save method then writes to. Like
+// NewCollectionWithFile, a usable collection is returned with any error.
+func NewCollectionWithStore(store Store) (*Collection, error) {
+	c := NewCollection()
+	c.store = store
+
+	return c, store.Load(c)
+}
+
+// SetStore sets the store for the Save method, which takes precedence over
+// a writer or filename
+func (c *Collection) SetStore(store Store) {
+	c.store = store
+}
@@ -0,0 +1,162 @@
+package totp
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryStore is a store kept in memory, as an embedding program might add
+type memoryStore struct {
+	data []byte
+}
+
+func (s *memoryStore) Load(c *Collection) error {
+	if s.data == nil {
+		return os.ErrNotExist
+	}
+
+	return c.Deserialize(s.data)
+}
+
+func (s *memoryStore) Save(c *Collection) error {
+	data, err := c.marshal()
+	s.data = data
+	return err
+}
+
+func (s *memoryStore) Lock(time.Duration) (Unlocker, error) {
+	return noLock{}, nil
+}
+
+func TestOpenStore(t *testing.T) {
+	tests := []struct {
+		location string
+		want     Store
+	}{
+		{"collection.json", &FileStore{Filename: "collection.json"}},
+		{"file:///tmp/collection.json", &FileStore{Filename: filepath.FromSlash("/tmp/collection.json")}},
+		{"dir:///tmp/secrets", &DirStore{Dir: filepath.FromSlash("/tmp/secrets")}},
+		{"dir://secrets/work", &DirStore{Dir: filepath.FromSlash("secrets/work")}},
+	}
+	for _, tt := range tests {
+		got, err := OpenStore(tt.location)
+		if err != nil {
+			t.Errorf("OpenStore(%q) error: %v", tt.location, err)
+			continue
+		}
+
+		switch want := tt.want.(type) {
+		case *FileStore:
+			if s, ok := got.(*FileStore); !ok || *s != *want {
+				t.Errorf("OpenStore(%q) = %#v, want %#v", tt.location, got, want)
+			}
+		case *DirStore:
+			if s, ok := got.(*DirStore); !ok || s.Dir != want.Dir {
+				t.Errorf("OpenStore(%q) = %#v, want %#v", tt.location, got, want)
+			}
+		}
+	}
+
+	if _, err := OpenStore("nosuchscheme://somewhere"); !errors.Is(err, ErrUnknownStore) {
+		t.Errorf("OpenStore() error = %v, want %v", err, ErrUnknownStore)
+	}
+
+	if _, err := OpenStore("dir://%zz"); err == nil {
+		t.Error("OpenStore() with invalid URL did not fail")
+	}
+
+	// registered stores
+	memory := &memoryStore{}
+	RegisterStore("memory", func(*url.URL) (Store, error) { return memory, nil })
+	if got, err := OpenStore("memory://"); err != nil || got != memory {
+		t.Errorf("OpenStore() of registered store = %v, %v", got, err)
+	}
+}
+
+func TestNewCollectionWithStore(t *testing.T) {
+	store := &memoryStore{}
+
+	c, err := NewCollectionWithStore(store)
+	if !errors.Is(err, os.ErrNotExist) || c == nil {
+		t.Fatalf("NewCollectionWithStore() of empty store = %v, %v", c, err)
+	}
+
+	_, _ = c.UpdateSecret("name", "SEED")
+	if err := c.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+
+	c, err = NewCollectionWithStore(store)
+	if err != nil || len(c.Secrets) != 1 {
+		t.Errorf("NewCollectionWithStore() = %v, %v", c.Secrets, err)
+	}
+
+	// a filename or writer replaces the store
+	writer := &bytes.Buffer{}
+	c.SetWriter(writer)
+	_, _ = c.UpdateSecret("other", "SEED")
+	_ = c.Save()
+	if !strings.Contains(writer.String(), "other") || strings.Contains(string(store.data), "other") {
+		t.Error("Save() did not use the writer")
+	}
+
+	c.SetStore(store)
+	_ = c.Save()
+	if !strings.Contains(string(store.data), "other") {
+		t.Error("Save() did not use the store")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "collection.json")
+	store := &FileStore{Filename: filename, Backups: 1}
+
+	c, err := NewCollectionWithStore(store)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() of missing file error = %v", err)
+	}
+
+	_, _ = c.UpdateSecret("name", "SEED")
+	_ = c.Save()
+	_, _ = c.UpdateSecret("other", "SEED")
+	_ = c.Save()
+
+	if c2, err := NewCollectionWithFile(filename); err != nil || len(c2.Secrets) != 2 {
+		t.Errorf("saved collection = %v, %v", c2, err)
+	}
+
+	if backups, _ := Backups(filename); len(backups) != 1 {
+		t.Errorf("Backups() = %v", backups)
+	}
+
+	lock, err := store.Lock(time.Second)
+	if err != nil {
+		t.Fatal("Lock() error:", err)
+	}
+	_ = lock.Unlock()
+}
+
+func TestStdioStore(t *testing.T) {
+	writer := &bytes.Buffer{}
+	store := &StdioStore{Reader: strings.NewReader(`{"Secrets": {"name": {"Name": "name", "Value": "SEED"}}}`), Writer: writer}
+
+	c, err := NewCollectionWithStore(store)
+	if err != nil || len(c.Secrets) != 1 {
+		t.Fatalf("NewCollectionWithStore() = %v, %v", c, err)
+	}
+
+	if err := c.Save(); err != nil || !strings.Contains(writer.String(), `"name"`) {
+		t.Errorf("Save() = %q, %v", writer.String(), err)
+	}
+
+	lock, err := store.Lock(time.Second)
+	if err != nil || lock.Unlock() != nil {
+		t.Error("Lock() error:", err)
+	}
+}
@@ -0,0 +1,146 @@
+package totp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidURI = errors.New("invalid otpauth URI")
+var ErrSecretExists = errors.New("secret already exists")
+
+const uriScheme = "otpauth"
+
+// ParseURI parses an otpauth:// key URI into a Secret. The secret name is
+// the URI label, prefixed by the issuer parameter when the label has no
+// issuer.
+//
+// https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func ParseURI(uri string) (Secret, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return Secret{}, fmt.Errorf("%w: %s", ErrInvalidURI, err)
+	}
+
+	if u.Scheme != uriScheme {
+		return Secret{}, fmt.Errorf("%w: scheme must be %s", ErrInvalidURI, uriScheme)
+	}
+
+	secretType := strings.ToLower(u.Host)
+	if secretType != TypeTOTP && secretType != TypeHOTP {
+		return Secret{}, fmt.Errorf("%w: %s", ErrInvalidURI, ErrInvalidType)
+	}
+
+	query := u.Query()
+
+	secret := Secret{
+		Name:      strings.TrimPrefix(u.Path, "/"),
+		Value:     strings.ToUpper(query.Get("secret")),
+		Type:      secretType,
+		Algorithm: strings.ToUpper(query.Get("algorithm")),
+	}
+
+	if len(secret.Value) == 0 {
+		return Secret{}, fmt.Errorf("%w: %s", ErrInvalidURI, ErrSecretValueEmpty)
+	}
+
+	secret.Issuer, secret.Account = splitName(secret.Name)
+	if issuer := query.Get("issuer"); len(issuer) != 0 {
+		if !strings.Contains(secret.Name, ":") {
+			secret.Name = issuer + ":" + secret.Name
+		}
+		secret.Issuer = issuer
+	}
+
+	if digits := query.Get("digits"); len(digits) != 0 {
+		if secret.Digits, err = strconv.Atoi(digits); err != nil {
+			return Secret{}, fmt.Errorf("%w: digits: %s", ErrInvalidURI, err)
+		}
+	}
+
+	if period := query.Get("period"); len(period) != 0 {
+		p, err := strconv.ParseUint(period, 10, 32)
+		if err != nil {
+			return Secret{}, fmt.Errorf("%w: period: %s", ErrInvalidURI, err)
+		}
+		secret.Period = uint(p)
+	}
+
+	if counter := query.Get("counter"); len(counter) != 0 {
+		if secret.Counter, err = strconv.ParseUint(counter, 10, 64); err != nil {
+			return Secret{}, fmt.Errorf("%w: counter: %s", ErrInvalidURI, err)
+		}
+	}
+
+	return secret, nil
+}
+
+// ImportSecret adds a secret with its options and counter to the
+// collection, validating it as UpdateSecretWithOptions does. An existing
+// secret with the same name is only replaced when overwrite is true.
+func (c *Collection) ImportSecret(secret Secret, overwrite bool) (Secret, error) {
+	if _, ok := c.Secrets[secret.Name]; ok && !overwrite {
+		return Secret{}, ErrSecretExists
+	}
+
+	retSecret, err := c.UpdateSecretWithOptions(secret.Name, secret.Value, secret.Options())
+	if err != nil {
+		return Secret{}, err
+	}
+
+	retSecret.Counter = secret.Counter
+	retSecret.setMetadata(secret.Metadata())
+	c.Secrets[retSecret.Name] = retSecret
+
+	return retSecret, nil
+}
+
+// splitName splits a secret name into the issuer and account parts of an
+// "issuer:account" label. A name without a colon has no issuer.
+func splitName(name string) (issuer, account string) {
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+
+	return "", name
+}
+
+// URI returns the otpauth:// key URI of the secret. The label is made of
+// the issuer and account, and only non-default options are included.
+func (s Secret) URI() string {
+	opts := s.Options()
+	issuer := s.GetIssuer()
+
+	query := url.Values{}
+	query.Set("secret", strings.ToUpper(s.Value))
+	if len(issuer) != 0 {
+		query.Set("issuer", issuer)
+	}
+
+	if s.IsHOTP() {
+		query.Set("counter", strconv.FormatUint(s.Counter, 10))
+	}
+
+	if algorithm := strings.ToUpper(opts.GetAlgorithm()); algorithm != DefaultAlgorithm {
+		query.Set("algorithm", algorithm)
+	}
+
+	if digits := opts.GetDigits(); digits != DefaultDigits {
+		query.Set("digits", strconv.Itoa(digits))
+	}
+
+	if period := opts.GetPeriod(); !s.IsHOTP() && period != DefaultPeriod {
+		query.Set("period", strconv.FormatUint(uint64(period), 10))
+	}
+
+	u := url.URL{
+		Scheme:   uriScheme,
+		Host:     opts.GetType(),
+		Path:     "/" + s.label(),
+		RawQuery: query.Encode(),
+	}
+
+	return u.String()
+}
@@ -0,0 +1,109 @@
+package totp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    Secret
+		wantErr bool
+	}{
+		{
+			name: "issuer in label",
+			uri:  "otpauth://totp/Example:alice@google.com?secret=jbswy3dpehpk3pxp&issuer=Example",
+			want: Secret{Name: "Example:alice@google.com", Value: "JBSWY3DPEHPK3PXP", Type: TypeTOTP, Issuer: "Example", Account: "alice@google.com"},
+		},
+		{
+			name: "issuer parameter only",
+			uri:  "otpauth://totp/alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+			want: Secret{Name: "Example:alice@google.com", Value: "JBSWY3DPEHPK3PXP", Type: TypeTOTP, Issuer: "Example", Account: "alice@google.com"},
+		},
+		{
+			name: "escaped label",
+			uri:  "otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=sha256&digits=8&period=60",
+			want: Secret{Name: "ACME Co:john.doe@email.com", Value: "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ", Type: TypeTOTP, Algorithm: "SHA256", Digits: 8, Period: 60, Issuer: "ACME Co", Account: "john.doe@email.com"},
+		},
+		{
+			name: "hotp",
+			uri:  "otpauth://hotp/hotpname?secret=JBSWY3DPEHPK3PXP&counter=42",
+			want: Secret{Name: "hotpname", Value: "JBSWY3DPEHPK3PXP", Type: TypeHOTP, Counter: 42, Account: "hotpname"},
+		},
+		{
+			name:    "invalid scheme",
+			uri:     "https://example.com/totp/name?secret=JBSWY3DPEHPK3PXP",
+			wantErr: true,
+		},
+		{
+			name:    "invalid type",
+			uri:     "otpauth://motp/name?secret=JBSWY3DPEHPK3PXP",
+			wantErr: true,
+		},
+		{
+			name:    "missing secret",
+			uri:     "otpauth://totp/name",
+			wantErr: true,
+		},
+		{
+			name:    "invalid digits",
+			uri:     "otpauth://totp/name?secret=JBSWY3DPEHPK3PXP&digits=six",
+			wantErr: true,
+		},
+		{
+			name:    "invalid period",
+			uri:     "otpauth://totp/name?secret=JBSWY3DPEHPK3PXP&period=-1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid counter",
+			uri:     "otpauth://hotp/name?secret=JBSWY3DPEHPK3PXP&counter=x",
+			wantErr: true,
+		},
+		{
+			name:    "unparsable",
+			uri:     "otpauth://totp/%zz",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseURI() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && !errors.Is(err, ErrInvalidURI) {
+				t.Errorf("ParseURI() error = %v, want %v", err, ErrInvalidURI)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseURI() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollection_ImportSecret(t *testing.T) {
+	c := NewCollection()
+
+	s, err := c.ImportSecret(Secret{Name: "hotpname", Value: "seed", Type: "hotp", Counter: 7}, false)
+	if err != nil || s.Counter != 7 || s.Type != TypeHOTP || s.Value != "SEED" {
+		t.Errorf("Collection.ImportSecret() = %v, %v", s, err)
+	}
+
+	if _, err := c.ImportSecret(Secret{Name: "hotpname", Value: "seedseed"}, false); !errors.Is(err, ErrSecretExists) {
+		t.Errorf("Collection.ImportSecret() error = %v, want %v", err, ErrSecretExists)
+	}
+
+	s, err = c.ImportSecret(Secret{Name: "hotpname", Value: "seedseed"}, true)
+	if err != nil || s.Value != "SEEDSEED" || s.Type != "" {
+		t.Errorf("Collection.ImportSecret() overwrite = %v, %v", s, err)
+	}
+
+	if _, err := c.ImportSecret(Secret{Name: "invalid", Value: "seed0"}, false); err == nil {
+		t.Error("Collection.ImportSecret() with invalid value did not fail")
+	}
+}
@@ -0,0 +1,92 @@
+package totp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrCodeMismatch = errors.New("code does not match")
+var ErrSkewTooLarge = errors.New("skew too large")
+
+// DefaultSkew is the number of steps either side of the current step in
+// which a code is accepted
+const DefaultSkew = 1
+
+// MaxSkew is the largest skew accepted, so checking a code generates a
+// bounded number of codes
+const MaxSkew = 100
+
+// verifySteps returns the step offsets to check for a TOTP code, nearest
+// first: 0, -1, 1, -2, 2, ...
+func verifySteps(skew uint) []int {
+	steps := []int{0}
+	for i := 1; i <= int(skew); i++ {
+		steps = append(steps, -i, i)
+	}
+
+	return steps
+}
+
+// VerifyCodeWithTime checks code against the secret's codes for the period
+// containing t and up to skew periods either side, returning the step
+// offset of the matching period. An HOTP secret ignores the time and checks
+// its counter and the skew counters after it, returning the offset from its
+// counter. A skew above MaxSkew returns ErrSkewTooLarge.
+func (s Secret) VerifyCodeWithTime(code string, t time.Time, skew uint) (int, error) {
+	if skew > MaxSkew {
+		return 0, fmt.Errorf("%w: %d, the maximum is %d", ErrSkewTooLarge, skew, MaxSkew)
+	}
+
+	if s.IsHOTP() {
+		for step := 0; step <= int(skew); step++ {
+			generated, err := s.generateCodeWithCounter(s.Counter + uint64(step))
+			if err != nil {
+				return 0, err
+			}
+
+			if subtle.ConstantTimeCompare([]byte(generated), []byte(code)) == 1 {
+				return step, nil
+			}
+		}
+
+		return 0, ErrCodeMismatch
+	}
+
+	period := s.Options().PeriodDuration()
+	for _, step := range verifySteps(skew) {
+		generated, err := s.GenerateCodeWithTime(t.Add(time.Duration(step) * period))
+		if err != nil {
+			return 0, err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(generated), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+
+	return 0, ErrCodeMismatch
+}
+
+// VerifyCode checks code against the named secret as VerifyCodeWithTime
+// does. A matched HOTP code advances the counter past it so the code cannot
+// be used again, and the collection must be saved.
+func (c *Collection) VerifyCode(name, code string, t time.Time, skew uint) (int, error) {
+	secret, err := c.GetSecret(name)
+	if err != nil {
+		return 0, err
+	}
+
+	step, err := secret.VerifyCodeWithTime(code, t, skew)
+	if err != nil {
+		return 0, err
+	}
+
+	if secret.IsHOTP() {
+		secret.Counter += uint64(step) + 1
+		c.Secrets[name] = secret
+	}
+
+	return step, nil
+}
@@ -0,0 +1,92 @@
+package totp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_verifySteps(t *testing.T) {
+	if got, want := verifySteps(2), []int{0, -1, 1, -2, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("verifySteps() = %v, want %v", got, want)
+	}
+}
+
+func TestSecret_VerifyCodeWithTime(t *testing.T) {
+	now := time.Date(2019, 6, 23, 20, 0, 0, 0, time.UTC)
+	secret := Secret{Name: "name", Value: "SEED"}
+
+	codeAt := func(offset time.Duration) string {
+		code, err := secret.GenerateCodeWithTime(now.Add(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     uint
+		wantStep int
+		wantErr  error
+	}{
+		{name: "current", code: codeAt(0), skew: 1, wantStep: 0},
+		{name: "previous", code: codeAt(-30 * time.Second), skew: 1, wantStep: -1},
+		{name: "next", code: codeAt(30 * time.Second), skew: 1, wantStep: 1},
+		{name: "outside window", code: codeAt(90 * time.Second), skew: 2, wantErr: ErrCodeMismatch},
+		{name: "no skew", code: codeAt(30 * time.Second), skew: 0, wantErr: ErrCodeMismatch},
+		{name: "wrong length", code: codeAt(0)[:5], skew: 1, wantErr: ErrCodeMismatch},
+		{name: "maximum skew", code: codeAt(30 * time.Second), skew: MaxSkew, wantStep: 1},
+		{name: "skew too large", code: codeAt(0), skew: 4000000000, wantErr: ErrSkewTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, err := secret.VerifyCodeWithTime(tt.code, now, tt.skew)
+			if !errors.Is(err, tt.wantErr) || step != tt.wantStep {
+				t.Errorf("Secret.VerifyCodeWithTime() = %v, %v, want %v, %v", step, err, tt.wantStep, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := (Secret{Value: "SEED", Algorithm: "MD4"}).VerifyCodeWithTime("123456", now, 1); !errors.Is(err, ErrInvalidAlgorithm) {
+		t.Errorf("Secret.VerifyCodeWithTime() error = %v, want %v", err, ErrInvalidAlgorithm)
+	}
+}
+
+func TestCollection_VerifyCode(t *testing.T) {
+	c := newHOTPTestCollection(t)
+
+	// 359152 is the code for counter 2
+	if step, err := c.VerifyCode("hotpname", "359152", time.Now(), 1); !errors.Is(err, ErrCodeMismatch) {
+		t.Errorf("Collection.VerifyCode() = %v, %v, want %v", step, err, ErrCodeMismatch)
+	}
+
+	step, err := c.VerifyCode("hotpname", "359152", time.Now(), 2)
+	if err != nil || step != 2 {
+		t.Errorf("Collection.VerifyCode() = %v, %v, want 2", step, err)
+	}
+	if s, _ := c.GetSecret("hotpname"); s.Counter != 3 {
+		t.Errorf("Counter = %d, want 3", s.Counter)
+	}
+
+	// HOTP skews are bounded too
+	if _, err := c.VerifyCode("hotpname", "359152", time.Now(), MaxSkew+1); !errors.Is(err, ErrSkewTooLarge) {
+		t.Errorf("Collection.VerifyCode() error = %v, want %v", err, ErrSkewTooLarge)
+	}
+
+	// A used code is not accepted again
+	if _, err := c.VerifyCode("hotpname", "359152", time.Now(), 2); !errors.Is(err, ErrCodeMismatch) {
+		t.Errorf("Collection.VerifyCode() error = %v, want %v", err, ErrCodeMismatch)
+	}
+
+	code, _ := c.GenerateCodeWithTime("totpname", time.Now())
+	if step, err := c.VerifyCode("totpname", code, time.Now(), 0); err != nil || step != 0 {
+		t.Errorf("Collection.VerifyCode() = %v, %v, want 0", step, err)
+	}
+
+	if _, err := c.VerifyCode("nosuchname", code, time.Now(), 0); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Collection.VerifyCode() error = %v, want %v", err, ErrSecretNotFound)
+	}
+}